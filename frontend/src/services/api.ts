@@ -4,6 +4,12 @@ import * as CollectionHandler from '../../wailsjs/go/handlers/CollectionHandler'
 import * as EnvironmentHandler from '../../wailsjs/go/handlers/EnvironmentHandler'
 import * as HistoryHandler from '../../wailsjs/go/handlers/HistoryHandler'
 import * as AppStateHandler from '../../wailsjs/go/handlers/AppStateHandler'
+import * as CookieHandler from '../../wailsjs/go/handlers/CookieHandler'
+import * as CertificateHandler from '../../wailsjs/go/handlers/CertificateHandler'
+import * as WebSocketHandler from '../../wailsjs/go/handlers/WebSocketHandler'
+import * as GraphQLHandler from '../../wailsjs/go/handlers/GraphQLHandler'
+import * as GRPCHandler from '../../wailsjs/go/handlers/GRPCHandler'
+import * as OAuth2Handler from '../../wailsjs/go/handlers/OAuth2Handler'
 import { models, handlers, services } from '../../wailsjs/go/models'
 import type { 
   CollectionTree, 
@@ -18,11 +24,133 @@ import type {
   TabSession,
   KeyValue,
   Variable,
+  RedirectPolicy,
+  RequestSettings,
+  AuthConfig,
+  TLSProfile,
+  HeaderProfile,
+  Cookie,
+  Certificate,
+  ProxyConfig,
+  WebSocketHandshake,
+  WebSocketMessage,
+  GraphQLSchema,
+  GraphQLError,
+  GRPCServiceInfo,
+  GRPCResponse,
+  OAuth2Token,
+  ResolvedRequest,
   Response as ResponseType
 } from '@/types'
+import { WEBSOCKET_METHOD, GRPC_METHOD } from '@/types'
 
 // Type converters - convert Wails generated types to our frontend types
 
+// Parameters of a request whose url, params, headers, body and auth may reference
+// {{variables}}; the backend resolves them
+interface ExecuteParams {
+  tabId: string
+  method: string
+  url: string
+  params?: KeyValue[] // set in the query of url
+  headers: KeyValue[]
+  body: string
+  bodyType: string
+  timeout: number
+  settings?: RequestSettings | null
+  environmentId?: number | null
+  variables?: Variable[] // request-level values, overriding the environment
+  auth?: AuthConfig | null
+  requestId?: number | null
+}
+
+function executeParams(params: ExecuteParams): handlers.ExecuteRequestParams {
+  return handlers.ExecuteRequestParams.createFrom({
+    tabId: params.tabId,
+    method: params.method,
+    url: params.url,
+    params: (params.params ?? []).map(p => models.KeyValue.createFrom(p)),
+    headers: params.headers.map(h => models.KeyValue.createFrom(h)),
+    body: params.body,
+    bodyType: params.bodyType,
+    timeout: params.timeout,
+    settings: params.settings ?? null,
+    environmentId: params.environmentId ?? null,
+    variables: (params.variables ?? []).map(v => models.Variable.createFrom(v)),
+    auth: params.auth ?? null,
+    requestId: params.requestId ?? null,
+  })
+}
+
+function convertResolvedRequest(req: models.ResolvedRequest): ResolvedRequest {
+  return {
+    method: req.method,
+    url: req.url,
+    headers: (req.headers || []).map(convertKeyValue),
+    body: req.body,
+    auth: (req.auth as AuthConfig) ?? null,
+    unresolved: req.unresolved || [],
+    cycles: req.cycles || [],
+    generated: (req.generated || []).map(convertVariable),
+  }
+}
+
+interface GRPCParams {
+  tabId: string
+  url: string
+  headers: KeyValue[]
+  body: string // GRPCBody as JSON
+  timeout: number
+  settings?: RequestSettings | null
+  environmentId?: number | null
+}
+
+function grpcParams(params: GRPCParams): handlers.GRPCParams {
+  return handlers.GRPCParams.createFrom({
+    tabId: params.tabId,
+    url: params.url,
+    headers: params.headers.map(h => models.KeyValue.createFrom(h)),
+    body: params.body,
+    timeout: params.timeout,
+    settings: params.settings ?? null,
+    environmentId: params.environmentId ?? null,
+  })
+}
+
+interface OAuth2Params {
+  tabId: string
+  auth: AuthConfig | null // may inherit
+  requestId?: number | null
+  timeout: number
+  settings?: RequestSettings | null
+  environmentId?: number | null
+}
+
+function oauth2Params(params: OAuth2Params): handlers.OAuth2Params {
+  return handlers.OAuth2Params.createFrom({
+    tabId: params.tabId,
+    auth: params.auth,
+    requestId: params.requestId ?? null,
+    timeout: params.timeout,
+    settings: params.settings ?? null,
+    environmentId: params.environmentId ?? null,
+  })
+}
+
+function convertOAuth2Token(token: models.OAuth2Token): OAuth2Token {
+  return {
+    id: token.id,
+    envId: token.envId,
+    accessToken: token.accessToken,
+    refreshToken: token.refreshToken,
+    tokenType: token.tokenType,
+    scope: token.scope,
+    expiresAt: token.expiresAt ?? null,
+    createdAt: token.createdAt,
+    updatedAt: token.updatedAt,
+  }
+}
+
 function convertKeyValue(kv: models.KeyValue): KeyValue {
   return {
     key: kv.key,
@@ -39,18 +167,49 @@ function convertVariable(v: models.Variable): Variable {
   }
 }
 
+// The frontend keeps the kind of a request in its method: websocket requests show
+// WEBSOCKET_METHOD and are stored as GET, the method of the opening handshake, and
+// grpc requests show GRPC_METHOD and are stored as POST, the method of every call
+function methodOf(kind: string | undefined, method: string): string {
+  switch (kind) {
+    case 'websocket': return WEBSOCKET_METHOD
+    case 'grpc': return GRPC_METHOD
+    default: return method
+  }
+}
+
+function kindOf(method: string | undefined): string {
+  switch (method) {
+    case WEBSOCKET_METHOD: return 'websocket'
+    case GRPC_METHOD: return 'grpc'
+    default: return 'http'
+  }
+}
+
+function storedMethod(method: string | undefined): string {
+  switch (method) {
+    case undefined:
+    case '':
+    case WEBSOCKET_METHOD: return 'GET'
+    case GRPC_METHOD: return 'POST'
+    default: return method
+  }
+}
+
 function convertRequest(req: models.Request): Request {
   return {
     id: req.id,
     collectionId: req.collectionId,
     folderId: req.folderId ?? null,
     name: req.name,
-    method: req.method,
+    method: methodOf(req.kind, req.method),
     url: req.url,
     headers: (req.headers || []).map(convertKeyValue),
     params: (req.params || []).map(convertKeyValue),
     body: req.body,
     bodyType: req.bodyType,
+    settings: (req.settings as RequestSettings) ?? null,
+    auth: (req.auth as AuthConfig) ?? null,
     sortOrder: req.sortOrder,
     createdAt: String(req.createdAt),
     updatedAt: String(req.updatedAt),
@@ -62,6 +221,7 @@ function convertCollection(col: models.Collection): Collection {
     id: col.id,
     name: col.name,
     description: col.description,
+    auth: (col.auth as AuthConfig) ?? null,
     sortOrder: col.sortOrder,
     createdAt: String(col.createdAt),
     updatedAt: String(col.updatedAt),
@@ -73,17 +233,33 @@ function convertFolder(folder: models.Folder): Folder {
     id: folder.id,
     collectionId: folder.collectionId,
     name: folder.name,
+    auth: (folder.auth as AuthConfig) ?? null,
     sortOrder: folder.sortOrder,
     createdAt: String(folder.createdAt),
     updatedAt: String(folder.updatedAt),
   }
 }
 
+function convertProxyConfig(proxy: Partial<models.ProxyConfig>): ProxyConfig {
+  return {
+    enabled: proxy.enabled || false,
+    http: proxy.http || '',
+    https: proxy.https || '',
+    socks: proxy.socks || '',
+    pac: proxy.pac || '',
+    autoDetect: proxy.autoDetect || false,
+    username: proxy.username || '',
+    password: proxy.password || '',
+    bypass: proxy.bypass || [],
+  }
+}
+
 function convertEnvironment(env: models.Environment): Environment {
   return {
     id: env.id,
     name: env.name,
     variables: (env.variables || []).map(convertVariable),
+    proxy: env.proxy ? convertProxyConfig(env.proxy) : null,
     createdAt: String(env.createdAt),
     updatedAt: String(env.updatedAt),
   }
@@ -101,6 +277,11 @@ function convertHistory(h: models.History): History {
     responseHeaders: h.responseHeaders,
     responseBody: h.responseBody,
     durationMs: h.durationMs ?? null,
+    timing: h.timing || '',
+    redirects: h.redirects || '',
+    raw: h.raw || '',
+    events: h.events || '',
+    variables: h.variables || '',
     createdAt: String(h.createdAt),
   }
 }
@@ -137,11 +318,59 @@ function convertAppState(state: models.AppState): AppState {
     requestTimeout: state.requestTimeout,
     autoLocateSidebar: state.autoLocateSidebar,
     useSystemProxy: state.useSystemProxy,
-    requestPanelTab: (state.requestPanelTab || 'params') as 'params' | 'headers' | 'body',
+    manualProxy: convertProxyConfig(state.manualProxy || {}),
+    reuseConnections: state.reuseConnections,
+    followRedirects: state.followRedirects,
+    maxRedirects: state.maxRedirects,
+    preserveRedirectMethod: state.preserveRedirectMethod,
+    stripRedirectAuth: state.stripRedirectAuth,
+    cookieJarEnabled: state.cookieJarEnabled,
+    scopeCookiesByEnv: state.scopeCookiesByEnv,
+    tlsProfile: (state.tlsProfile || 'chrome') as TLSProfile,
+    headerProfile: (state.headerProfile || 'browser') as HeaderProfile,
+    customHeaders: (state.customHeaders || []).map(convertKeyValue),
+    captureRawTraffic: state.captureRawTraffic,
+    responseMemoryLimit: state.responseMemoryLimit,
+    requestPanelTab: (state.requestPanelTab || 'params') as 'params' | 'headers' | 'auth' | 'body',
     updatedAt: String(state.updatedAt),
   }
 }
 
+function convertCookie(c: models.Cookie): Cookie {
+  return {
+    id: c.id,
+    envId: c.envId,
+    domain: c.domain,
+    path: c.path,
+    name: c.name,
+    value: c.value,
+    hostOnly: c.hostOnly,
+    secure: c.secure,
+    httpOnly: c.httpOnly,
+    sameSite: c.sameSite,
+    expires: c.expires ? String(c.expires) : null,
+    createdAt: String(c.createdAt),
+    updatedAt: String(c.updatedAt),
+  }
+}
+
+function convertCertificate(c: models.Certificate): Certificate {
+  return {
+    id: c.id,
+    name: c.name,
+    type: c.type as 'client' | 'ca',
+    host: c.host,
+    certPath: c.certPath,
+    keyPath: c.keyPath,
+    subject: c.subject,
+    issuer: c.issuer,
+    notAfter: c.notAfter ? String(c.notAfter) : null,
+    enabled: c.enabled,
+    createdAt: String(c.createdAt),
+    updatedAt: String(c.updatedAt),
+  }
+}
+
 function convertSidebarState(ss: models.SidebarState): SidebarState {
   return {
     id: ss.id,
@@ -160,25 +389,55 @@ function convertTabSession(ts: models.TabSession): TabSession {
     sortOrder: ts.sortOrder,
     isActive: ts.isActive,
     isDirty: ts.isDirty,
-    method: ts.method,
+    method: methodOf(ts.kind, ts.method),
     url: ts.url,
     headers: (ts.headers || []).map(convertKeyValue),
     params: (ts.params || []).map(convertKeyValue),
     body: ts.body,
     bodyType: ts.bodyType,
+    auth: (ts.auth as AuthConfig) ?? null,
     createdAt: String(ts.createdAt),
     updatedAt: String(ts.updatedAt),
   }
 }
 
+function convertGraphQLSchema(schema: models.GraphQLSchema): GraphQLSchema {
+  return {
+    id: schema.id,
+    endpoint: schema.endpoint,
+    schema: schema.schema,
+    fetchedAt: String(schema.fetchedAt),
+  }
+}
+
 function convertResponse(res: models.Response): ResponseType {
   return {
     statusCode: res.statusCode,
     status: res.status,
-    headers: res.headers || {},
+    url: res.url,
+    headers: (res.headers || []).map(convertKeyValue),
     body: res.body,
     size: res.size,
     duration: res.duration,
+    timing: res.timing ?? null,
+    bodyEncoding: res.bodyEncoding || '',
+    truncated: res.truncated || false,
+    bodyHandle: res.bodyHandle || '',
+    events: (res.events || []).map(e => ({ id: e.id, event: e.event, data: e.data, time: e.time })),
+    redirects: (res.redirects || []).map(hop => ({
+      method: hop.method,
+      url: hop.url,
+      statusCode: hop.statusCode,
+      status: hop.status,
+      location: hop.location,
+      headers: (hop.headers || []).map(convertKeyValue),
+      sentHeaders: (hop.sentHeaders || []).map(convertKeyValue),
+      timing: hop.timing ?? null,
+      raw: hop.raw ?? null,
+    })),
+    sentHeaders: (res.sentHeaders || []).map(convertKeyValue),
+    raw: res.raw ?? null,
+    resolved: res.resolved ? convertResolvedRequest(res.resolved) : null,
   }
 }
 
@@ -267,12 +526,15 @@ export const api = {
       collectionId: request.collectionId || 0,
       folderId: request.folderId,
       name: request.name || 'Untitled',
-      method: request.method || 'GET',
+      kind: kindOf(request.method),
+      method: storedMethod(request.method),
       url: request.url || '',
       headers: (request.headers || []).map(h => models.KeyValue.createFrom(h)),
       params: (request.params || []).map(p => models.KeyValue.createFrom(p)),
       body: request.body || '',
       bodyType: request.bodyType || 'none',
+      settings: request.settings ?? null,
+      auth: request.auth ?? null,
       sortOrder: request.sortOrder || 0,
     })
     const result = await RequestHandler.Create(req)
@@ -285,12 +547,15 @@ export const api = {
       collectionId: request.collectionId,
       folderId: request.folderId,
       name: request.name,
-      method: request.method,
+      kind: kindOf(request.method),
+      method: storedMethod(request.method),
       url: request.url,
       headers: request.headers.map(h => models.KeyValue.createFrom(h)),
       params: request.params.map(p => models.KeyValue.createFrom(p)),
       body: request.body,
       bodyType: request.bodyType,
+      settings: request.settings ?? null,
+      auth: request.auth ?? null,
       sortOrder: request.sortOrder,
     })
     await RequestHandler.Update(req)
@@ -311,36 +576,255 @@ export const api = {
   },
 
   // Execute request
-  async executeRequest(params: {
+  async executeRequest(params: ExecuteParams): Promise<ResponseType> {
+    const result = await RequestHandler.Execute(executeParams(params))
+    return convertResponse(result)
+  },
+
+  // The request as executeRequest would send it, with the names that could not be resolved
+  async previewRequest(params: ExecuteParams): Promise<ResolvedRequest> {
+    const result = await RequestHandler.Preview(executeParams(params))
+    return convertResolvedRequest(result)
+  },
+
+  async cancelRequest(tabId: string): Promise<void> {
+    await RequestHandler.CancelRequest(tabId)
+  },
+
+  // WebSocket sessions, one per tab; their messages arrive as websocket:message events
+  async wsConnect(params: {
     tabId: string
-    method: string
     url: string
+    params?: KeyValue[] // set in the query of url
     headers: KeyValue[]
-    body: string
-    bodyType: string
     timeout: number
-  }): Promise<ResponseType> {
-    const execParams = handlers.ExecuteRequestParams.createFrom({
+    settings?: RequestSettings | null
+    environmentId?: number | null
+  }): Promise<WebSocketHandshake> {
+    const result = await WebSocketHandler.Connect(handlers.ConnectParams.createFrom({
       tabId: params.tabId,
-      method: params.method,
       url: params.url,
+      params: (params.params ?? []).map(p => models.KeyValue.createFrom(p)),
       headers: params.headers.map(h => models.KeyValue.createFrom(h)),
-      body: params.body,
-      bodyType: params.bodyType,
       timeout: params.timeout,
-    })
-    const result = await RequestHandler.Execute(execParams)
-    return convertResponse(result)
+      settings: params.settings ?? null,
+      environmentId: params.environmentId ?? null,
+    }))
+    return {
+      statusCode: result.statusCode,
+      status: result.status,
+      headers: (result.headers || []).map(convertKeyValue),
+      subprotocol: result.subprotocol,
+    }
   },
 
-  async cancelRequest(tabId: string): Promise<void> {
-    await RequestHandler.CancelRequest(tabId)
+  // messageType is 'text', 'json' or 'binary' (data in base64)
+  // data may reference {{variables}} of the environment environmentId
+  async wsSend(tabId: string, messageType: string, data: string, environmentId: number | null): Promise<void> {
+    await WebSocketHandler.Send(tabId, messageType, data, environmentId)
+  },
+
+  async wsPing(tabId: string, data = ''): Promise<void> {
+    await WebSocketHandler.Ping(tabId, data)
+  },
+
+  async wsClose(tabId: string, code = 1000, reason = ''): Promise<void> {
+    await WebSocketHandler.Close(tabId, code, reason)
+  },
+
+  async wsRelease(tabId: string): Promise<void> {
+    await WebSocketHandler.Release(tabId)
+  },
+
+  async getWebSocketTranscript(tabId: string): Promise<WebSocketMessage[]> {
+    return ((await WebSocketHandler.GetTranscript(tabId)) || []) as WebSocketMessage[]
+  },
+
+  async saveWebSocketTranscript(tabId: string, path: string): Promise<void> {
+    await WebSocketHandler.SaveTranscript(tabId, path)
+  },
+
+  // GraphQL schemas, fetched by introspection and cached per endpoint
+  async introspectGraphQL(params: {
+    url: string
+    headers: KeyValue[]
+    timeout: number
+    refresh: boolean
+    settings?: RequestSettings | null
+    environmentId?: number | null
+  }): Promise<GraphQLSchema> {
+    const result = await GraphQLHandler.Introspect(handlers.IntrospectParams.createFrom({
+      url: params.url,
+      headers: params.headers.map(h => models.KeyValue.createFrom(h)),
+      timeout: params.timeout,
+      refresh: params.refresh,
+      settings: params.settings ?? null,
+      environmentId: params.environmentId ?? null,
+    }))
+    return convertGraphQLSchema(result)
+  },
+
+  // Endpoint URLs may reference {{variables}} of the environment environmentId
+  async getGraphQLSchema(url: string, environmentId: number | null): Promise<GraphQLSchema | null> {
+    const result = await GraphQLHandler.GetSchema(url, environmentId)
+    return result ? convertGraphQLSchema(result) : null
+  },
+
+  async clearGraphQLSchema(url: string, environmentId: number | null): Promise<void> {
+    await GraphQLHandler.ClearSchema(url, environmentId)
+  },
+
+  async validateGraphQL(url: string, query: string, environmentId: number | null): Promise<GraphQLError[]> {
+    return ((await GraphQLHandler.Validate(url, query, environmentId)) || []) as GraphQLError[]
+  },
+
+  // gRPC calls; messages of streaming calls arrive as grpc:message events
+  async grpcListServices(params: GRPCParams): Promise<GRPCServiceInfo[]> {
+    return ((await GRPCHandler.ListServices(grpcParams(params))) || []) as GRPCServiceInfo[]
+  },
+
+  async grpcInvoke(params: GRPCParams): Promise<GRPCResponse> {
+    const result = await GRPCHandler.Invoke(grpcParams(params))
+    return {
+      code: result.code,
+      status: result.status,
+      message: result.message,
+      headers: (result.headers || []).map(convertKeyValue),
+      trailers: (result.trailers || []).map(convertKeyValue),
+      messages: result.messages || [],
+      duration: result.duration,
+    }
+  },
+
+  // OAuth 2.0 tokens, cached per environment; interactive grants emit oauth2:prompt
+  async getOAuth2Token(params: OAuth2Params): Promise<OAuth2Token> {
+    return convertOAuth2Token(await OAuth2Handler.GetToken(oauth2Params(params)))
+  },
+
+  async getCachedOAuth2Token(params: OAuth2Params): Promise<OAuth2Token | null> {
+    const result = await OAuth2Handler.GetCachedToken(oauth2Params(params))
+    return result ? convertOAuth2Token(result) : null
+  },
+
+  async clearOAuth2Token(params: OAuth2Params): Promise<void> {
+    await OAuth2Handler.ClearToken(oauth2Params(params))
   },
 
   async setUseSystemProxy(useProxy: boolean): Promise<void> {
     await RequestHandler.SetUseSystemProxy(useProxy)
   },
 
+  async setManualProxy(config: ProxyConfig): Promise<void> {
+    await RequestHandler.SetManualProxy(models.ProxyConfig.createFrom(config))
+  },
+
+  async setReuseConnections(reuse: boolean): Promise<void> {
+    await RequestHandler.SetReuseConnections(reuse)
+  },
+
+  async setRedirectPolicy(policy: RedirectPolicy): Promise<void> {
+    await RequestHandler.SetRedirectPolicy(models.RedirectPolicy.createFrom(policy))
+  },
+
+  async flushConnections(): Promise<void> {
+    await RequestHandler.FlushConnections()
+  },
+
+  async setTLSProfile(profile: TLSProfile): Promise<void> {
+    await RequestHandler.SetTLSProfile(profile)
+  },
+
+  async setDefaultHeaders(profile: HeaderProfile, custom: KeyValue[]): Promise<void> {
+    await RequestHandler.SetDefaultHeaders(profile, custom.map(h => models.KeyValue.createFrom(h)))
+  },
+
+  async setCookieJarEnabled(enabled: boolean): Promise<void> {
+    await RequestHandler.SetCookieJarEnabled(enabled)
+  },
+
+  async setScopeCookiesByEnvironment(scoped: boolean): Promise<void> {
+    await RequestHandler.SetScopeCookiesByEnvironment(scoped)
+  },
+
+  async setCaptureRawTraffic(capture: boolean): Promise<void> {
+    await RequestHandler.SetCaptureRawTraffic(capture)
+  },
+
+  async setResponseMemoryLimit(limit: number): Promise<void> {
+    await RequestHandler.SetResponseMemoryLimit(limit)
+  },
+
+  // Writes the full response body, including the part of a large body that was not loaded
+  async saveResponseBody(response: ResponseType, path: string): Promise<void> {
+    await RequestHandler.SaveResponseBody(models.Response.createFrom(response), path)
+  },
+
+  // Certificate operations (changes apply to new connections)
+  async getCertificates(): Promise<Certificate[]> {
+    const certs = await CertificateHandler.GetAll()
+    return (certs || []).map(convertCertificate)
+  },
+
+  async importCertificatePEM(name: string, host: string, certFile: string, keyFile = ''): Promise<Certificate> {
+    const cert = await CertificateHandler.ImportPEM(name, host, certFile, keyFile)
+    await RequestHandler.FlushConnections()
+    return convertCertificate(cert)
+  },
+
+  async importCertificatePKCS12(name: string, host: string, file: string, password: string): Promise<Certificate> {
+    const cert = await CertificateHandler.ImportPKCS12(name, host, file, password)
+    await RequestHandler.FlushConnections()
+    return convertCertificate(cert)
+  },
+
+  async importCA(name: string, host: string, file: string): Promise<Certificate> {
+    const cert = await CertificateHandler.ImportCA(name, host, file)
+    await RequestHandler.FlushConnections()
+    return convertCertificate(cert)
+  },
+
+  async updateCertificate(cert: Certificate): Promise<void> {
+    await CertificateHandler.Update(models.Certificate.createFrom(cert))
+    await RequestHandler.FlushConnections()
+  },
+
+  async deleteCertificate(id: number): Promise<void> {
+    await CertificateHandler.Delete(id)
+    await RequestHandler.FlushConnections()
+  },
+
+  // Cookie jar operations (envId 0 is the shared jar)
+  async getCookies(envId: number): Promise<Cookie[]> {
+    const cookies = await CookieHandler.GetAll(envId)
+    return (cookies || []).map(convertCookie)
+  },
+
+  async getCookiesByDomain(envId: number, domain: string): Promise<Cookie[]> {
+    const cookies = await CookieHandler.GetByDomain(envId, domain)
+    return (cookies || []).map(convertCookie)
+  },
+
+  async createCookie(cookie: Partial<Cookie>): Promise<Cookie> {
+    const result = await CookieHandler.Create(models.Cookie.createFrom(cookie))
+    return convertCookie(result)
+  },
+
+  async updateCookie(cookie: Cookie): Promise<void> {
+    await CookieHandler.Update(models.Cookie.createFrom(cookie))
+  },
+
+  async deleteCookie(id: number): Promise<void> {
+    await CookieHandler.Delete(id)
+  },
+
+  async deleteCookiesByDomain(envId: number, domain: string): Promise<void> {
+    await CookieHandler.DeleteByDomain(envId, domain)
+  },
+
+  async clearCookies(envId: number): Promise<void> {
+    await CookieHandler.Clear(envId)
+  },
+
   // Environment operations
   async getEnvironments(): Promise<Environment[]> {
     const envs = await EnvironmentHandler.GetAll()
@@ -361,6 +845,7 @@ export const api = {
       id: env.id,
       name: env.name,
       variables: env.variables.map(v => models.Variable.createFrom(v)),
+      proxy: env.proxy ? models.ProxyConfig.createFrom(env.proxy) : null,
     })
     await EnvironmentHandler.Update(e)
   },
@@ -404,6 +889,11 @@ export const api = {
       responseHeaders: history.responseHeaders || '',
       responseBody: history.responseBody || '',
       durationMs: history.durationMs,
+      timing: history.timing || '',
+      redirects: history.redirects || '',
+      raw: history.raw || '',
+      events: history.events || '',
+      variables: history.variables || '',
     })
     const result = await HistoryHandler.Create(h)
     return convertHistory(result)
@@ -446,12 +936,14 @@ export const api = {
       sortOrder: session.sortOrder || 0,
       isActive: session.isActive || false,
       isDirty: session.isDirty || false,
-      method: session.method || 'GET',
+      kind: kindOf(session.method),
+      method: storedMethod(session.method),
       url: session.url || '',
       headers: (session.headers || []).map(h => models.KeyValue.createFrom(h)),
       params: (session.params || []).map(p => models.KeyValue.createFrom(p)),
       body: session.body || '',
       bodyType: session.bodyType || 'none',
+      auth: session.auth ?? null,
     })
     await AppStateHandler.SaveTabSession(ts)
   },
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref, computed, watch } from 'vue'
-import type { AppState, SidebarState } from '@/types'
+import type { AppState, SidebarState, TLSProfile, HeaderProfile, KeyValue, ProxyConfig } from '@/types'
 import { WindowSetBackgroundColour } from '../../wailsjs/runtime/runtime'
 
 // Import api lazily to avoid circular dependency
@@ -23,7 +23,20 @@ export const useAppStateStore = defineStore('appState', () => {
   const requestTimeout = ref(30)
   const autoLocateSidebar = ref(true)
   const useSystemProxy = ref(true)
-  const requestPanelTab = ref<'params' | 'headers' | 'body'>('params')
+  const manualProxy = ref<ProxyConfig>({ enabled: false, http: '', https: '', socks: '', pac: '', autoDetect: false, username: '', password: '', bypass: [] })
+  const reuseConnections = ref(true)
+  const followRedirects = ref(true)
+  const maxRedirects = ref(10)
+  const preserveRedirectMethod = ref(false)
+  const stripRedirectAuth = ref(true)
+  const cookieJarEnabled = ref(true)
+  const scopeCookiesByEnv = ref(false)
+  const tlsProfile = ref<TLSProfile>('chrome')
+  const headerProfile = ref<HeaderProfile>('browser')
+  const customHeaders = ref<KeyValue[]>([])
+  const captureRawTraffic = ref(false)
+  const responseMemoryLimit = ref(10 * 1024 * 1024)
+  const requestPanelTab = ref<'params' | 'headers' | 'auth' | 'body'>('params')
   const modalOpenCount = ref(0)
   
   // Window state
@@ -163,7 +176,20 @@ export const useAppStateStore = defineStore('appState', () => {
     requestTimeout.value = state.requestTimeout
     autoLocateSidebar.value = state.autoLocateSidebar
     useSystemProxy.value = state.useSystemProxy
-    requestPanelTab.value = (state.requestPanelTab as 'params' | 'headers' | 'body') || 'params'
+    manualProxy.value = state.manualProxy
+    reuseConnections.value = state.reuseConnections
+    followRedirects.value = state.followRedirects
+    maxRedirects.value = state.maxRedirects
+    preserveRedirectMethod.value = state.preserveRedirectMethod
+    stripRedirectAuth.value = state.stripRedirectAuth
+    cookieJarEnabled.value = state.cookieJarEnabled
+    scopeCookiesByEnv.value = state.scopeCookiesByEnv
+    tlsProfile.value = state.tlsProfile || 'chrome'
+    headerProfile.value = state.headerProfile || 'browser'
+    customHeaders.value = state.customHeaders || []
+    captureRawTraffic.value = state.captureRawTraffic
+    responseMemoryLimit.value = state.responseMemoryLimit || 10 * 1024 * 1024
+    requestPanelTab.value = (state.requestPanelTab as 'params' | 'headers' | 'auth' | 'body') || 'params'
     
     // Load window state
     windowWidth.value = state.windowWidth || 1200
@@ -189,6 +215,19 @@ export const useAppStateStore = defineStore('appState', () => {
       requestTimeout: requestTimeout.value,
       autoLocateSidebar: autoLocateSidebar.value,
       useSystemProxy: useSystemProxy.value,
+      manualProxy: manualProxy.value,
+      reuseConnections: reuseConnections.value,
+      followRedirects: followRedirects.value,
+      maxRedirects: maxRedirects.value,
+      preserveRedirectMethod: preserveRedirectMethod.value,
+      stripRedirectAuth: stripRedirectAuth.value,
+      cookieJarEnabled: cookieJarEnabled.value,
+      scopeCookiesByEnv: scopeCookiesByEnv.value,
+      tlsProfile: tlsProfile.value,
+      headerProfile: headerProfile.value,
+      customHeaders: customHeaders.value,
+      captureRawTraffic: captureRawTraffic.value,
+      responseMemoryLimit: responseMemoryLimit.value,
       requestPanelTab: requestPanelTab.value,
       windowWidth: windowWidth.value,
       windowHeight: windowHeight.value,
@@ -206,6 +245,19 @@ export const useAppStateStore = defineStore('appState', () => {
     requestTimeout,
     autoLocateSidebar,
     useSystemProxy,
+    manualProxy,
+    reuseConnections,
+    followRedirects,
+    maxRedirects,
+    preserveRedirectMethod,
+    stripRedirectAuth,
+    cookieJarEnabled,
+    scopeCookiesByEnv,
+    tlsProfile,
+    headerProfile,
+    customHeaders,
+    captureRawTraffic,
+    responseMemoryLimit,
     requestPanelTab,
     modalOpenCount,
     isModalOpen,
@@ -14,37 +14,6 @@ export const useEnvironmentStore = defineStore('environment', () => {
     return environments.value.find(e => e.id === activeEnvId.value) || null
   })
 
-  // Get all variables (global + active env)
-  const allVariables = computed(() => {
-    const vars = new Map<string, string>()
-    
-    // Global variables first
-    for (const v of globalVariables.value) {
-      vars.set(v.key, v.value)
-    }
-    
-    // Environment variables override
-    if (activeEnvironment.value) {
-      for (const v of activeEnvironment.value.variables) {
-        vars.set(v.key, v.value)
-      }
-    }
-    
-    return vars
-  })
-
-  // Resolve a variable
-  function resolveVariable(name: string): string | undefined {
-    return allVariables.value.get(name)
-  }
-
-  // Replace variables in text
-  function replaceVariables(text: string): string {
-    return text.replace(/\{\{(\w+)\}\}/g, (match, name) => {
-      return resolveVariable(name) ?? match
-    })
-  }
-
   // Set environments
   function setEnvironments(envs: Environment[]) {
     environments.value = envs
@@ -90,9 +59,6 @@ export const useEnvironmentStore = defineStore('environment', () => {
     activeEnvId,
     loading,
     activeEnvironment,
-    allVariables,
-    resolveVariable,
-    replaceVariables,
     setEnvironments,
     setGlobalVariables,
     setActiveEnv,
@@ -1,6 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
-import type { Response, ResponseState } from '@/types'
+import type { GRPCMessage, GRPCResponse, Response, ResponseState, ServerSentEvent, TransferProgress } from '@/types'
 
 export const useResponseStore = defineStore('response', () => {
   // Map of tabId to response state
@@ -14,10 +14,38 @@ export const useResponseStore = defineStore('response', () => {
     responses.value.set(tabId, { status: 'loading' })
   }
 
+  // Progress only updates a request that is still running
+  function setProgress(progress: TransferProgress) {
+    const state = getResponse(progress.tabId)
+    if (state.status === 'loading') {
+      responses.value.set(progress.tabId, { ...state, progress })
+    }
+  }
+
+  // Events of a stream still being read are shown as they arrive
+  function addEvent(event: ServerSentEvent) {
+    const state = getResponse(event.tabId!)
+    if (state.status === 'loading') {
+      responses.value.set(event.tabId!, { ...state, events: [...(state.events || []), event] })
+    }
+  }
+
+  // Messages of a gRPC stream still being read are shown as they arrive
+  function addGRPCMessage(message: GRPCMessage) {
+    const state = getResponse(message.tabId!)
+    if (state.status === 'loading') {
+      responses.value.set(message.tabId!, { ...state, grpcMessages: [...(state.grpcMessages || []), message] })
+    }
+  }
+
   function setSuccess(tabId: string, response: Response) {
     responses.value.set(tabId, { status: 'success', response })
   }
 
+  function setGRPCResponse(tabId: string, response: GRPCResponse) {
+    responses.value.set(tabId, { status: 'grpc', response })
+  }
+
   function setError(tabId: string, message: string) {
     responses.value.set(tabId, { status: 'error', message })
   }
@@ -46,7 +74,11 @@ export const useResponseStore = defineStore('response', () => {
     responses,
     getResponse,
     setLoading,
+    setProgress,
+    addEvent,
+    addGRPCMessage,
     setSuccess,
+    setGRPCResponse,
     setError,
     setCancelled,
     setTimeout,
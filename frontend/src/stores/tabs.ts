@@ -1,6 +1,7 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import type { Tab, KeyValue } from '@/types'
+import type { Tab, KeyValue, AuthConfig } from '@/types'
+import { useWebSocketStore } from './websocket'
 
 // Generate unique ID
 function generateId(): string {
@@ -54,6 +55,9 @@ function computeDirty(tab: Tab): boolean {
   if (tab.body !== orig.body) return true
   if (tab.bodyType !== orig.bodyType) return true
 
+  // Compare auth; unset and null both inherit
+  if (JSON.stringify(tab.auth ?? null) !== JSON.stringify(orig.auth ?? null)) return true
+
   return false
 }
 
@@ -91,7 +95,7 @@ export const useTabsStore = defineStore('tabs', () => {
   }
 
   // Open a request in a new tab or switch to existing
-  function openRequest(requestId: number, title: string, method: string, url: string, headers: KeyValue[], params: KeyValue[], body: string, bodyType: string) {
+  function openRequest(requestId: number, title: string, method: string, url: string, headers: KeyValue[], params: KeyValue[], body: string, bodyType: string, auth: AuthConfig | null = null) {
     // Check if already open
     const existing = tabs.value.find(t => t.requestId === requestId)
     if (existing) {
@@ -99,7 +103,7 @@ export const useTabsStore = defineStore('tabs', () => {
       return existing
     }
 
-    const originalState = { method, url, headers: [...headers], params: [...params], body, bodyType }
+    const originalState = { method, url, headers: [...headers], params: [...params], body, bodyType, auth }
 
     // Check if current tab is preview and reuse it
     const previewTab = tabs.value.find(t => t.isPreview)
@@ -112,6 +116,7 @@ export const useTabsStore = defineStore('tabs', () => {
       previewTab.params = params
       previewTab.body = body
       previewTab.bodyType = bodyType
+      previewTab.auth = auth
       previewTab.isDirty = false
       previewTab.isPreview = false
       previewTab.originalState = originalState
@@ -130,6 +135,7 @@ export const useTabsStore = defineStore('tabs', () => {
       params,
       body,
       bodyType,
+      auth,
       isDirty: false,
       isPreview: false,
       originalState,
@@ -140,7 +146,7 @@ export const useTabsStore = defineStore('tabs', () => {
   }
 
   // Preview a request (single-click)
-  function previewRequest(requestId: number, title: string, method: string, url: string, headers: KeyValue[], params: KeyValue[], body: string, bodyType: string) {
+  function previewRequest(requestId: number, title: string, method: string, url: string, headers: KeyValue[], params: KeyValue[], body: string, bodyType: string, auth: AuthConfig | null = null) {
     // Check if already open (pinned or preview)
     const existing = tabs.value.find(t => t.requestId === requestId)
     if (existing) {
@@ -148,7 +154,7 @@ export const useTabsStore = defineStore('tabs', () => {
       return existing
     }
 
-    const originalState = { method, url, headers: [...headers], params: [...params], body, bodyType }
+    const originalState = { method, url, headers: [...headers], params: [...params], body, bodyType, auth }
 
     // Find or create preview tab
     let previewTab = tabs.value.find(t => t.isPreview)
@@ -161,6 +167,7 @@ export const useTabsStore = defineStore('tabs', () => {
       previewTab.params = params
       previewTab.body = body
       previewTab.bodyType = bodyType
+      previewTab.auth = auth
       previewTab.isDirty = false
       previewTab.originalState = originalState
     } else {
@@ -174,6 +181,7 @@ export const useTabsStore = defineStore('tabs', () => {
         params,
         body,
         bodyType,
+        auth,
         isDirty: false,
         isPreview: true,
         originalState,
@@ -198,6 +206,7 @@ export const useTabsStore = defineStore('tabs', () => {
     if (index === -1) return
 
     tabs.value.splice(index, 1)
+    useWebSocketStore().release(tabId)
 
     if (tabs.value.length === 0) {
       // Add a new empty tab
@@ -245,6 +254,7 @@ export const useTabsStore = defineStore('tabs', () => {
         params: [...tab.params],
         body: tab.body,
         bodyType: tab.bodyType,
+        auth: tab.auth ?? null,
       }
     }
   }
@@ -316,6 +326,7 @@ export const useTabsStore = defineStore('tabs', () => {
       params: JSON.parse(JSON.stringify(tab.params)),
       body: tab.body,
       bodyType: tab.bodyType,
+      auth: tab.auth ? { ...tab.auth } : null,
       isDirty: false,
       isPreview: false,
       originalState: null, // No original state = new unsaved request
@@ -0,0 +1,65 @@
+import { defineStore } from 'pinia'
+import { ref } from 'vue'
+import { api } from '@/services/api'
+import type { WebSocketHandshake, WebSocketMessage, WebSocketState } from '@/types'
+
+export const useWebSocketStore = defineStore('websocket', () => {
+  // Map of tabId to the state of its WebSocket session
+  const sessions = ref<Map<string, WebSocketState>>(new Map())
+
+  function getSession(tabId: string): WebSocketState | undefined {
+    return sessions.value.get(tabId)
+  }
+
+  function setConnecting(tabId: string) {
+    sessions.value.set(tabId, { status: 'connecting', handshake: null, messages: [], error: '' })
+  }
+
+  function setOpen(tabId: string, handshake: WebSocketHandshake) {
+    const session = getSession(tabId)
+    sessions.value.set(tabId, { status: 'open', handshake, messages: session?.messages || [], error: '' })
+  }
+
+  function setError(tabId: string, error: string) {
+    const session = getSession(tabId)
+    sessions.value.set(tabId, { status: 'closed', handshake: null, messages: session?.messages || [], error })
+  }
+
+  // Messages arrive as websocket:message events, including those of the handshake
+  // before Connect returns; a close or error ends the session
+  function addMessage(message: WebSocketMessage) {
+    const tabId = message.tabId!
+    const session = getSession(tabId) || { status: 'connecting', handshake: null, messages: [], error: '' }
+    const ended = message.type === 'error' || (message.type === 'close' && message.direction === 'received')
+    sessions.value.set(tabId, {
+      ...session,
+      status: ended ? 'closed' : session.status,
+      messages: [...session.messages, message],
+    })
+  }
+
+  function setClosed(tabId: string) {
+    const session = getSession(tabId)
+    if (session) {
+      sessions.value.set(tabId, { ...session, status: 'closed' })
+    }
+  }
+
+  // Close the session of a tab being closed and forget it
+  function release(tabId: string) {
+    if (!sessions.value.has(tabId)) return
+    sessions.value.delete(tabId)
+    api.wsRelease(tabId).catch(err => console.error('Failed to close WebSocket:', err))
+  }
+
+  return {
+    sessions,
+    getSession,
+    setConnecting,
+    setOpen,
+    setError,
+    addMessage,
+    setClosed,
+    release,
+  }
+})
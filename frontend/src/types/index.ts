@@ -27,19 +27,227 @@ export interface Request {
   params: KeyValue[]
   body: string
   bodyType: string
+  settings: RequestSettings | null
+  auth: AuthConfig | null // null inherits the folder's or collection's
   sortOrder: number
   createdAt: string
   updatedAt: string
 }
 
+// Auth of a request, folder or collection
+export type AuthType = 'none' | 'inherit' | 'basic' | 'bearer' | 'apikey' | 'digest' | 'oauth2' | 'awsv4' | 'oauth1' | 'hmac' | 'jwt'
+
+// Credentials of a request, folder or collection; which fields apply depends on type
+export interface AuthConfig {
+  type: AuthType
+  username?: string // basic, digest
+  password?: string // basic, digest
+  token?: string // bearer
+  prefix?: string // bearer scheme, "Bearer" when empty
+  key?: string // apikey header or query parameter name
+  value?: string // apikey value
+  in?: 'header' | 'query' // apikey location, header when unset
+  oauth2?: OAuth2Config // oauth2
+  accessKey?: string // awsv4
+  secretKey?: string // awsv4
+  sessionToken?: string // awsv4 temporary credentials
+  region?: string // awsv4, such as us-east-1
+  service?: string // awsv4 signing name, such as execute-api or s3
+  oauth1?: OAuth1Config // oauth1
+  hmac?: HMACConfig // hmac
+  jwt?: JWTConfig // jwt
+}
+
+// Credentials of an OAuth 1.0a signed request
+export interface OAuth1Config {
+  consumerKey: string
+  consumerSecret?: string // HMAC and PLAINTEXT
+  privateKey?: string // RSA-SHA1 PEM key
+  token?: string
+  tokenSecret?: string
+  signatureMethod?: 'HMAC-SHA1' | 'HMAC-SHA256' | 'RSA-SHA1' | 'PLAINTEXT' // HMAC-SHA1 when unset
+  realm?: string
+  callback?: string // temporary credentials requests
+  verifier?: string // token requests
+  in?: 'header' | 'query' // header when unset
+}
+
+// HMAC over a canonical request string. stringToSign and value are templates of
+// {method}, {host}, {path}, {query}, {timestamp}, {nonce}, {bodySha256}, {keyId} and
+// {header:Name}; value may also use {signature}.
+export interface HMACConfig {
+  algorithm?: 'sha256' | 'sha1' | 'sha512' // sha256 when unset
+  keyId?: string
+  secret: string
+  stringToSign?: string
+  encoding?: 'hex' | 'base64' // hex when unset
+  header?: string // Authorization when empty
+  value?: string
+}
+
+// Defaults of the HMACConfig templates, as applied by the backend
+export const DEFAULT_HMAC_STRING_TO_SIGN = '{method}\n{path}\n{query}\n{timestamp}\n{nonce}\n{bodySha256}'
+export const DEFAULT_HMAC_VALUE = 'HMAC keyId="{keyId}", timestamp="{timestamp}", nonce="{nonce}", signature="{signature}"'
+
+// JSON Web Token minted for every request
+export interface JWTConfig {
+  algorithm: 'HS256' | 'RS256' | 'ES256'
+  secret?: string // HS256
+  privateKey?: string // RS256 and ES256 PEM key
+  keyId?: string // kid header
+  claims?: string // JSON object; iat is added unless set
+  expiresIn?: number // seconds until exp, added unless set; 0 adds none
+  in?: 'header' | 'query' // header when unset
+  prefix?: string // header scheme, "Bearer" when empty
+  param?: string // query parameter, jwt when empty
+}
+
+export type OAuth2GrantType = 'client_credentials' | 'password' | 'refresh_token' | 'device_code' | 'authorization_code'
+
+// How an OAuth 2.0 access token is obtained and sent
+export interface OAuth2Config {
+  grantType: OAuth2GrantType
+  tokenUrl: string
+  authUrl?: string // authorization_code
+  deviceAuthUrl?: string // device_code
+  redirectUri?: string // authorization_code loopback URI; a free port on 127.0.0.1 when empty
+  clientId: string
+  clientSecret?: string // empty for public clients
+  clientAuth?: 'basic' | 'body' // client secret location, basic when unset
+  scope?: string
+  username?: string // password
+  password?: string // password
+  refreshToken?: string // refresh_token
+  in?: 'header' | 'query' // token location, header when unset
+  prefix?: string // header scheme, "Bearer" when empty
+  param?: string // query parameter, access_token when empty
+}
+
+// OAuth 2.0 access token cached per environment
+export interface OAuth2Token {
+  id: number
+  envId: number
+  accessToken: string
+  refreshToken: string
+  tokenType: string
+  scope: string
+  expiresAt: string | null
+  createdAt: string
+  updatedAt: string
+}
+
+// What the user must open or enter during an interactive OAuth 2.0 grant
+export interface OAuth2Prompt {
+  tabId: string
+  authorizationUrl?: string
+  userCode?: string
+  verificationUri?: string
+  expiresIn?: number
+}
+
+// Redirect handling policy
+export interface RedirectPolicy {
+  follow: boolean
+  maxRedirects: number
+  preserveMethod: boolean
+  stripAuth: boolean
+}
+
+// Per-request overrides of app settings (unset fields inherit)
+export interface RequestSettings {
+  redirect?: RedirectPolicy
+  skipTlsVerify?: boolean
+  tlsProfile?: TLSProfile
+  headerProfile?: HeaderProfile
+  customHeaders?: KeyValue[]
+  captureRaw?: boolean
+  proxy?: ProxyConfig // overrides the environment's and app-wide proxy
+  sseReconnect?: boolean // reopen a closed event stream with Last-Event-ID
+  subprotocols?: string[] // offered in a WebSocket handshake
+}
+
+// Manually configured proxy (URLs may be http, https, socks5(h) or socks4(a)).
+// A PAC script or WPAD, when set, decides instead of the fixed proxies.
+export interface ProxyConfig {
+  enabled: boolean // false sends requests directly
+  http: string
+  https: string // empty uses the HTTP proxy
+  socks: string // used when there is no HTTP(S) proxy
+  pac: string // URL or file path of a proxy auto-config script
+  autoDetect: boolean // discover the PAC script through WPAD
+  username: string
+  password: string
+  bypass: string[] // hosts reached directly: names, *.domain, .domain, CIDR ranges, <local>
+}
+
+// Default header profile
+export type HeaderProfile = 'browser' | 'minimal' | 'none' | 'custom'
+
+// TLS client fingerprint profile
+export type TLSProfile = 'chrome' | 'firefox' | 'safari' | 'ios' | 'randomized' | 'go'
+
+// Intermediate redirect response
+export interface RedirectHop {
+  method: string
+  url: string
+  statusCode: number
+  status: string
+  location: string
+  headers: KeyValue[]
+  sentHeaders: KeyValue[]
+  timing: Timing | null
+  raw: RawCapture | null
+}
+
 // HTTP Response
 export interface Response {
   statusCode: number
   status: string
-  headers: Record<string, string>
+  url: string
+  headers: KeyValue[] // repeated names kept, e.g. several Set-Cookie
   body: string
-  size: number
+  size: number // of the full body, even when body is only a preview
   duration: number
+  timing: Timing | null
+  redirects: RedirectHop[]
+  bodyEncoding: string // 'base64' when the body is not UTF-8 text
+  truncated: boolean // body is a preview of a large body spooled to disk
+  bodyHandle: string // identifies the spooled body for saving
+  events: ServerSentEvent[] // of a text/event-stream response
+  sentHeaders: KeyValue[] // request headers actually written on the wire
+  raw: RawCapture | null // wire traffic, when capture mode is on
+  resolved: ResolvedRequest | null // the request as sent, variables replaced
+}
+
+// A request with its {{variables}} replaced
+export interface ResolvedRequest {
+  method: string
+  url: string // query parameters included
+  headers: KeyValue[]
+  body: string
+  auth: AuthConfig | null // inherited auth resolved too
+  unresolved: string[] // referenced names with no value, left as typed
+  cycles: string[] // names whose value refers back to itself
+  generated: Variable[] // values of dynamic variables, keyed by the reference as typed
+}
+
+// Raw wire traffic of a request (HTTP/2 is summarised frame by frame)
+export interface RawCapture {
+  protocol: string
+  request: string
+  response: string
+  truncated: boolean
+}
+
+// Per-phase request timing in milliseconds
+export interface Timing {
+  dns: number
+  connect: number
+  tls: number
+  ttfb: number
+  download: number
+  total: number
+  reused: boolean
 }
 
 // Collection
@@ -47,6 +255,7 @@ export interface Collection {
   id: number
   name: string
   description: string
+  auth: AuthConfig | null // shared by requests that inherit
   sortOrder: number
   createdAt: string
   updatedAt: string
@@ -57,6 +266,7 @@ export interface Folder {
   id: number
   collectionId: number
   name: string
+  auth: AuthConfig | null // null inherits the collection's
   sortOrder: number
   createdAt: string
   updatedAt: string
@@ -67,6 +277,7 @@ export interface Environment {
   id: number
   name: string
   variables: Variable[]
+  proxy: ProxyConfig | null // overrides the app-wide proxy for requests in this environment
   createdAt: string
   updatedAt: string
 }
@@ -90,6 +301,11 @@ export interface History {
   responseHeaders: string
   responseBody: string
   durationMs: number | null
+  timing: string
+  redirects: string
+  raw: string
+  events: string // JSON ServerSentEvent[] of an event stream
+  variables: string // JSON Variable[] of the dynamic values sent, such as {{$uuid}}
   createdAt: string
 }
 
@@ -110,7 +326,53 @@ export interface AppState {
   requestTimeout: number
   autoLocateSidebar: boolean
   useSystemProxy: boolean
-  requestPanelTab: 'params' | 'headers' | 'body'
+  manualProxy: ProxyConfig
+  reuseConnections: boolean
+  followRedirects: boolean
+  maxRedirects: number
+  preserveRedirectMethod: boolean
+  stripRedirectAuth: boolean
+  cookieJarEnabled: boolean
+  scopeCookiesByEnv: boolean
+  tlsProfile: TLSProfile
+  headerProfile: HeaderProfile
+  customHeaders: KeyValue[]
+  captureRawTraffic: boolean
+  responseMemoryLimit: number // bytes; larger bodies are spooled to disk
+  requestPanelTab: 'params' | 'headers' | 'auth' | 'body'
+  updatedAt: string
+}
+
+// Client certificate or trusted CA used for TLS connections
+export interface Certificate {
+  id: number
+  name: string
+  type: 'client' | 'ca'
+  host: string
+  certPath: string
+  keyPath: string
+  subject: string
+  issuer: string
+  notAfter: string | null
+  enabled: boolean
+  createdAt: string
+  updatedAt: string
+}
+
+// Cookie stored in the persistent cookie jar (envId 0 is the shared jar)
+export interface Cookie {
+  id: number
+  envId: number
+  domain: string
+  path: string
+  name: string
+  value: string
+  hostOnly: boolean
+  secure: boolean
+  httpOnly: boolean
+  sameSite: string
+  expires: string | null
+  createdAt: string
   updatedAt: string
 }
 
@@ -137,6 +399,7 @@ export interface TabSession {
   params: KeyValue[]
   body: string
   bodyType: string
+  auth: AuthConfig | null
   createdAt: string
   updatedAt: string
 }
@@ -164,6 +427,7 @@ export interface Tab {
   params: KeyValue[]
   body: string
   bodyType: string
+  auth?: AuthConfig | null // null inherits the folder's or collection's
   isDirty: boolean
   isPreview: boolean
   // Original state for comparing dirty (null if new/unsaved tab)
@@ -174,6 +438,7 @@ export interface Tab {
     params: KeyValue[]
     body: string
     bodyType: string
+    auth?: AuthConfig | null
   } | null
 }
 
@@ -186,16 +451,129 @@ export interface ExecuteRequestParams {
   body: string
   bodyType: string
   timeout: number
+  settings?: RequestSettings | null
+  auth?: AuthConfig | null
+  requestId?: number | null // saved request whose folder or collection auth is inherited
+}
+
+// Payload of a graphql body, stored as JSON in the request body
+export interface GraphQLBody {
+  query: string
+  variables: string // JSON object as typed; empty for none
+  operationName: string
+}
+
+// Introspected schema of a GraphQL endpoint, cached per endpoint
+export interface GraphQLSchema {
+  id: number
+  endpoint: string
+  schema: string // JSON of the introspection __schema
+  fetchedAt: string
+}
+
+// Problem found checking a query against a schema
+export interface GraphQLError {
+  message: string
+  line: number
+  column: number
+}
+
+// Payload of a gRPC request, kept as JSON in the request body
+export interface GRPCBody {
+  method: string // package.Service/Method
+  message: string // JSON; a JSON array of messages for client streaming
+  protoFiles?: string[] // without any the server's reflection service is asked
+  importPaths?: string[]
+  web?: boolean // call over gRPC-Web
+}
+
+export interface GRPCMethodInfo {
+  name: string
+  fullName: string // package.Service/Method
+  inputType: string
+  outputType: string
+  clientStreaming: boolean
+  serverStreaming: boolean
+  template: string // request message as JSON with every field at its default
+}
+
+export interface GRPCServiceInfo {
+  name: string
+  methods: GRPCMethodInfo[]
+}
+
+// Outcome of a gRPC call; a failed call has a non-zero code
+export interface GRPCResponse {
+  code: number
+  status: string // name of the code, such as "NotFound"
+  message: string
+  headers: KeyValue[]
+  trailers: KeyValue[]
+  messages: string[] // response messages as JSON
+  duration: number
+}
+
+// A response message of a streaming call, sent as it arrives
+export interface GRPCMessage {
+  tabId?: string
+  data: string
+  time: number // ms since the call started
 }
 
 // Response state
+// One event of a text/event-stream response
+export interface ServerSentEvent {
+  tabId?: string
+  id: string
+  event: string
+  data: string
+  time: number // ms since the request was sent
+}
+
+// One entry of a WebSocket session transcript
+export interface WebSocketMessage {
+  tabId?: string
+  direction: 'sent' | 'received' | '' // empty for open and error
+  type: 'text' | 'binary' | 'ping' | 'pong' | 'open' | 'close' | 'error'
+  data: string // base64 for binary frames; the reason for close
+  code?: number // close code
+  time: number // ms since the connection opened
+}
+
+// The server's answer to a WebSocket opening handshake
+export interface WebSocketHandshake {
+  statusCode: number
+  status: string
+  headers: KeyValue[]
+  subprotocol: string
+}
+
+// State of the WebSocket session of a tab
+export interface WebSocketState {
+  status: 'connecting' | 'open' | 'closed'
+  handshake: WebSocketHandshake | null
+  messages: WebSocketMessage[]
+  error: string
+}
+
+// Progress of sending a request body or receiving a response body
+export interface TransferProgress {
+  tabId: string
+  direction: 'upload' | 'download'
+  transferred: number
+  total: number // 0 when unknown
+  rate: number // bytes per second
+  done: boolean
+}
+
 export type ResponseState = 
   | { status: 'idle' }
-  | { status: 'loading' }
+  | { status: 'loading'; progress?: TransferProgress; events?: ServerSentEvent[]; grpcMessages?: GRPCMessage[] }
   | { status: 'cancelled' }
   | { status: 'timeout'; seconds: number }
   | { status: 'error'; message: string }
   | { status: 'success'; response: Response }
+  | { status: 'grpc'; response: GRPCResponse }
 
 // Toast types
 export type ToastType = 'success' | 'error' | 'warning' | 'info'
@@ -213,6 +591,12 @@ export type ModalType = 'confirm' | 'danger' | 'input' | 'select' | 'info'
 export const HTTP_METHODS = ['GET', 'POST', 'PUT', 'PATCH', 'DELETE', 'OPTIONS', 'HEAD'] as const
 export type HttpMethod = typeof HTTP_METHODS[number]
 
+// Tabs and saved requests of the websocket kind show this in place of an HTTP method
+export const WEBSOCKET_METHOD = 'WS'
+
+// Tabs and saved requests of the grpc kind show this in place of an HTTP method
+export const GRPC_METHOD = 'GRPC'
+
 // Body types
-export const BODY_TYPES = ['none', 'json', 'xml', 'text', 'form-data', 'x-www-form-urlencoded'] as const
+export const BODY_TYPES = ['none', 'json', 'xml', 'text', 'form-data', 'x-www-form-urlencoded', 'graphql'] as const
 export type BodyType = typeof BODY_TYPES[number]
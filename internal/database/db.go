@@ -14,16 +14,21 @@ const (
 	appDataDirName   = "postme"
 	dataDirName      = "data"
 	dbFileName       = "postme.db"
+	certsDirName     = "certs"
 	portableFlagName = "portable.flag"
 )
 
 // DB is the global database connection
 var DB *sqlx.DB
 
+// dataDir is the directory holding the database and other app data
+var dataDir string
+
 // Init initializes the database connection
 func Init() error {
 	// Get data directory path
-	dataDir, portable := getDataDirInfo()
+	var portable bool
+	dataDir, portable = getDataDirInfo()
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return err
 	}
@@ -276,3 +281,8 @@ func copyFileIfExists(src string, dst string) error {
 func GetDB() *sqlx.DB {
 	return DB
 }
+
+// GetCertificateDir returns the directory where imported certificates are stored
+func GetCertificateDir() string {
+	return filepath.Join(dataDir, certsDirName)
+}
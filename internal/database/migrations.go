@@ -1,6 +1,12 @@
 package database
 
-import "github.com/jmoiron/sqlx"
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
 
 // RunMigrations runs all database migrations
 func RunMigrations(db *sqlx.DB) error {
@@ -126,6 +132,62 @@ func RunMigrations(db *sqlx.DB) error {
 			FOREIGN KEY (request_id) REFERENCES requests(id) ON DELETE SET NULL
 		)`,
 
+		// Cookie jar table (env_id 0 is the shared jar)
+		`CREATE TABLE IF NOT EXISTS cookies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			env_id INTEGER NOT NULL DEFAULT 0,
+			domain TEXT NOT NULL,
+			path TEXT NOT NULL DEFAULT '/',
+			name TEXT NOT NULL,
+			value TEXT DEFAULT '',
+			host_only INTEGER DEFAULT 1,
+			secure INTEGER DEFAULT 0,
+			http_only INTEGER DEFAULT 0,
+			same_site TEXT DEFAULT '',
+			expires DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(env_id, domain, path, name)
+		)`,
+
+		// Certificates table (client certificates and trusted CAs)
+		`CREATE TABLE IF NOT EXISTS certificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'client',
+			host TEXT DEFAULT '',
+			cert_path TEXT NOT NULL,
+			key_path TEXT DEFAULT '',
+			subject TEXT DEFAULT '',
+			issuer TEXT DEFAULT '',
+			not_after DATETIME,
+			enabled INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Introspected GraphQL schemas, one per endpoint
+		`CREATE TABLE IF NOT EXISTS graphql_schemas (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			endpoint TEXT NOT NULL UNIQUE,
+			schema TEXT NOT NULL,
+			fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS oauth2_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			env_id INTEGER NOT NULL DEFAULT 0,
+			cache_key TEXT NOT NULL,
+			access_token TEXT NOT NULL,
+			refresh_token TEXT DEFAULT '',
+			token_type TEXT DEFAULT '',
+			scope TEXT DEFAULT '',
+			expires_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(env_id, cache_key)
+		)`,
+
 		// Initialize app_state with default values
 		`INSERT OR IGNORE INTO app_state (id) VALUES (1)`,
 
@@ -144,6 +206,33 @@ func RunMigrations(db *sqlx.DB) error {
 		`ALTER TABLE app_state ADD COLUMN use_system_proxy INTEGER DEFAULT 1`,
 		`ALTER TABLE app_state ADD COLUMN request_panel_tab TEXT DEFAULT 'params'`,
 		`ALTER TABLE app_state ADD COLUMN window_position_mode TEXT DEFAULT ''`,
+		`ALTER TABLE history ADD COLUMN timing TEXT DEFAULT ''`,
+		`ALTER TABLE app_state ADD COLUMN reuse_connections INTEGER DEFAULT 1`,
+		`ALTER TABLE app_state ADD COLUMN follow_redirects INTEGER DEFAULT 1`,
+		`ALTER TABLE app_state ADD COLUMN max_redirects INTEGER DEFAULT 10`,
+		`ALTER TABLE app_state ADD COLUMN preserve_redirect_method INTEGER DEFAULT 0`,
+		`ALTER TABLE app_state ADD COLUMN strip_redirect_auth INTEGER DEFAULT 1`,
+		`ALTER TABLE history ADD COLUMN redirects TEXT DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN settings TEXT DEFAULT '{}'`,
+		`ALTER TABLE tab_sessions ADD COLUMN settings TEXT DEFAULT '{}'`,
+		`ALTER TABLE app_state ADD COLUMN cookie_jar_enabled INTEGER DEFAULT 1`,
+		`ALTER TABLE app_state ADD COLUMN scope_cookies_by_env INTEGER DEFAULT 0`,
+		`ALTER TABLE app_state ADD COLUMN tls_profile TEXT DEFAULT 'chrome'`,
+		`ALTER TABLE app_state ADD COLUMN header_profile TEXT DEFAULT 'browser'`,
+		`ALTER TABLE app_state ADD COLUMN custom_headers TEXT DEFAULT '[]'`,
+		`ALTER TABLE app_state ADD COLUMN capture_raw_traffic INTEGER DEFAULT 0`,
+		`ALTER TABLE history ADD COLUMN raw TEXT DEFAULT ''`,
+		`ALTER TABLE app_state ADD COLUMN manual_proxy TEXT DEFAULT '{}'`,
+		`ALTER TABLE environments ADD COLUMN proxy TEXT DEFAULT ''`,
+		`ALTER TABLE app_state ADD COLUMN response_memory_limit INTEGER DEFAULT 10485760`,
+		`ALTER TABLE history ADD COLUMN events TEXT DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN kind TEXT DEFAULT 'http'`,
+		`ALTER TABLE tab_sessions ADD COLUMN kind TEXT DEFAULT 'http'`,
+		`ALTER TABLE collections ADD COLUMN auth TEXT DEFAULT ''`,
+		`ALTER TABLE folders ADD COLUMN auth TEXT DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN auth TEXT DEFAULT ''`,
+		`ALTER TABLE tab_sessions ADD COLUMN auth TEXT DEFAULT ''`,
+		`ALTER TABLE history ADD COLUMN variables TEXT DEFAULT ''`,
 	}
 
 	for _, migration := range alterTableMigrations {
@@ -151,5 +240,71 @@ func RunMigrations(db *sqlx.DB) error {
 		db.Exec(migration)
 	}
 
+	return migrateHistoryHeaders(db)
+}
+
+// migrateHistoryHeaders converts response headers stored by older versions as a
+// name → value object into the ordered list used now. Old rows kept a single value
+// per name in no particular order, so names are sorted.
+func migrateHistoryHeaders(db *sqlx.DB) error {
+	var rows []struct {
+		ID              int64  `db:"id"`
+		ResponseHeaders string `db:"response_headers"`
+		Redirects       string `db:"redirects"`
+	}
+	err := db.Select(&rows, `
+		SELECT id, COALESCE(response_headers, '') AS response_headers, COALESCE(redirects, '') AS redirects
+		FROM history
+		WHERE response_headers LIKE '{%' OR redirects LIKE '%"headers":{%'
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		headers := row.ResponseHeaders
+		if list, ok := headerObjectToList([]byte(headers)); ok {
+			data, _ := json.Marshal(list)
+			headers = string(data)
+		}
+
+		redirects := row.Redirects
+		var hops []map[string]json.RawMessage
+		if json.Unmarshal([]byte(redirects), &hops) == nil {
+			for _, hop := range hops {
+				if list, ok := headerObjectToList(hop["headers"]); ok {
+					hop["headers"], _ = json.Marshal(list)
+				}
+			}
+			data, _ := json.Marshal(hops)
+			redirects = string(data)
+		}
+
+		if _, err := db.Exec(`UPDATE history SET response_headers = ?, redirects = ? WHERE id = ?`,
+			headers, redirects, row.ID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// headerObjectToList converts a JSON name → value object to a header list.
+// It reports false if data is not such an object.
+func headerObjectToList(data []byte) ([]models.KeyValue, bool) {
+	var headers map[string]string
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &headers) != nil {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]models.KeyValue, 0, len(names))
+	for _, name := range names {
+		list = append(list, models.KeyValue{Key: name, Value: headers[name], Enabled: true})
+	}
+	return list, true
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMigrateHistoryHeadersToList(t *testing.T) {
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// A row written by a version that stored headers as an object
+	_, err = db.Exec(`INSERT INTO history (method, url, response_headers, redirects) VALUES (?, ?, ?, ?)`,
+		"GET", "http://example.com",
+		`{"Vary":"Accept","Content-Type":"text/plain"}`,
+		`[{"url":"http://example.com/old","headers":{"Location":"/"},"timing":null}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrations run on every start and must leave converted rows alone
+	for i := 0; i < 2; i++ {
+		if err := RunMigrations(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var row struct {
+		ResponseHeaders string `db:"response_headers"`
+		Redirects       string `db:"redirects"`
+	}
+	if err := db.Get(&row, `SELECT response_headers, redirects FROM history`); err != nil {
+		t.Fatal(err)
+	}
+
+	wantHeaders := `[{"key":"Content-Type","value":"text/plain","enabled":true},{"key":"Vary","value":"Accept","enabled":true}]`
+	if row.ResponseHeaders != wantHeaders {
+		t.Errorf("response_headers = %s, want %s", row.ResponseHeaders, wantHeaders)
+	}
+	wantRedirects := `[{"headers":[{"key":"Location","value":"/","enabled":true}],"timing":null,"url":"http://example.com/old"}]`
+	if row.Redirects != wantRedirects {
+		t.Errorf("redirects = %s, want %s", row.Redirects, wantRedirects)
+	}
+}
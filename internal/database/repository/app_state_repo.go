@@ -25,11 +25,16 @@ func (r *AppStateRepository) Get() (*models.AppState, error) {
 	if err != nil {
 		return nil, err
 	}
+	json.Unmarshal([]byte(state.CustomHeadersJSON), &state.CustomHeaders)
+	json.Unmarshal([]byte(state.ManualProxyJSON), &state.ManualProxy)
 	return &state, nil
 }
 
 // Update updates the app state
 func (r *AppStateRepository) Update(state *models.AppState) error {
+	customHeadersJSON, _ := json.Marshal(state.CustomHeaders)
+	manualProxyJSON, _ := json.Marshal(state.ManualProxy)
+
 	_, err := r.db.Exec(`
 		UPDATE app_state SET
 			window_width = ?, window_height = ?, window_x = ?, window_y = ?,
@@ -37,13 +42,21 @@ func (r *AppStateRepository) Update(state *models.AppState) error {
 			window_maximized = ?, sidebar_open = ?, sidebar_width = ?,
 			layout_direction = ?, split_ratio = ?, theme = ?,
 			active_env_id = ?, request_timeout = ?, auto_locate_sidebar = ?,
-			use_system_proxy = ?, request_panel_tab = ?, updated_at = ?
+			use_system_proxy = ?, manual_proxy = ?, reuse_connections = ?,
+			follow_redirects = ?, max_redirects = ?, preserve_redirect_method = ?, strip_redirect_auth = ?,
+			cookie_jar_enabled = ?, scope_cookies_by_env = ?, tls_profile = ?,
+			header_profile = ?, custom_headers = ?, capture_raw_traffic = ?,
+			response_memory_limit = ?, request_panel_tab = ?, updated_at = ?
 		WHERE id = 1
 	`, state.WindowWidth, state.WindowHeight, state.WindowX, state.WindowY,
 		state.WindowPositionMode, state.WindowMaximized, state.SidebarOpen, state.SidebarWidth,
 		state.LayoutDirection, state.SplitRatio, state.Theme,
 		state.ActiveEnvID, state.RequestTimeout, state.AutoLocateSidebar,
-		state.UseSystemProxy, state.RequestPanelTab, time.Now())
+		state.UseSystemProxy, string(manualProxyJSON), state.ReuseConnections,
+		state.FollowRedirects, state.MaxRedirects, state.PreserveRedirectMethod, state.StripRedirectAuth,
+		state.CookieJarEnabled, state.ScopeCookiesByEnv, state.TLSProfile,
+		state.HeaderProfile, string(customHeadersJSON), state.CaptureRawTraffic,
+		state.ResponseMemoryLimit, state.RequestPanelTab, time.Now())
 	return err
 }
 
@@ -76,6 +89,8 @@ func (r *AppStateRepository) GetTabSessions() ([]models.TabSession, error) {
 	for i := range sessions {
 		json.Unmarshal([]byte(sessions[i].HeadersJSON), &sessions[i].Headers)
 		json.Unmarshal([]byte(sessions[i].ParamsJSON), &sessions[i].Params)
+		json.Unmarshal([]byte(sessions[i].SettingsJSON), &sessions[i].Settings)
+		json.Unmarshal([]byte(sessions[i].AuthJSON), &sessions[i].Auth)
 	}
 	return sessions, nil
 }
@@ -84,17 +99,23 @@ func (r *AppStateRepository) GetTabSessions() ([]models.TabSession, error) {
 func (r *AppStateRepository) SaveTabSession(session *models.TabSession) error {
 	headersJSON, _ := json.Marshal(session.Headers)
 	paramsJSON, _ := json.Marshal(session.Params)
+	settingsJSON, _ := json.Marshal(session.Settings)
+	authJSON, _ := json.Marshal(session.Auth)
+	kind := session.Kind
+	if kind == "" {
+		kind = models.RequestKindHTTP
+	}
 
 	_, err := r.db.Exec(`
-		INSERT INTO tab_sessions (tab_id, request_id, title, sort_order, is_active, is_dirty, method, url, headers, params, body, body_type)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tab_sessions (tab_id, request_id, title, sort_order, is_active, is_dirty, kind, method, url, headers, params, body, body_type, settings, auth)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(tab_id) DO UPDATE SET
 			request_id = ?, title = ?, sort_order = ?, is_active = ?, is_dirty = ?,
-			method = ?, url = ?, headers = ?, params = ?, body = ?, body_type = ?, updated_at = CURRENT_TIMESTAMP
+			kind = ?, method = ?, url = ?, headers = ?, params = ?, body = ?, body_type = ?, settings = ?, auth = ?, updated_at = CURRENT_TIMESTAMP
 	`, session.TabID, session.RequestID, session.Title, session.SortOrder, session.IsActive, session.IsDirty,
-		session.Method, session.URL, string(headersJSON), string(paramsJSON), session.Body, session.BodyType,
+		kind, session.Method, session.URL, string(headersJSON), string(paramsJSON), session.Body, session.BodyType, string(settingsJSON), string(authJSON),
 		session.RequestID, session.Title, session.SortOrder, session.IsActive, session.IsDirty,
-		session.Method, session.URL, string(headersJSON), string(paramsJSON), session.Body, session.BodyType)
+		kind, session.Method, session.URL, string(headersJSON), string(paramsJSON), session.Body, session.BodyType, string(settingsJSON), string(authJSON))
 	return err
 }
 
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// CertificateRepository handles certificate data access
+type CertificateRepository struct {
+	db *sqlx.DB
+}
+
+// NewCertificateRepository creates a new CertificateRepository
+func NewCertificateRepository(db *sqlx.DB) *CertificateRepository {
+	return &CertificateRepository{db: db}
+}
+
+// Create creates a new certificate
+func (r *CertificateRepository) Create(cert *models.Certificate) error {
+	result, err := r.db.Exec(`
+		INSERT INTO certificates (name, type, host, cert_path, key_path, subject, issuer, not_after, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, cert.Name, cert.Type, cert.Host, cert.CertPath, cert.KeyPath, cert.Subject, cert.Issuer, cert.NotAfter, cert.Enabled)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	cert.ID = id
+	return nil
+}
+
+// GetByID retrieves a certificate by ID
+func (r *CertificateRepository) GetByID(id int64) (*models.Certificate, error) {
+	var cert models.Certificate
+	err := r.db.Get(&cert, "SELECT * FROM certificates WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// GetAll retrieves all certificates
+func (r *CertificateRepository) GetAll() ([]models.Certificate, error) {
+	var certs []models.Certificate
+	err := r.db.Select(&certs, "SELECT * FROM certificates ORDER BY type, name")
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// GetEnabled retrieves all enabled certificates
+func (r *CertificateRepository) GetEnabled() ([]models.Certificate, error) {
+	var certs []models.Certificate
+	err := r.db.Select(&certs, "SELECT * FROM certificates WHERE enabled = 1 ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// Update updates the editable fields of a certificate
+func (r *CertificateRepository) Update(cert *models.Certificate) error {
+	_, err := r.db.Exec(`
+		UPDATE certificates SET name = ?, host = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`, cert.Name, cert.Host, cert.Enabled, time.Now(), cert.ID)
+	return err
+}
+
+// Delete deletes a certificate
+func (r *CertificateRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM certificates WHERE id = ?", id)
+	return err
+}
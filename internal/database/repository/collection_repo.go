@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/SoulTraitor/postme/internal/models"
@@ -19,10 +20,12 @@ func NewCollectionRepository(db *sqlx.DB) *CollectionRepository {
 
 // Create creates a new collection
 func (r *CollectionRepository) Create(collection *models.Collection) error {
+	authJSON, _ := json.Marshal(collection.Auth)
+
 	result, err := r.db.Exec(`
-		INSERT INTO collections (name, description, sort_order)
-		VALUES (?, ?, ?)
-	`, collection.Name, collection.Description, collection.SortOrder)
+		INSERT INTO collections (name, description, auth, sort_order)
+		VALUES (?, ?, ?, ?)
+	`, collection.Name, collection.Description, string(authJSON), collection.SortOrder)
 	if err != nil {
 		return err
 	}
@@ -42,6 +45,8 @@ func (r *CollectionRepository) GetByID(id int64) (*models.Collection, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	json.Unmarshal([]byte(collection.AuthJSON), &collection.Auth)
 	return &collection, nil
 }
 
@@ -52,15 +57,21 @@ func (r *CollectionRepository) GetAll() ([]models.Collection, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	for i := range collections {
+		json.Unmarshal([]byte(collections[i].AuthJSON), &collections[i].Auth)
+	}
 	return collections, nil
 }
 
 // Update updates a collection
 func (r *CollectionRepository) Update(collection *models.Collection) error {
+	authJSON, _ := json.Marshal(collection.Auth)
+
 	_, err := r.db.Exec(`
-		UPDATE collections SET name = ?, description = ?, sort_order = ?, updated_at = ?
+		UPDATE collections SET name = ?, description = ?, auth = ?, sort_order = ?, updated_at = ?
 		WHERE id = ?
-	`, collection.Name, collection.Description, collection.SortOrder, time.Now(), collection.ID)
+	`, collection.Name, collection.Description, string(authJSON), collection.SortOrder, time.Now(), collection.ID)
 	return err
 }
 
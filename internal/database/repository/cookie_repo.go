@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// CookieRepository handles cookie jar data access
+type CookieRepository struct {
+	db *sqlx.DB
+}
+
+// NewCookieRepository creates a new CookieRepository
+func NewCookieRepository(db *sqlx.DB) *CookieRepository {
+	return &CookieRepository{db: db}
+}
+
+// expiresParam stores expiry in UTC at second precision so it compares correctly as text
+func expiresParam(expires *time.Time) any {
+	if expires == nil {
+		return nil
+	}
+	return expires.UTC().Truncate(time.Second)
+}
+
+// Save inserts a cookie or replaces the one with the same env, domain, path and name
+func (r *CookieRepository) Save(cookie *models.Cookie) error {
+	_, err := r.db.Exec(`
+		INSERT INTO cookies (env_id, domain, path, name, value, host_only, secure, http_only, same_site, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(env_id, domain, path, name) DO UPDATE SET
+			value = ?, host_only = ?, secure = ?, http_only = ?, same_site = ?, expires = ?, updated_at = ?
+	`, cookie.EnvID, cookie.Domain, cookie.Path, cookie.Name, cookie.Value,
+		cookie.HostOnly, cookie.Secure, cookie.HttpOnly, cookie.SameSite, expiresParam(cookie.Expires),
+		cookie.Value, cookie.HostOnly, cookie.Secure, cookie.HttpOnly, cookie.SameSite, expiresParam(cookie.Expires), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return r.db.Get(&cookie.ID, `
+		SELECT id FROM cookies WHERE env_id = ? AND domain = ? AND path = ? AND name = ?
+	`, cookie.EnvID, cookie.Domain, cookie.Path, cookie.Name)
+}
+
+// Update updates a cookie by ID
+func (r *CookieRepository) Update(cookie *models.Cookie) error {
+	_, err := r.db.Exec(`
+		UPDATE cookies SET
+			domain = ?, path = ?, name = ?, value = ?, host_only = ?, secure = ?,
+			http_only = ?, same_site = ?, expires = ?, updated_at = ?
+		WHERE id = ?
+	`, cookie.Domain, cookie.Path, cookie.Name, cookie.Value, cookie.HostOnly, cookie.Secure,
+		cookie.HttpOnly, cookie.SameSite, expiresParam(cookie.Expires), time.Now(), cookie.ID)
+	return err
+}
+
+// GetByEnvID retrieves all cookies of a jar
+func (r *CookieRepository) GetByEnvID(envID int64) ([]models.Cookie, error) {
+	var cookies []models.Cookie
+	err := r.db.Select(&cookies, "SELECT * FROM cookies WHERE env_id = ? ORDER BY domain, path, created_at", envID)
+	if err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// GetByDomain retrieves the cookies of a jar stored for a domain
+func (r *CookieRepository) GetByDomain(envID int64, domain string) ([]models.Cookie, error) {
+	var cookies []models.Cookie
+	err := r.db.Select(&cookies, "SELECT * FROM cookies WHERE env_id = ? AND domain = ? ORDER BY path, created_at", envID, domain)
+	if err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// Delete deletes a cookie
+func (r *CookieRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM cookies WHERE id = ?", id)
+	return err
+}
+
+// DeleteByKey deletes the cookie with the given env, domain, path and name
+func (r *CookieRepository) DeleteByKey(envID int64, domain, path, name string) error {
+	_, err := r.db.Exec("DELETE FROM cookies WHERE env_id = ? AND domain = ? AND path = ? AND name = ?", envID, domain, path, name)
+	return err
+}
+
+// DeleteByDomain deletes all cookies of a jar stored for a domain
+func (r *CookieRepository) DeleteByDomain(envID int64, domain string) error {
+	_, err := r.db.Exec("DELETE FROM cookies WHERE env_id = ? AND domain = ?", envID, domain)
+	return err
+}
+
+// DeleteByEnvID deletes all cookies of a jar
+func (r *CookieRepository) DeleteByEnvID(envID int64) error {
+	_, err := r.db.Exec("DELETE FROM cookies WHERE env_id = ?", envID)
+	return err
+}
+
+// DeleteExpired deletes cookies that expired before now
+func (r *CookieRepository) DeleteExpired(now time.Time) error {
+	_, err := r.db.Exec("DELETE FROM cookies WHERE expires IS NOT NULL AND expires < ?", now.UTC().Truncate(time.Second))
+	return err
+}
@@ -23,9 +23,9 @@ func (r *EnvironmentRepository) Create(env *models.Environment) error {
 	variablesJSON, _ := json.Marshal(env.Variables)
 
 	result, err := r.db.Exec(`
-		INSERT INTO environments (name, variables)
-		VALUES (?, ?)
-	`, env.Name, string(variablesJSON))
+		INSERT INTO environments (name, variables, proxy)
+		VALUES (?, ?, ?)
+	`, env.Name, string(variablesJSON), proxyJSON(env.Proxy))
 	if err != nil {
 		return err
 	}
@@ -46,6 +46,7 @@ func (r *EnvironmentRepository) GetByID(id int64) (*models.Environment, error) {
 		return nil, err
 	}
 	json.Unmarshal([]byte(env.VariablesJSON), &env.Variables)
+	unmarshalProxy(env.ProxyJSON, &env.Proxy)
 	return &env, nil
 }
 
@@ -58,6 +59,7 @@ func (r *EnvironmentRepository) GetAll() ([]models.Environment, error) {
 	}
 	for i := range envs {
 		json.Unmarshal([]byte(envs[i].VariablesJSON), &envs[i].Variables)
+		unmarshalProxy(envs[i].ProxyJSON, &envs[i].Proxy)
 	}
 	return envs, nil
 }
@@ -67,12 +69,32 @@ func (r *EnvironmentRepository) Update(env *models.Environment) error {
 	variablesJSON, _ := json.Marshal(env.Variables)
 
 	_, err := r.db.Exec(`
-		UPDATE environments SET name = ?, variables = ?, updated_at = ?
+		UPDATE environments SET name = ?, variables = ?, proxy = ?, updated_at = ?
 		WHERE id = ?
-	`, env.Name, string(variablesJSON), time.Now(), env.ID)
+	`, env.Name, string(variablesJSON), proxyJSON(env.Proxy), time.Now(), env.ID)
 	return err
 }
 
+// proxyJSON encodes an environment's proxy override; no override is stored as an empty string
+func proxyJSON(proxy *models.ProxyConfig) string {
+	if proxy == nil {
+		return ""
+	}
+	data, _ := json.Marshal(proxy)
+	return string(data)
+}
+
+// unmarshalProxy decodes an environment's proxy override, leaving proxy nil when there is none
+func unmarshalProxy(data string, proxy **models.ProxyConfig) {
+	if data == "" {
+		return
+	}
+	var config models.ProxyConfig
+	if json.Unmarshal([]byte(data), &config) == nil {
+		*proxy = &config
+	}
+}
+
 // Delete deletes an environment
 func (r *EnvironmentRepository) Delete(id int64) error {
 	_, err := r.db.Exec("DELETE FROM environments WHERE id = ?", id)
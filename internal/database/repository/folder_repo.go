@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/SoulTraitor/postme/internal/models"
@@ -19,10 +20,12 @@ func NewFolderRepository(db *sqlx.DB) *FolderRepository {
 
 // Create creates a new folder
 func (r *FolderRepository) Create(folder *models.Folder) error {
+	authJSON, _ := json.Marshal(folder.Auth)
+
 	result, err := r.db.Exec(`
-		INSERT INTO folders (collection_id, name, sort_order)
-		VALUES (?, ?, ?)
-	`, folder.CollectionID, folder.Name, folder.SortOrder)
+		INSERT INTO folders (collection_id, name, auth, sort_order)
+		VALUES (?, ?, ?, ?)
+	`, folder.CollectionID, folder.Name, string(authJSON), folder.SortOrder)
 	if err != nil {
 		return err
 	}
@@ -42,6 +45,8 @@ func (r *FolderRepository) GetByID(id int64) (*models.Folder, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	json.Unmarshal([]byte(folder.AuthJSON), &folder.Auth)
 	return &folder, nil
 }
 
@@ -52,15 +57,21 @@ func (r *FolderRepository) GetByCollectionID(collectionID int64) ([]models.Folde
 	if err != nil {
 		return nil, err
 	}
+
+	for i := range folders {
+		json.Unmarshal([]byte(folders[i].AuthJSON), &folders[i].Auth)
+	}
 	return folders, nil
 }
 
 // Update updates a folder
 func (r *FolderRepository) Update(folder *models.Folder) error {
+	authJSON, _ := json.Marshal(folder.Auth)
+
 	_, err := r.db.Exec(`
-		UPDATE folders SET name = ?, sort_order = ?, updated_at = ?
+		UPDATE folders SET name = ?, auth = ?, sort_order = ?, updated_at = ?
 		WHERE id = ?
-	`, folder.Name, folder.SortOrder, time.Now(), folder.ID)
+	`, folder.Name, string(authJSON), folder.SortOrder, time.Now(), folder.ID)
 	return err
 }
 
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// GraphQLSchemaRepository handles cached GraphQL schema data access
+type GraphQLSchemaRepository struct {
+	db *sqlx.DB
+}
+
+// NewGraphQLSchemaRepository creates a new GraphQLSchemaRepository
+func NewGraphQLSchemaRepository(db *sqlx.DB) *GraphQLSchemaRepository {
+	return &GraphQLSchemaRepository{db: db}
+}
+
+// GetByEndpoint retrieves the cached schema of an endpoint
+func (r *GraphQLSchemaRepository) GetByEndpoint(endpoint string) (*models.GraphQLSchema, error) {
+	var schema models.GraphQLSchema
+	err := r.db.Get(&schema, "SELECT * FROM graphql_schemas WHERE endpoint = ?", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Upsert stores the schema of an endpoint, replacing any cached one
+func (r *GraphQLSchemaRepository) Upsert(schema *models.GraphQLSchema) error {
+	_, err := r.db.Exec(`
+		INSERT INTO graphql_schemas (endpoint, schema, fetched_at) VALUES (?, ?, ?)
+		ON CONFLICT(endpoint) DO UPDATE SET schema = excluded.schema, fetched_at = excluded.fetched_at
+	`, schema.Endpoint, schema.Schema, schema.FetchedAt)
+	if err != nil {
+		return err
+	}
+	return r.db.Get(&schema.ID, "SELECT id FROM graphql_schemas WHERE endpoint = ?", schema.Endpoint)
+}
+
+// Delete deletes the cached schema of an endpoint
+func (r *GraphQLSchemaRepository) Delete(endpoint string) error {
+	_, err := r.db.Exec("DELETE FROM graphql_schemas WHERE endpoint = ?", endpoint)
+	return err
+}
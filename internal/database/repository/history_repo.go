@@ -18,10 +18,10 @@ func NewHistoryRepository(db *sqlx.DB) *HistoryRepository {
 // Create creates a new history record
 func (r *HistoryRepository) Create(history *models.History) error {
 	result, err := r.db.Exec(`
-		INSERT INTO history (request_id, method, url, request_headers, request_body, status_code, response_headers, response_body, duration_ms, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO history (request_id, method, url, request_headers, request_body, status_code, response_headers, response_body, duration_ms, timing, redirects, raw, events, variables, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, history.RequestID, history.Method, history.URL, history.RequestHeaders, history.RequestBody,
-		history.StatusCode, history.ResponseHeaders, history.ResponseBody, history.DurationMs, history.CreatedAt)
+		history.StatusCode, history.ResponseHeaders, history.ResponseBody, history.DurationMs, history.Timing, history.Redirects, history.Raw, history.Events, history.Variables, history.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// OAuth2TokenRepository handles cached OAuth 2.0 token data access
+type OAuth2TokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewOAuth2TokenRepository creates a new OAuth2TokenRepository
+func NewOAuth2TokenRepository(db *sqlx.DB) *OAuth2TokenRepository {
+	return &OAuth2TokenRepository{db: db}
+}
+
+// Get retrieves the token cached under key in an environment
+func (r *OAuth2TokenRepository) Get(envID int64, key string) (*models.OAuth2Token, error) {
+	var token models.OAuth2Token
+	err := r.db.Get(&token, "SELECT * FROM oauth2_tokens WHERE env_id = ? AND cache_key = ?", envID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Save inserts a token or replaces the one cached under the same env and key
+func (r *OAuth2TokenRepository) Save(token *models.OAuth2Token) error {
+	_, err := r.db.Exec(`
+		INSERT INTO oauth2_tokens (env_id, cache_key, access_token, refresh_token, token_type, scope, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(env_id, cache_key) DO UPDATE SET
+			access_token = excluded.access_token, refresh_token = excluded.refresh_token,
+			token_type = excluded.token_type, scope = excluded.scope, expires_at = excluded.expires_at, updated_at = ?
+	`, token.EnvID, token.CacheKey, token.AccessToken, token.RefreshToken, token.TokenType, token.Scope,
+		expiresParam(token.ExpiresAt), time.Now())
+	if err != nil {
+		return err
+	}
+	return r.db.Get(&token.ID, "SELECT id FROM oauth2_tokens WHERE env_id = ? AND cache_key = ?", token.EnvID, token.CacheKey)
+}
+
+// Delete deletes the token cached under key in an environment
+func (r *OAuth2TokenRepository) Delete(envID int64, key string) error {
+	_, err := r.db.Exec("DELETE FROM oauth2_tokens WHERE env_id = ? AND cache_key = ?", envID, key)
+	return err
+}
+
+// DeleteByEnvID deletes all tokens of an environment
+func (r *OAuth2TokenRepository) DeleteByEnvID(envID int64) error {
+	_, err := r.db.Exec("DELETE FROM oauth2_tokens WHERE env_id = ?", envID)
+	return err
+}
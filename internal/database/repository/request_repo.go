@@ -22,11 +22,13 @@ func NewRequestRepository(db *sqlx.DB) *RequestRepository {
 func (r *RequestRepository) Create(req *models.Request) error {
 	headersJSON, _ := json.Marshal(req.Headers)
 	paramsJSON, _ := json.Marshal(req.Params)
+	settingsJSON, _ := json.Marshal(req.Settings)
+	authJSON, _ := json.Marshal(req.Auth)
 
 	result, err := r.db.Exec(`
-		INSERT INTO requests (collection_id, folder_id, name, method, url, headers, params, body, body_type, sort_order)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, req.CollectionID, req.FolderID, req.Name, req.Method, req.URL, string(headersJSON), string(paramsJSON), req.Body, req.BodyType, req.SortOrder)
+		INSERT INTO requests (collection_id, folder_id, name, kind, method, url, headers, params, body, body_type, settings, auth, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, req.CollectionID, req.FolderID, req.Name, requestKind(req), req.Method, req.URL, string(headersJSON), string(paramsJSON), req.Body, req.BodyType, string(settingsJSON), string(authJSON), req.SortOrder)
 	if err != nil {
 		return err
 	}
@@ -50,6 +52,8 @@ func (r *RequestRepository) GetByID(id int64) (*models.Request, error) {
 	// Parse JSON fields
 	json.Unmarshal([]byte(req.HeadersJSON), &req.Headers)
 	json.Unmarshal([]byte(req.ParamsJSON), &req.Params)
+	json.Unmarshal([]byte(req.SettingsJSON), &req.Settings)
+	json.Unmarshal([]byte(req.AuthJSON), &req.Auth)
 	return &req, nil
 }
 
@@ -64,6 +68,8 @@ func (r *RequestRepository) GetByCollectionID(collectionID int64) ([]models.Requ
 	for i := range requests {
 		json.Unmarshal([]byte(requests[i].HeadersJSON), &requests[i].Headers)
 		json.Unmarshal([]byte(requests[i].ParamsJSON), &requests[i].Params)
+		json.Unmarshal([]byte(requests[i].SettingsJSON), &requests[i].Settings)
+		json.Unmarshal([]byte(requests[i].AuthJSON), &requests[i].Auth)
 	}
 	return requests, nil
 }
@@ -79,6 +85,8 @@ func (r *RequestRepository) GetByFolderID(folderID int64) ([]models.Request, err
 	for i := range requests {
 		json.Unmarshal([]byte(requests[i].HeadersJSON), &requests[i].Headers)
 		json.Unmarshal([]byte(requests[i].ParamsJSON), &requests[i].Params)
+		json.Unmarshal([]byte(requests[i].SettingsJSON), &requests[i].Settings)
+		json.Unmarshal([]byte(requests[i].AuthJSON), &requests[i].Auth)
 	}
 	return requests, nil
 }
@@ -87,14 +95,16 @@ func (r *RequestRepository) GetByFolderID(folderID int64) ([]models.Request, err
 func (r *RequestRepository) Update(req *models.Request) error {
 	headersJSON, _ := json.Marshal(req.Headers)
 	paramsJSON, _ := json.Marshal(req.Params)
+	settingsJSON, _ := json.Marshal(req.Settings)
+	authJSON, _ := json.Marshal(req.Auth)
 
 	_, err := r.db.Exec(`
 		UPDATE requests SET
-			collection_id = ?, folder_id = ?, name = ?, method = ?, url = ?,
-			headers = ?, params = ?, body = ?, body_type = ?, sort_order = ?, updated_at = ?
+			collection_id = ?, folder_id = ?, name = ?, kind = ?, method = ?, url = ?,
+			headers = ?, params = ?, body = ?, body_type = ?, settings = ?, auth = ?, sort_order = ?, updated_at = ?
 		WHERE id = ?
-	`, req.CollectionID, req.FolderID, req.Name, req.Method, req.URL,
-		string(headersJSON), string(paramsJSON), req.Body, req.BodyType, req.SortOrder, time.Now(), req.ID)
+	`, req.CollectionID, req.FolderID, req.Name, requestKind(req), req.Method, req.URL,
+		string(headersJSON), string(paramsJSON), req.Body, req.BodyType, string(settingsJSON), string(authJSON), req.SortOrder, time.Now(), req.ID)
 	return err
 }
 
@@ -115,6 +125,16 @@ func (r *RequestRepository) GetAll() ([]models.Request, error) {
 	for i := range requests {
 		json.Unmarshal([]byte(requests[i].HeadersJSON), &requests[i].Headers)
 		json.Unmarshal([]byte(requests[i].ParamsJSON), &requests[i].Params)
+		json.Unmarshal([]byte(requests[i].SettingsJSON), &requests[i].Settings)
+		json.Unmarshal([]byte(requests[i].AuthJSON), &requests[i].Auth)
 	}
 	return requests, nil
 }
+
+// requestKind returns the kind of req, defaulting to HTTP and recording the default on req
+func requestKind(req *models.Request) string {
+	if req.Kind == "" {
+		req.Kind = models.RequestKindHTTP
+	}
+	return req.Kind
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"github.com/SoulTraitor/postme/internal/database"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+)
+
+// CertificateHandler handles client certificate and CA operations for the frontend
+type CertificateHandler struct {
+	service *services.CertificateService
+}
+
+// NewCertificateHandler creates a new CertificateHandler
+func NewCertificateHandler() *CertificateHandler {
+	return &CertificateHandler{}
+}
+
+// Init initializes the handler with database connection
+func (h *CertificateHandler) Init() {
+	h.service = services.NewCertificateService(database.GetDB(), database.GetCertificateDir())
+}
+
+// GetAll retrieves all certificates
+func (h *CertificateHandler) GetAll() ([]models.Certificate, error) {
+	return h.service.GetAll()
+}
+
+// ImportPEM imports a client certificate from PEM files; keyFile may be empty if the key is in certFile
+func (h *CertificateHandler) ImportPEM(name, host, certFile, keyFile string) (*models.Certificate, error) {
+	return h.service.ImportPEM(name, host, certFile, keyFile)
+}
+
+// ImportPKCS12 imports a client certificate from a .p12/.pfx file
+func (h *CertificateHandler) ImportPKCS12(name, host, file, password string) (*models.Certificate, error) {
+	return h.service.ImportPKCS12(name, host, file, password)
+}
+
+// ImportCA imports a PEM bundle of CA certificates to trust
+func (h *CertificateHandler) ImportCA(name, host, file string) (*models.Certificate, error) {
+	return h.service.ImportCA(name, host, file)
+}
+
+// Update updates the name, host pattern and enabled state of a certificate
+func (h *CertificateHandler) Update(cert models.Certificate) error {
+	return h.service.Update(&cert)
+}
+
+// Delete deletes a certificate
+func (h *CertificateHandler) Delete(id int64) error {
+	return h.service.Delete(id)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/SoulTraitor/postme/internal/database"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+)
+
+// CookieHandler handles cookie jar operations for the frontend.
+// envID selects the jar: 0 is the shared jar, otherwise an environment's own jar.
+type CookieHandler struct {
+	service *services.CookieService
+}
+
+// NewCookieHandler creates a new CookieHandler
+func NewCookieHandler() *CookieHandler {
+	return &CookieHandler{}
+}
+
+// Init initializes the handler with database connection
+func (h *CookieHandler) Init() {
+	h.service = services.NewCookieService(database.GetDB())
+}
+
+// GetAll retrieves all cookies of a jar
+func (h *CookieHandler) GetAll(envID int64) ([]models.Cookie, error) {
+	return h.service.GetAll(envID)
+}
+
+// GetByDomain retrieves the cookies of a jar stored for a domain
+func (h *CookieHandler) GetByDomain(envID int64, domain string) ([]models.Cookie, error) {
+	return h.service.GetByDomain(envID, domain)
+}
+
+// Create creates a cookie, replacing any with the same domain, path and name
+func (h *CookieHandler) Create(cookie models.Cookie) (*models.Cookie, error) {
+	if err := h.service.Save(&cookie); err != nil {
+		return nil, err
+	}
+	return &cookie, nil
+}
+
+// Update updates a cookie
+func (h *CookieHandler) Update(cookie models.Cookie) error {
+	return h.service.Update(&cookie)
+}
+
+// Delete deletes a cookie
+func (h *CookieHandler) Delete(id int64) error {
+	return h.service.Delete(id)
+}
+
+// DeleteByDomain deletes all cookies of a jar stored for a domain
+func (h *CookieHandler) DeleteByDomain(envID int64, domain string) error {
+	return h.service.DeleteByDomain(envID, domain)
+}
+
+// Clear deletes all cookies of a jar
+func (h *CookieHandler) Clear(envID int64) error {
+	return h.service.Clear(envID)
+}
@@ -53,6 +53,14 @@ func (h *DialogHandler) OpenAnyFileDialog(title string) (string, error) {
 	})
 }
 
+// SaveAnyFileDialog opens a native file save dialog without file filters.
+func (h *DialogHandler) SaveAnyFileDialog(title string, defaultFilename string) (string, error) {
+	return runtime.SaveFileDialog(h.ctx, runtime.SaveDialogOptions{
+		Title:           title,
+		DefaultFilename: defaultFilename,
+	})
+}
+
 // SaveFileDialog opens a native file save dialog
 func (h *DialogHandler) SaveFileDialog(title string, defaultFilename string) (string, error) {
 	return runtime.SaveFileDialog(h.ctx, runtime.SaveDialogOptions{
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/SoulTraitor/postme/internal/database"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+)
+
+// GraphQLHandler handles GraphQL schema operations for the frontend
+type GraphQLHandler struct {
+	requests *RequestHandler
+	service  *services.GraphQLService
+}
+
+// NewGraphQLHandler creates a new GraphQLHandler introspecting with the HTTP client of requests
+func NewGraphQLHandler(requests *RequestHandler) *GraphQLHandler {
+	return &GraphQLHandler{requests: requests}
+}
+
+// Init initializes the handler with database connection; it must run after the RequestHandler's Init
+func (h *GraphQLHandler) Init() {
+	h.service = services.NewGraphQLService(database.GetDB(), h.requests.httpClient)
+}
+
+// IntrospectParams represents the parameters for fetching the schema of an endpoint.
+// The URL and headers may reference {{variables}} of the environment EnvironmentID.
+type IntrospectParams struct {
+	URL     string            `json:"url"`
+	Headers []models.KeyValue `json:"headers"`
+	Timeout float64           `json:"timeout"`
+	Refresh bool              `json:"refresh"` // fetch again even when the schema is cached
+
+	Settings      *models.RequestSettings `json:"settings"`
+	EnvironmentID *int64                  `json:"environmentId"`
+}
+
+// Introspect returns the schema of an endpoint, fetching it when it is not cached
+func (h *GraphQLHandler) Introspect(params IntrospectParams) (*models.GraphQLSchema, error) {
+	resolver, err := h.requests.environments.Resolver(params.EnvironmentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.service.Introspect(context.Background(), services.ExecuteRequest{
+		URL:           resolver.Resolve(params.URL),
+		Headers:       resolver.ResolveKeyValues(params.Headers),
+		Timeout:       params.Timeout,
+		Settings:      params.Settings,
+		EnvironmentID: params.EnvironmentID,
+	}, params.Refresh)
+}
+
+// GetSchema returns the cached schema of an endpoint, or nil if there is none. Like
+// the other schema methods, it resolves the {{variables}} of url from the environment
+// environmentID.
+func (h *GraphQLHandler) GetSchema(url string, environmentID *int64) (*models.GraphQLSchema, error) {
+	url, err := h.endpoint(url, environmentID)
+	if err != nil {
+		return nil, err
+	}
+	return h.service.GetSchema(url)
+}
+
+// ClearSchema forgets the cached schema of an endpoint
+func (h *GraphQLHandler) ClearSchema(url string, environmentID *int64) error {
+	url, err := h.endpoint(url, environmentID)
+	if err != nil {
+		return err
+	}
+	return h.service.ClearSchema(url)
+}
+
+// Validate checks a query against the cached schema of an endpoint
+func (h *GraphQLHandler) Validate(url, query string, environmentID *int64) ([]models.GraphQLError, error) {
+	url, err := h.endpoint(url, environmentID)
+	if err != nil {
+		return nil, err
+	}
+	return h.service.Validate(url, query)
+}
+
+// endpoint returns url with its variables resolved, as schemas are cached under
+func (h *GraphQLHandler) endpoint(url string, environmentID *int64) (string, error) {
+	resolver, err := h.requests.environments.Resolver(environmentID, nil)
+	if err != nil {
+		return "", err
+	}
+	return resolver.Resolve(url), nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// GRPCMessageEvent is the runtime event carrying each models.GRPCMessage of a call
+const GRPCMessageEvent = "grpc:message"
+
+// grpcHistoryMethod is the method gRPC calls are recorded under in history
+const grpcHistoryMethod = "GRPC"
+
+// GRPCHandler handles gRPC calls for the frontend
+type GRPCHandler struct {
+	ctx      context.Context
+	requests *RequestHandler
+	service  *services.GRPCService
+}
+
+// NewGRPCHandler creates a new GRPCHandler connecting with the HTTP client of requests
+func NewGRPCHandler(requests *RequestHandler) *GRPCHandler {
+	return &GRPCHandler{requests: requests}
+}
+
+// Init initializes the handler; it must run after the RequestHandler's Init
+func (h *GRPCHandler) Init() {
+	h.service = services.NewGRPCService(h.requests.httpClient)
+}
+
+// SetContext sets the Wails context, used to emit message events
+func (h *GRPCHandler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// GRPCParams represents the parameters of a gRPC call or service lookup. The URL,
+// headers and body may reference {{variables}} of the environment EnvironmentID.
+type GRPCParams struct {
+	TabID   string            `json:"tabId"`
+	URL     string            `json:"url"`
+	Headers []models.KeyValue `json:"headers"`
+	Body    string            `json:"body"` // a models.GRPCBody as JSON
+	Timeout float64           `json:"timeout"`
+
+	Settings      *models.RequestSettings `json:"settings"`
+	EnvironmentID *int64                  `json:"environmentId"`
+}
+
+// request returns the call of params with its variables resolved, and the values of
+// the dynamic variables it used
+func (h *GRPCHandler) request(params GRPCParams) (services.GRPCRequest, []models.Variable, error) {
+	resolver, err := h.requests.environments.Resolver(params.EnvironmentID, nil)
+	if err != nil {
+		return services.GRPCRequest{}, nil, err
+	}
+	req := services.GRPCRequest{
+		URL:           resolver.Resolve(params.URL),
+		Headers:       resolver.ResolveKeyValues(params.Headers),
+		Body:          resolver.Resolve(params.Body),
+		Timeout:       params.Timeout,
+		Settings:      params.Settings,
+		EnvironmentID: params.EnvironmentID,
+	}
+	return req, resolver.Generated(), nil
+}
+
+// ListServices returns the services and methods of the .proto files of the body or,
+// without any, of the server's reflection service
+func (h *GRPCHandler) ListServices(params GRPCParams) ([]models.GRPCServiceInfo, error) {
+	req, _, err := h.request(params)
+	if err != nil {
+		return nil, err
+	}
+	return h.service.ListServices(context.Background(), req)
+}
+
+// Invoke calls the method of the body and records the call in history. It can be
+// cancelled with the RequestHandler's CancelRequest.
+func (h *GRPCHandler) Invoke(params GRPCParams) (*models.GRPCResponse, error) {
+	req, generated, err := h.request(params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done := h.requests.track(params.TabID)
+	defer done()
+
+	resp, err := h.service.Invoke(ctx, req, h.messageEmitter(params.TabID))
+
+	historyEntry := &models.History{
+		Method:         grpcHistoryMethod,
+		URL:            req.URL,
+		RequestHeaders: services.BuildRequestHeadersJSON(req.Headers),
+		RequestBody:    req.Body,
+		Variables:      services.BuildVariablesJSON(generated),
+	}
+	if resp != nil {
+		// Trailers follow the headers, ending with the status
+		headers := append(append([]models.KeyValue{}, resp.Headers...), resp.Trailers...)
+		headers = append(headers, models.KeyValue{Key: "grpc-status", Value: strconv.Itoa(resp.Code), Enabled: true})
+		if resp.Message != "" {
+			headers = append(headers, models.KeyValue{Key: "grpc-message", Value: resp.Message, Enabled: true})
+		}
+		historyEntry.ResponseHeaders = services.BuildResponseHeadersJSON(headers)
+		if messages, err := json.Marshal(resp.Messages); err == nil {
+			historyEntry.ResponseBody = string(messages)
+		}
+		historyEntry.DurationMs = &resp.Duration
+	}
+	h.requests.history.Create(historyEntry)
+
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// messageEmitter returns a function emitting the response messages of the call of
+// tabID as GRPCMessageEvent, or nil before the Wails context is set
+func (h *GRPCHandler) messageEmitter(tabID string) func(models.GRPCMessage) {
+	if h.ctx == nil {
+		return nil
+	}
+	return func(message models.GRPCMessage) {
+		message.TabID = tabID
+		runtime.EventsEmit(h.ctx, GRPCMessageEvent, message)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// OAuth2PromptEvent is the runtime event carrying the models.OAuth2Prompt of an
+// interactive grant
+const OAuth2PromptEvent = "oauth2:prompt"
+
+// OAuth2Handler handles OAuth 2.0 tokens for the frontend
+type OAuth2Handler struct {
+	ctx      context.Context
+	requests *RequestHandler
+}
+
+// NewOAuth2Handler creates a new OAuth2Handler obtaining tokens with the HTTP client of requests
+func NewOAuth2Handler(requests *RequestHandler) *OAuth2Handler {
+	return &OAuth2Handler{requests: requests}
+}
+
+// Init initializes the handler; it must run after the RequestHandler's Init
+func (h *OAuth2Handler) Init() {}
+
+// SetContext sets the Wails context, used to emit prompts and open the browser
+func (h *OAuth2Handler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// OAuth2Params identifies the OAuth 2.0 auth of a tab and where its token is cached.
+// The auth may reference {{variables}} of the environment EnvironmentID.
+type OAuth2Params struct {
+	TabID     string             `json:"tabId"`
+	Auth      *models.AuthConfig `json:"auth"`      // may inherit
+	RequestID *int64             `json:"requestId"` // saved request, to inherit from its folder or collection
+	Timeout   float64            `json:"timeout"`
+
+	Settings      *models.RequestSettings `json:"settings"`
+	EnvironmentID *int64                  `json:"environmentId"`
+}
+
+// GetToken obtains a new access token for the auth of a tab and caches it. For the
+// authorization code and device code grants the browser is opened and the user code
+// emitted as OAuth2PromptEvent; CancelRequest on the tab stops waiting.
+func (h *OAuth2Handler) GetToken(params OAuth2Params) (*models.OAuth2Token, error) {
+	auth, err := h.auth(params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done := h.requests.track(params.TabID)
+	defer done()
+
+	return h.requests.httpClient.AuthorizeOAuth2(ctx, services.ExecuteRequest{
+		Timeout:       params.Timeout,
+		Auth:          auth,
+		Settings:      params.Settings,
+		EnvironmentID: params.EnvironmentID,
+	}, h.prompter(params.TabID))
+}
+
+// GetCachedToken returns the token cached for the auth of a tab, or nil if there is none
+func (h *OAuth2Handler) GetCachedToken(params OAuth2Params) (*models.OAuth2Token, error) {
+	auth, err := h.auth(params)
+	if err != nil {
+		return nil, err
+	}
+	return h.requests.oauth2.CachedToken(auth.OAuth2, envID(params.EnvironmentID))
+}
+
+// ClearToken forgets the token cached for the auth of a tab
+func (h *OAuth2Handler) ClearToken(params OAuth2Params) error {
+	auth, err := h.auth(params)
+	if err != nil {
+		return err
+	}
+	return h.requests.oauth2.ClearToken(auth.OAuth2, envID(params.EnvironmentID))
+}
+
+// auth resolves the auth of params and its variables; it must be OAuth 2.0
+func (h *OAuth2Handler) auth(params OAuth2Params) (*models.AuthConfig, error) {
+	auth, err := h.requests.resolveAuth(params.Auth, params.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := h.requests.environments.Resolver(params.EnvironmentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	auth = resolver.ResolveAuth(auth)
+	if auth == nil || auth.Type != models.AuthTypeOAuth2 || auth.OAuth2 == nil {
+		return nil, errors.New("the request does not use OAuth 2.0")
+	}
+	return auth, nil
+}
+
+// prompter returns a function emitting the prompts of the grant run for tabID as
+// OAuth2PromptEvent and opening the authorization page in the browser, or nil before
+// the Wails context is set
+func (h *OAuth2Handler) prompter(tabID string) func(models.OAuth2Prompt) {
+	if h.ctx == nil {
+		return nil
+	}
+	return func(prompt models.OAuth2Prompt) {
+		prompt.TabID = tabID
+		runtime.EventsEmit(h.ctx, OAuth2PromptEvent, prompt)
+		if prompt.AuthorizationURL != "" {
+			runtime.BrowserOpenURL(h.ctx, prompt.AuthorizationURL)
+		} else if prompt.VerificationURI != "" {
+			runtime.BrowserOpenURL(h.ctx, prompt.VerificationURI)
+		}
+	}
+}
+
+// envID returns the ID of an environment, or 0 when none is active
+func envID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
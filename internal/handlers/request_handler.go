@@ -2,18 +2,33 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/SoulTraitor/postme/internal/database"
 	"github.com/SoulTraitor/postme/internal/models"
 	"github.com/SoulTraitor/postme/internal/services"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Runtime events emitted while a request runs
+const (
+	ProgressEvent = "request:progress" // carries a models.TransferProgress
+	StreamEvent   = "request:event"    // carries a models.ServerSentEvent
 )
 
 // RequestHandler handles request-related operations for the frontend
 type RequestHandler struct {
-	service    *services.RequestService
-	httpClient *services.HTTPClient
-	history    *services.HistoryService
+	ctx         context.Context
+	service     *services.RequestService
+	collections *services.CollectionService
+	httpClient  *services.HTTPClient
+	history     *services.HistoryService
+	oauth2      *services.OAuth2Service
+
+	environments *services.EnvironmentService
 
 	// For request cancellation
 	mu          sync.Mutex
@@ -31,10 +46,22 @@ func NewRequestHandler() *RequestHandler {
 func (h *RequestHandler) Init() {
 	db := database.GetDB()
 	h.service = services.NewRequestService(db)
+	h.collections = services.NewCollectionService(db)
 	h.httpClient = services.NewHTTPClient()
+	h.httpClient.SetCookieService(services.NewCookieService(db))
+	h.environments = services.NewEnvironmentService(db)
+	h.httpClient.SetEnvironmentService(h.environments)
+	h.httpClient.SetCertificateService(services.NewCertificateService(db, database.GetCertificateDir()))
+	h.oauth2 = services.NewOAuth2Service(db)
+	h.httpClient.SetOAuth2Service(h.oauth2)
 	h.history = services.NewHistoryService(db)
 }
 
+// SetContext sets the Wails context, used to emit progress events
+func (h *RequestHandler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
 // Create creates a new request
 func (h *RequestHandler) Create(req models.Request) (*models.Request, error) {
 	if err := h.service.Create(&req); err != nil {
@@ -71,12 +98,15 @@ func (h *RequestHandler) Duplicate(id int64) (*models.Request, error) {
 		CollectionID: original.CollectionID,
 		FolderID:     original.FolderID,
 		Name:         original.Name + " (copy)",
+		Kind:         original.Kind,
 		Method:       original.Method,
 		URL:          original.URL,
 		Headers:      original.Headers,
 		Params:       original.Params,
 		Body:         original.Body,
 		BodyType:     original.BodyType,
+		Settings:     original.Settings,
+		Auth:         original.Auth,
 		SortOrder:    original.SortOrder + 1, // Place after original
 	}
 
@@ -87,56 +117,82 @@ func (h *RequestHandler) Duplicate(id int64) (*models.Request, error) {
 	return duplicate, nil
 }
 
-// ExecuteRequestParams represents the parameters for executing a request
+// ExecuteRequestParams represents the parameters for executing a request. The URL,
+// params, headers, body and auth may reference {{variables}}, which are resolved from
+// the global variables, the environment EnvironmentID and Variables, in rising precedence.
 type ExecuteRequestParams struct {
 	TabID    string            `json:"tabId"`
 	Method   string            `json:"method"`
 	URL      string            `json:"url"`
+	Params   []models.KeyValue `json:"params"` // set in the query of URL
 	Headers  []models.KeyValue `json:"headers"`
 	Body     string            `json:"body"`
 	BodyType string            `json:"bodyType"`
 	Timeout  float64           `json:"timeout"`
+
+	Settings      *models.RequestSettings `json:"settings"`
+	EnvironmentID *int64                  `json:"environmentId"`
+	Variables     []models.Variable       `json:"variables"` // request-level values
+
+	// Auth is the tab's auth; when it inherits, the auth of the folder or collection of
+	// the saved request RequestID is used
+	Auth      *models.AuthConfig `json:"auth"`
+	RequestID *int64             `json:"requestId"`
 }
 
 // Execute executes an HTTP request
 func (h *RequestHandler) Execute(params ExecuteRequestParams) (*models.Response, error) {
-	// Create cancellable context
-	ctx, cancel := context.WithCancel(context.Background())
-
-	h.mu.Lock()
-	h.cancelFuncs[params.TabID] = cancel
-	h.mu.Unlock()
+	ctx, done := h.track(params.TabID)
+	defer done()
 
-	defer func() {
-		h.mu.Lock()
-		delete(h.cancelFuncs, params.TabID)
-		h.mu.Unlock()
-	}()
+	resolved, err := h.resolve(params)
+	if err != nil {
+		return nil, err
+	}
 
 	// Execute request
 	resp, err := h.httpClient.Execute(ctx, services.ExecuteRequest{
-		Method:   params.Method,
-		URL:      params.URL,
-		Headers:  params.Headers,
-		Body:     params.Body,
+		Method:   resolved.Method,
+		URL:      resolved.URL,
+		Headers:  resolved.Headers,
+		Body:     resolved.Body,
 		BodyType: params.BodyType,
 		Timeout:  params.Timeout,
+		Settings: params.Settings,
+
+		EnvironmentID: params.EnvironmentID,
+		Auth:          resolved.Auth,
+		OnProgress:    h.progressEmitter(params.TabID),
+		OnEvent:       h.streamEmitter(params.TabID),
 	})
 
 	// Save to history
 	historyEntry := &models.History{
-		Method:         params.Method,
-		URL:            params.URL,
-		RequestHeaders: services.BuildRequestHeadersJSON(params.Headers),
-		RequestBody:    params.Body,
+		Method:         resolved.Method,
+		URL:            resolved.URL,
+		RequestHeaders: services.BuildRequestHeadersJSON(resolved.Headers),
+		RequestBody:    resolved.Body,
+		Variables:      services.BuildVariablesJSON(resolved.Generated),
 	}
 
 	if resp != nil {
+		resp.Resolved = resolved
 		statusCode := resp.StatusCode
 		historyEntry.StatusCode = &statusCode
+		if len(resp.SentHeaders) > 0 {
+			// Record what was on the wire rather than what was typed
+			historyEntry.RequestHeaders = services.BuildRequestHeadersJSON(resp.SentHeaders)
+		}
 		historyEntry.ResponseHeaders = services.BuildResponseHeadersJSON(resp.Headers)
-		historyEntry.ResponseBody = resp.Body
+		if resp.BodyEncoding == "" {
+			// Large bodies are kept as their preview and binary ones not at all
+			historyEntry.ResponseBody = resp.Body
+		}
 		historyEntry.DurationMs = &resp.Duration
+		historyEntry.Timing = services.BuildTimingJSON(resp.Timing)
+		historyEntry.Redirects = services.BuildRedirectsJSON(resp.Redirects)
+		historyEntry.Raw = services.BuildRawCaptureJSON(resp.Raw)
+		historyEntry.Events = services.BuildEventsJSON(resp.Events)
 	}
 
 	h.history.Create(historyEntry)
@@ -148,6 +204,103 @@ func (h *RequestHandler) Execute(params ExecuteRequestParams) (*models.Response,
 	return resp, nil
 }
 
+// Preview returns the request of params as Execute would send it, with its inherited
+// auth and its variables resolved, and the names that could not be.
+func (h *RequestHandler) Preview(params ExecuteRequestParams) (*models.ResolvedRequest, error) {
+	return h.resolve(params)
+}
+
+// resolve returns the request of params with its inherited auth and its variables
+// resolved
+func (h *RequestHandler) resolve(params ExecuteRequestParams) (*models.ResolvedRequest, error) {
+	auth, err := h.resolveAuth(params.Auth, params.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := h.environments.Resolver(params.EnvironmentID, params.Variables)
+	if err != nil {
+		return nil, err
+	}
+	url, err := resolveURL(resolver, params.URL, params.Params)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &models.ResolvedRequest{
+		Method:  params.Method,
+		URL:     url,
+		Headers: resolver.ResolveKeyValues(params.Headers),
+		Body:    resolver.Resolve(params.Body),
+		Auth:    resolver.ResolveAuth(auth),
+	}
+	resolved.Unresolved, resolved.Cycles = resolver.Unresolved(), resolver.Cycles()
+	resolved.Generated = resolver.Generated()
+	return resolved, nil
+}
+
+// resolveURL returns rawURL with params set in its query, their variables resolved
+func resolveURL(resolver *services.VariableResolver, rawURL string, params []models.KeyValue) (string, error) {
+	url, err := services.WithQueryParams(resolver.Resolve(rawURL), resolver.ResolveKeyValues(params))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	return url, nil
+}
+
+// resolveAuth returns auth, or when it inherits, the auth of the folder or collection of
+// the saved request requestID. Unsaved requests have nothing to inherit from.
+func (h *RequestHandler) resolveAuth(auth *models.AuthConfig, requestID *int64) (*models.AuthConfig, error) {
+	if !auth.Inherits() || requestID == nil {
+		return auth, nil
+	}
+	req, err := h.service.GetByID(*requestID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return h.collections.ResolveAuth(req.CollectionID, req.FolderID)
+}
+
+// progressEmitter returns a function emitting the transfer progress of the request of
+// tabID as ProgressEvent, or nil before the Wails context is set
+func (h *RequestHandler) progressEmitter(tabID string) func(models.TransferProgress) {
+	if h.ctx == nil {
+		return nil
+	}
+	return func(progress models.TransferProgress) {
+		progress.TabID = tabID
+		runtime.EventsEmit(h.ctx, ProgressEvent, progress)
+	}
+}
+
+// streamEmitter returns a function emitting the server-sent events of the request of
+// tabID as StreamEvent, or nil before the Wails context is set
+func (h *RequestHandler) streamEmitter(tabID string) func(models.ServerSentEvent) {
+	if h.ctx == nil {
+		return nil
+	}
+	return func(event models.ServerSentEvent) {
+		event.TabID = tabID
+		runtime.EventsEmit(h.ctx, StreamEvent, event)
+	}
+}
+
+// track returns a context for a request of tabID that CancelRequest cancels, and a
+// function to call once the request is done
+func (h *RequestHandler) track(tabID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mu.Lock()
+	h.cancelFuncs[tabID] = cancel
+	h.mu.Unlock()
+
+	return ctx, func() {
+		h.mu.Lock()
+		delete(h.cancelFuncs, tabID)
+		h.mu.Unlock()
+	}
+}
+
 // CancelRequest cancels a running request
 func (h *RequestHandler) CancelRequest(tabID string) {
 	h.mu.Lock()
@@ -165,3 +318,89 @@ func (h *RequestHandler) SetUseSystemProxy(useProxy bool) {
 		h.httpClient.SetUseSystemProxy(useProxy)
 	}
 }
+
+// SetManualProxy sets the manually configured proxy for HTTP requests.
+func (h *RequestHandler) SetManualProxy(config models.ProxyConfig) error {
+	if h.httpClient != nil {
+		return h.httpClient.SetManualProxy(config)
+	}
+	return nil
+}
+
+// SetReuseConnections enables or disables keep-alive connection reuse for HTTP requests.
+func (h *RequestHandler) SetReuseConnections(reuse bool) {
+	if h.httpClient != nil {
+		h.httpClient.SetReuseConnections(reuse)
+	}
+}
+
+// SetRedirectPolicy sets the app-wide redirect policy for HTTP requests.
+func (h *RequestHandler) SetRedirectPolicy(policy models.RedirectPolicy) {
+	if h.httpClient != nil {
+		h.httpClient.SetRedirectPolicy(policy)
+	}
+}
+
+// SetTLSProfile sets the app-wide TLS fingerprint profile for HTTPS requests.
+func (h *RequestHandler) SetTLSProfile(name string) error {
+	if h.httpClient != nil {
+		return h.httpClient.SetTLSProfile(name)
+	}
+	return nil
+}
+
+// SetDefaultHeaders sets the app-wide default header profile and its custom header list.
+func (h *RequestHandler) SetDefaultHeaders(profile string, custom []models.KeyValue) error {
+	if h.httpClient != nil {
+		return h.httpClient.SetDefaultHeaders(profile, custom)
+	}
+	return nil
+}
+
+// SetCookieJarEnabled enables or disables the persistent cookie jar for HTTP requests.
+func (h *RequestHandler) SetCookieJarEnabled(enabled bool) {
+	if h.httpClient != nil {
+		h.httpClient.SetCookieJarEnabled(enabled)
+	}
+}
+
+// SetScopeCookiesByEnvironment makes each environment use its own cookie jar.
+func (h *RequestHandler) SetScopeCookiesByEnvironment(scoped bool) {
+	if h.httpClient != nil {
+		h.httpClient.SetScopeCookiesByEnvironment(scoped)
+	}
+}
+
+// SetCaptureRawTraffic enables or disables recording the raw wire traffic of HTTP requests.
+func (h *RequestHandler) SetCaptureRawTraffic(capture bool) {
+	if h.httpClient != nil {
+		h.httpClient.SetCaptureRawTraffic(capture)
+	}
+}
+
+// SetResponseMemoryLimit sets the size in bytes above which response bodies are spooled to disk.
+func (h *RequestHandler) SetResponseMemoryLimit(limit int64) {
+	if h.httpClient != nil {
+		h.httpClient.SetResponseMemoryLimit(limit)
+	}
+}
+
+// SaveResponseBody writes the full body of a response to path, including the part of a
+// large body that was not sent to the frontend.
+func (h *RequestHandler) SaveResponseBody(resp models.Response, path string) error {
+	return h.httpClient.SaveResponseBody(resp, path)
+}
+
+// Shutdown deletes the temporary files of spooled response bodies.
+func (h *RequestHandler) Shutdown() {
+	if h.httpClient != nil {
+		h.httpClient.ReleaseResponseBodies()
+	}
+}
+
+// FlushConnections closes all pooled keep-alive connections.
+func (h *RequestHandler) FlushConnections() {
+	if h.httpClient != nil {
+		h.httpClient.FlushConnections()
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/SoulTraitor/postme/internal/services"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// MessageEvent is the runtime event carrying each models.WebSocketMessage of a session
+const MessageEvent = "websocket:message"
+
+// WebSocketHandler handles WebSocket sessions for the frontend
+type WebSocketHandler struct {
+	ctx      context.Context
+	requests *RequestHandler
+	service  *services.WebSocketService
+}
+
+// NewWebSocketHandler creates a new WebSocketHandler connecting with the HTTP client of requests
+func NewWebSocketHandler(requests *RequestHandler) *WebSocketHandler {
+	return &WebSocketHandler{requests: requests}
+}
+
+// Init initializes the handler; it must run after the RequestHandler's Init
+func (h *WebSocketHandler) Init() {
+	h.service = services.NewWebSocketService(h.requests.httpClient)
+}
+
+// SetContext sets the Wails context, used to emit message events
+func (h *WebSocketHandler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// ConnectParams represents the parameters for opening a WebSocket session. The URL,
+// params and headers may reference {{variables}} of the environment EnvironmentID.
+type ConnectParams struct {
+	TabID   string            `json:"tabId"`
+	URL     string            `json:"url"`
+	Params  []models.KeyValue `json:"params"` // set in the query of URL
+	Headers []models.KeyValue `json:"headers"`
+	Timeout float64           `json:"timeout"`
+
+	Settings      *models.RequestSettings `json:"settings"`
+	EnvironmentID *int64                  `json:"environmentId"`
+}
+
+// Connect opens a WebSocket session for a tab, replacing any session it had
+func (h *WebSocketHandler) Connect(params ConnectParams) (*models.WebSocketHandshake, error) {
+	resolver, err := h.requests.environments.Resolver(params.EnvironmentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	url, err := resolveURL(resolver, params.URL, params.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.service.Connect(context.Background(), params.TabID, services.WebSocketRequest{
+		URL:           url,
+		Headers:       resolver.ResolveKeyValues(params.Headers),
+		Timeout:       params.Timeout,
+		Settings:      params.Settings,
+		EnvironmentID: params.EnvironmentID,
+	}, h.messageEmitter(params.TabID))
+}
+
+// Send sends a "text", "json" or base64 "binary" message on the session of a tab,
+// resolving the {{variables}} of data from the environment environmentID
+func (h *WebSocketHandler) Send(tabID, messageType, data string, environmentID *int64) error {
+	resolver, err := h.requests.environments.Resolver(environmentID, nil)
+	if err != nil {
+		return err
+	}
+	return h.service.Send(tabID, messageType, resolver.Resolve(data))
+}
+
+// Ping sends a ping frame on the session of a tab
+func (h *WebSocketHandler) Ping(tabID, data string) error {
+	return h.service.Ping(tabID, data)
+}
+
+// Close closes the session of a tab with a close code and reason
+func (h *WebSocketHandler) Close(tabID string, code int, reason string) error {
+	return h.service.Close(tabID, code, reason)
+}
+
+// Release closes the session of a tab and forgets its transcript, as when the tab is closed
+func (h *WebSocketHandler) Release(tabID string) {
+	h.service.Release(tabID)
+}
+
+// GetTranscript returns the messages of the last session of a tab
+func (h *WebSocketHandler) GetTranscript(tabID string) []models.WebSocketMessage {
+	return h.service.Transcript(tabID)
+}
+
+// SaveTranscript writes the transcript of the last session of a tab to path as JSON
+func (h *WebSocketHandler) SaveTranscript(tabID, path string) error {
+	return h.service.SaveTranscript(tabID, path)
+}
+
+// Shutdown closes every open session.
+func (h *WebSocketHandler) Shutdown() {
+	if h.service != nil {
+		h.service.CloseAll()
+	}
+}
+
+// messageEmitter returns a function emitting the messages of the session of tabID as
+// MessageEvent, or nil before the Wails context is set
+func (h *WebSocketHandler) messageEmitter(tabID string) func(models.WebSocketMessage) {
+	if h.ctx == nil {
+		return nil
+	}
+	return func(message models.WebSocketMessage) {
+		message.TabID = tabID
+		runtime.EventsEmit(h.ctx, MessageEvent, message)
+	}
+}
@@ -4,24 +4,39 @@ import "time"
 
 // AppState represents the application state (single row)
 type AppState struct {
-	ID                 int64     `json:"id" db:"id"`
-	WindowWidth        int       `json:"windowWidth" db:"window_width"`
-	WindowHeight       int       `json:"windowHeight" db:"window_height"`
-	WindowX            *int      `json:"windowX" db:"window_x"`
-	WindowY            *int      `json:"windowY" db:"window_y"`
-	WindowPositionMode string    `json:"windowPositionMode" db:"window_position_mode"`
-	WindowMaximized    bool      `json:"windowMaximized" db:"window_maximized"`
-	SidebarOpen        bool      `json:"sidebarOpen" db:"sidebar_open"`
-	SidebarWidth       int       `json:"sidebarWidth" db:"sidebar_width"`
-	LayoutDirection    string    `json:"layoutDirection" db:"layout_direction"`
-	SplitRatio         int       `json:"splitRatio" db:"split_ratio"`
-	Theme              string    `json:"theme" db:"theme"`
-	ActiveEnvID        *int64    `json:"activeEnvId" db:"active_env_id"`
-	RequestTimeout     float64   `json:"requestTimeout" db:"request_timeout"`
-	AutoLocateSidebar  bool      `json:"autoLocateSidebar" db:"auto_locate_sidebar"`
-	UseSystemProxy     bool      `json:"useSystemProxy" db:"use_system_proxy"`
-	RequestPanelTab    string    `json:"requestPanelTab" db:"request_panel_tab"`
-	UpdatedAt          time.Time `json:"updatedAt" db:"updated_at"`
+	ID                     int64       `json:"id" db:"id"`
+	WindowWidth            int         `json:"windowWidth" db:"window_width"`
+	WindowHeight           int         `json:"windowHeight" db:"window_height"`
+	WindowX                *int        `json:"windowX" db:"window_x"`
+	WindowY                *int        `json:"windowY" db:"window_y"`
+	WindowPositionMode     string      `json:"windowPositionMode" db:"window_position_mode"`
+	WindowMaximized        bool        `json:"windowMaximized" db:"window_maximized"`
+	SidebarOpen            bool        `json:"sidebarOpen" db:"sidebar_open"`
+	SidebarWidth           int         `json:"sidebarWidth" db:"sidebar_width"`
+	LayoutDirection        string      `json:"layoutDirection" db:"layout_direction"`
+	SplitRatio             int         `json:"splitRatio" db:"split_ratio"`
+	Theme                  string      `json:"theme" db:"theme"`
+	ActiveEnvID            *int64      `json:"activeEnvId" db:"active_env_id"`
+	RequestTimeout         float64     `json:"requestTimeout" db:"request_timeout"`
+	AutoLocateSidebar      bool        `json:"autoLocateSidebar" db:"auto_locate_sidebar"`
+	UseSystemProxy         bool        `json:"useSystemProxy" db:"use_system_proxy"`
+	ManualProxy            ProxyConfig `json:"manualProxy" db:"-"` // takes precedence over the system proxy when enabled
+	ManualProxyJSON        string      `json:"-" db:"manual_proxy"`
+	ReuseConnections       bool        `json:"reuseConnections" db:"reuse_connections"`
+	FollowRedirects        bool        `json:"followRedirects" db:"follow_redirects"`
+	MaxRedirects           int         `json:"maxRedirects" db:"max_redirects"`
+	PreserveRedirectMethod bool        `json:"preserveRedirectMethod" db:"preserve_redirect_method"`
+	StripRedirectAuth      bool        `json:"stripRedirectAuth" db:"strip_redirect_auth"`
+	CookieJarEnabled       bool        `json:"cookieJarEnabled" db:"cookie_jar_enabled"`
+	ScopeCookiesByEnv      bool        `json:"scopeCookiesByEnv" db:"scope_cookies_by_env"`
+	TLSProfile             string      `json:"tlsProfile" db:"tls_profile"`
+	HeaderProfile          string      `json:"headerProfile" db:"header_profile"`
+	CustomHeaders          []KeyValue  `json:"customHeaders" db:"-"`
+	CustomHeadersJSON      string      `json:"-" db:"custom_headers"`
+	CaptureRawTraffic      bool        `json:"captureRawTraffic" db:"capture_raw_traffic"`
+	ResponseMemoryLimit    int64       `json:"responseMemoryLimit" db:"response_memory_limit"` // bytes; larger bodies go to a temp file
+	RequestPanelTab        string      `json:"requestPanelTab" db:"request_panel_tab"`
+	UpdatedAt              time.Time   `json:"updatedAt" db:"updated_at"`
 }
 
 // SidebarState represents the expanded/collapsed state of sidebar items
@@ -34,21 +49,26 @@ type SidebarState struct {
 
 // TabSession represents a tab session
 type TabSession struct {
-	ID          int64      `json:"id" db:"id"`
-	TabID       string     `json:"tabId" db:"tab_id"`
-	RequestID   *int64     `json:"requestId" db:"request_id"`
-	Title       string     `json:"title" db:"title"`
-	SortOrder   int        `json:"sortOrder" db:"sort_order"`
-	IsActive    bool       `json:"isActive" db:"is_active"`
-	IsDirty     bool       `json:"isDirty" db:"is_dirty"`
-	Method      string     `json:"method" db:"method"`
-	URL         string     `json:"url" db:"url"`
-	Headers     []KeyValue `json:"headers" db:"-"`
-	HeadersJSON string     `json:"-" db:"headers"`
-	Params      []KeyValue `json:"params" db:"-"`
-	ParamsJSON  string     `json:"-" db:"params"`
-	Body        string     `json:"body" db:"body"`
-	BodyType    string     `json:"bodyType" db:"body_type"`
-	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updatedAt" db:"updated_at"`
+	ID           int64            `json:"id" db:"id"`
+	TabID        string           `json:"tabId" db:"tab_id"`
+	RequestID    *int64           `json:"requestId" db:"request_id"`
+	Title        string           `json:"title" db:"title"`
+	SortOrder    int              `json:"sortOrder" db:"sort_order"`
+	IsActive     bool             `json:"isActive" db:"is_active"`
+	IsDirty      bool             `json:"isDirty" db:"is_dirty"`
+	Kind         string           `json:"kind" db:"kind"`
+	Method       string           `json:"method" db:"method"`
+	URL          string           `json:"url" db:"url"`
+	Headers      []KeyValue       `json:"headers" db:"-"`
+	HeadersJSON  string           `json:"-" db:"headers"`
+	Params       []KeyValue       `json:"params" db:"-"`
+	ParamsJSON   string           `json:"-" db:"params"`
+	Body         string           `json:"body" db:"body"`
+	BodyType     string           `json:"bodyType" db:"body_type"`
+	Settings     *RequestSettings `json:"settings" db:"-"`
+	SettingsJSON string           `json:"-" db:"settings"`
+	Auth         *AuthConfig      `json:"auth" db:"-"`
+	AuthJSON     string           `json:"-" db:"auth"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" db:"updated_at"`
 }
@@ -0,0 +1,115 @@
+package models
+
+// Auth types
+const (
+	AuthTypeNone    = "none"    // send no credentials, stopping inheritance
+	AuthTypeInherit = "inherit" // use the folder's or collection's auth
+	AuthTypeBasic   = "basic"
+	AuthTypeBearer  = "bearer"
+	AuthTypeAPIKey  = "apikey"
+	AuthTypeDigest  = "digest"
+	AuthTypeOAuth2  = "oauth2"
+	AuthTypeAWSV4   = "awsv4"
+	AuthTypeOAuth1  = "oauth1"
+	AuthTypeHMAC    = "hmac"
+	AuthTypeJWT     = "jwt"
+)
+
+// API key locations
+const (
+	AuthInHeader = "header"
+	AuthInQuery  = "query"
+)
+
+// AuthConfig holds the credentials of a request, folder or collection. Which fields
+// are used depends on Type.
+type AuthConfig struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"` // basic, digest
+	Password string `json:"password,omitempty"` // basic, digest
+	Token    string `json:"token,omitempty"`    // bearer
+	Prefix   string `json:"prefix,omitempty"`   // bearer scheme; empty sends "Bearer"
+	Key      string `json:"key,omitempty"`      // apikey header or query parameter name
+	Value    string `json:"value,omitempty"`    // apikey value
+	In       string `json:"in,omitempty"`       // apikey location: AuthInHeader (default) or AuthInQuery
+
+	OAuth2 *OAuth2Config `json:"oauth2,omitempty"` // oauth2
+
+	AccessKey    string `json:"accessKey,omitempty"`    // awsv4
+	SecretKey    string `json:"secretKey,omitempty"`    // awsv4
+	SessionToken string `json:"sessionToken,omitempty"` // awsv4 temporary credentials
+	Region       string `json:"region,omitempty"`       // awsv4, such as us-east-1
+	Service      string `json:"service,omitempty"`      // awsv4 signing name, such as execute-api or s3
+
+	OAuth1 *OAuth1Config `json:"oauth1,omitempty"` // oauth1
+	HMAC   *HMACConfig   `json:"hmac,omitempty"`   // hmac
+	JWT    *JWTConfig    `json:"jwt,omitempty"`    // jwt
+}
+
+// OAuth 1.0a signature methods
+const (
+	OAuth1HMACSHA1   = "HMAC-SHA1"
+	OAuth1HMACSHA256 = "HMAC-SHA256"
+	OAuth1RSASHA1    = "RSA-SHA1"
+	OAuth1Plaintext  = "PLAINTEXT"
+)
+
+// OAuth1Config holds the credentials of an OAuth 1.0a signed request (RFC 5849)
+type OAuth1Config struct {
+	ConsumerKey     string `json:"consumerKey"`
+	ConsumerSecret  string `json:"consumerSecret,omitempty"` // HMAC and PLAINTEXT
+	PrivateKey      string `json:"privateKey,omitempty"`     // RSA-SHA1 PEM key
+	Token           string `json:"token,omitempty"`
+	TokenSecret     string `json:"tokenSecret,omitempty"`
+	SignatureMethod string `json:"signatureMethod,omitempty"` // empty signs with HMAC-SHA1
+	Realm           string `json:"realm,omitempty"`
+	Callback        string `json:"callback,omitempty"` // temporary credentials requests
+	Verifier        string `json:"verifier,omitempty"` // token requests
+	In              string `json:"in,omitempty"`       // AuthInHeader (default) or AuthInQuery
+}
+
+// HMACConfig signs a canonical request string with a shared secret. StringToSign and
+// Value are templates of {method}, {host}, {path}, {query}, {timestamp}, {nonce},
+// {bodySha256}, {keyId} and {header:Name}; Value may also use {signature}.
+type HMACConfig struct {
+	Algorithm    string `json:"algorithm,omitempty"` // sha256 (default), sha1 or sha512
+	KeyID        string `json:"keyId,omitempty"`
+	Secret       string `json:"secret"`
+	StringToSign string `json:"stringToSign,omitempty"` // empty uses DefaultHMACStringToSign
+	Encoding     string `json:"encoding,omitempty"`     // signature encoding: hex (default) or base64
+	Header       string `json:"header,omitempty"`       // empty sends Authorization
+	Value        string `json:"value,omitempty"`        // empty uses DefaultHMACValue
+}
+
+// Defaults of the HMACConfig templates
+const (
+	DefaultHMACStringToSign = "{method}\n{path}\n{query}\n{timestamp}\n{nonce}\n{bodySha256}"
+	DefaultHMACValue        = `HMAC keyId="{keyId}", timestamp="{timestamp}", nonce="{nonce}", signature="{signature}"`
+)
+
+// JWT signing algorithms
+const (
+	JWTHS256 = "HS256"
+	JWTRS256 = "RS256"
+	JWTES256 = "ES256"
+)
+
+// JWTConfig mints a JSON Web Token for every request
+type JWTConfig struct {
+	Algorithm  string `json:"algorithm"`
+	Secret     string `json:"secret,omitempty"`     // HS256
+	PrivateKey string `json:"privateKey,omitempty"` // RS256 and ES256 PEM key
+	KeyID      string `json:"keyId,omitempty"`      // kid header
+	Claims     string `json:"claims,omitempty"`     // JSON object; iat is added unless set
+	ExpiresIn  int    `json:"expiresIn,omitempty"`  // seconds until exp, added unless set; 0 adds none
+
+	In     string `json:"in,omitempty"`     // token location: AuthInHeader (default) or AuthInQuery
+	Prefix string `json:"prefix,omitempty"` // header scheme; empty sends "Bearer"
+	Param  string `json:"param,omitempty"`  // query parameter; empty sends jwt
+}
+
+// Inherits reports whether a takes its credentials from the parent folder or collection.
+// A nil config inherits.
+func (a *AuthConfig) Inherits() bool {
+	return a == nil || a.Type == "" || a.Type == AuthTypeInherit
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Certificate kinds
+const (
+	CertificateTypeClient = "client" // client certificate and key presented for mTLS
+	CertificateTypeCA     = "ca"     // additional root CA bundle trusted for server verification
+)
+
+// Certificate represents an imported certificate used for TLS connections.
+// The PEM files live in the certs directory next to the database.
+type Certificate struct {
+	ID        int64      `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Type      string     `json:"type" db:"type"`
+	Host      string     `json:"host" db:"host"` // host pattern such as "api.internal", "*.corp.local" or "localhost:8443"; empty matches every host
+	CertPath  string     `json:"certPath" db:"cert_path"`
+	KeyPath   string     `json:"keyPath" db:"key_path"` // empty for CA bundles
+	Subject   string     `json:"subject" db:"subject"`
+	Issuer    string     `json:"issuer" db:"issuer"`
+	NotAfter  *time.Time `json:"notAfter" db:"not_after"`
+	Enabled   bool       `json:"enabled" db:"enabled"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
+}
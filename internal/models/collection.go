@@ -4,10 +4,12 @@ import "time"
 
 // Collection represents a top-level container for requests
 type Collection struct {
-	ID          int64     `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description" db:"description"`
-	SortOrder   int       `json:"sortOrder" db:"sort_order"`
-	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	ID          int64       `json:"id" db:"id"`
+	Name        string      `json:"name" db:"name"`
+	Description string      `json:"description" db:"description"`
+	Auth        *AuthConfig `json:"auth" db:"-"` // shared by requests that inherit; nil sends none
+	AuthJSON    string      `json:"-" db:"auth"`
+	SortOrder   int         `json:"sortOrder" db:"sort_order"`
+	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time   `json:"updatedAt" db:"updated_at"`
 }
@@ -25,4 +25,11 @@ const (
 
 	// DefaultRequestTimeout is the default request timeout in seconds (0 means no limit)
 	DefaultRequestTimeout = 30.0
+
+	// DefaultMaxRedirects is the default number of redirects followed before stopping
+	DefaultMaxRedirects = 10
+
+	// DefaultResponseMemoryLimit is the default size in bytes above which response bodies
+	// are spooled to a temporary file
+	DefaultResponseMemoryLimit = 10 << 20
 )
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Cookie represents a cookie stored in the persistent cookie jar
+type Cookie struct {
+	ID        int64      `json:"id" db:"id"`
+	EnvID     int64      `json:"envId" db:"env_id"` // 0 is the shared jar used when cookies are not scoped per environment
+	Domain    string     `json:"domain" db:"domain"`
+	Path      string     `json:"path" db:"path"`
+	Name      string     `json:"name" db:"name"`
+	Value     string     `json:"value" db:"value"`
+	HostOnly  bool       `json:"hostOnly" db:"host_only"` // only sent to Domain itself, not its subdomains
+	Secure    bool       `json:"secure" db:"secure"`
+	HttpOnly  bool       `json:"httpOnly" db:"http_only"`
+	SameSite  string     `json:"sameSite" db:"same_site"`
+	Expires   *time.Time `json:"expires" db:"expires"` // nil for session cookies
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
+}
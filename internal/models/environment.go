@@ -11,12 +11,14 @@ type Variable struct {
 
 // Environment represents an environment with variables
 type Environment struct {
-	ID            int64      `json:"id" db:"id"`
-	Name          string     `json:"name" db:"name"`
-	Variables     []Variable `json:"variables" db:"-"`
-	VariablesJSON string     `json:"-" db:"variables"`
-	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
-	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+	ID            int64        `json:"id" db:"id"`
+	Name          string       `json:"name" db:"name"`
+	Variables     []Variable   `json:"variables" db:"-"`
+	VariablesJSON string       `json:"-" db:"variables"`
+	Proxy         *ProxyConfig `json:"proxy" db:"-"` // proxy override for requests run in this environment; nil inherits the app-wide one
+	ProxyJSON     string       `json:"-" db:"proxy"`
+	CreatedAt     time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time    `json:"updatedAt" db:"updated_at"`
 }
 
 // GlobalVariables represents global variables (single row)
@@ -26,3 +28,21 @@ type GlobalVariables struct {
 	VariablesJSON string     `json:"-" db:"variables"`
 	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
 }
+
+// ResolvedRequest is a request with its {{variables}} replaced by their values
+type ResolvedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"` // query parameters included
+	Headers []KeyValue  `json:"headers"`
+	Body    string      `json:"body"`
+	Auth    *AuthConfig `json:"auth"` // inherited auth resolved too
+
+	// Generated are the values of the dynamic variables, such as {{$uuid}}, keyed by
+	// the reference as typed
+	Generated []Variable `json:"generated"`
+
+	// Unresolved are the referenced names with no value, and Cycles those whose value
+	// refers back to itself; both are left as typed
+	Unresolved []string `json:"unresolved"`
+	Cycles     []string `json:"cycles"`
+}
@@ -5,7 +5,7 @@ import "time"
 // ExportFile is the top-level export file structure
 type ExportFile struct {
 	Version    int              `json:"version"`
-	ExportedAt time.Time       `json:"exportedAt"`
+	ExportedAt time.Time        `json:"exportedAt"`
 	Collection ExportCollection `json:"collection"`
 }
 
@@ -13,6 +13,7 @@ type ExportFile struct {
 type ExportCollection struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
+	Auth        *AuthConfig     `json:"auth,omitempty"`
 	Folders     []ExportFolder  `json:"folders"`
 	Requests    []ExportRequest `json:"requests"`
 }
@@ -21,17 +22,21 @@ type ExportCollection struct {
 type ExportFolder struct {
 	Name      string          `json:"name"`
 	SortOrder int             `json:"sortOrder"`
+	Auth      *AuthConfig     `json:"auth,omitempty"`
 	Requests  []ExportRequest `json:"requests"`
 }
 
 // ExportRequest represents a request without IDs/timestamps
 type ExportRequest struct {
-	Name      string     `json:"name"`
-	Method    string     `json:"method"`
-	URL       string     `json:"url"`
-	Headers   []KeyValue `json:"headers"`
-	Params    []KeyValue `json:"params"`
-	Body      string     `json:"body"`
-	BodyType  string     `json:"bodyType"`
-	SortOrder int        `json:"sortOrder"`
+	Name      string           `json:"name"`
+	Kind      string           `json:"kind,omitempty"` // empty for HTTP requests
+	Method    string           `json:"method"`
+	URL       string           `json:"url"`
+	Headers   []KeyValue       `json:"headers"`
+	Params    []KeyValue       `json:"params"`
+	Body      string           `json:"body"`
+	BodyType  string           `json:"bodyType"`
+	Settings  *RequestSettings `json:"settings,omitempty"`
+	Auth      *AuthConfig      `json:"auth,omitempty"`
+	SortOrder int              `json:"sortOrder"`
 }
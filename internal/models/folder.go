@@ -4,10 +4,12 @@ import "time"
 
 // Folder represents a folder within a collection (no nesting allowed)
 type Folder struct {
-	ID           int64     `json:"id" db:"id"`
-	CollectionID int64     `json:"collectionId" db:"collection_id"`
-	Name         string    `json:"name" db:"name"`
-	SortOrder    int       `json:"sortOrder" db:"sort_order"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID           int64       `json:"id" db:"id"`
+	CollectionID int64       `json:"collectionId" db:"collection_id"`
+	Name         string      `json:"name" db:"name"`
+	Auth         *AuthConfig `json:"auth" db:"-"` // nil inherits the collection's
+	AuthJSON     string      `json:"-" db:"auth"`
+	SortOrder    int         `json:"sortOrder" db:"sort_order"`
+	CreatedAt    time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time   `json:"updatedAt" db:"updated_at"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// BodyTypeGraphQL is the body type of GraphQL requests, whose Body holds a GraphQLBody as JSON
+const BodyTypeGraphQL = "graphql"
+
+// GraphQLBody is the payload of a GraphQL request
+type GraphQLBody struct {
+	Query         string `json:"query"`
+	Variables     string `json:"variables"` // JSON object as typed; empty for none
+	OperationName string `json:"operationName"`
+}
+
+// GraphQLSchema is the introspected schema of a GraphQL endpoint, cached per endpoint
+type GraphQLSchema struct {
+	ID        int64     `json:"id" db:"id"`
+	Endpoint  string    `json:"endpoint" db:"endpoint"`
+	Schema    string    `json:"schema" db:"schema"` // JSON of the introspection query's __schema
+	FetchedAt time.Time `json:"fetchedAt" db:"fetched_at"`
+}
+
+// GraphQLError is a problem found when checking a query against a schema
+type GraphQLError struct {
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// GRPCBody is the payload of a gRPC request, stored as JSON in the request's Body. The
+// request's URL is the server: grpc://host:port for plaintext, grpcs://host:port or a
+// bare host:port for TLS, or the http(s) base URL of a gRPC-Web endpoint.
+type GRPCBody struct {
+	Method  string `json:"method"`  // full method name, package.Service/Method
+	Message string `json:"message"` // request message as JSON; a JSON array of messages for client streaming
+
+	// ProtoFiles are .proto files describing the service. Without any the server's
+	// reflection service is asked.
+	ProtoFiles  []string `json:"protoFiles,omitempty"`
+	ImportPaths []string `json:"importPaths,omitempty"` // directories imports are resolved in
+
+	Web bool `json:"web,omitempty"` // call over gRPC-Web instead of HTTP/2
+}
+
+// GRPCServiceInfo describes a service and its methods
+type GRPCServiceInfo struct {
+	Name    string           `json:"name"` // full name, package.Service
+	Methods []GRPCMethodInfo `json:"methods"`
+}
+
+// GRPCMethodInfo describes a method of a service
+type GRPCMethodInfo struct {
+	Name            string `json:"name"`
+	FullName        string `json:"fullName"` // package.Service/Method, as set in GRPCBody.Method
+	InputType       string `json:"inputType"`
+	OutputType      string `json:"outputType"`
+	ClientStreaming bool   `json:"clientStreaming"`
+	ServerStreaming bool   `json:"serverStreaming"`
+
+	// Template is a request message as JSON with every field at its default
+	Template string `json:"template"`
+}
+
+// GRPCResponse is the outcome of a gRPC call. A call the server failed is a response
+// with a non-zero Code, not an error.
+type GRPCResponse struct {
+	Code     int        `json:"code"`   // gRPC status code; 0 is OK
+	Status   string     `json:"status"` // name of the code, such as "NotFound"
+	Message  string     `json:"message"`
+	Headers  []KeyValue `json:"headers"`
+	Trailers []KeyValue `json:"trailers"`
+	Messages []string   `json:"messages"` // response messages as JSON, in order
+	Duration int64      `json:"duration"` // milliseconds
+}
+
+// GRPCMessage is a response message of a streaming call, sent as it arrives
+type GRPCMessage struct {
+	TabID string `json:"tabId,omitempty"`
+	Data  string `json:"data"` // the message as JSON
+	Time  int64  `json:"time"` // milliseconds since the call started
+}
@@ -0,0 +1,12 @@
+package models
+
+// Default header profiles decide which headers are added to a request when the
+// user did not set them
+const (
+	HeaderProfileBrowser = "browser" // full browser header set matching the TLS profile
+	HeaderProfileMinimal = "minimal" // User-Agent and Accept only
+	HeaderProfileNone    = "none"    // send exactly the user's headers
+	HeaderProfileCustom  = "custom"  // user-defined list
+
+	DefaultHeaderProfile = HeaderProfileBrowser
+)
@@ -14,5 +14,10 @@ type History struct {
 	ResponseHeaders string    `json:"responseHeaders" db:"response_headers"`
 	ResponseBody    string    `json:"responseBody" db:"response_body"`
 	DurationMs      *int64    `json:"durationMs" db:"duration_ms"`
+	Timing          string    `json:"timing" db:"timing"`
+	Redirects       string    `json:"redirects" db:"redirects"`
+	Raw             string    `json:"raw" db:"raw"`
+	Events          string    `json:"events" db:"events"`
+	Variables       string    `json:"variables" db:"variables"` // JSON []Variable of the dynamic values sent
 	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
 }
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// OAuth 2.0 grant types
+const (
+	OAuth2GrantClientCredentials = "client_credentials"
+	OAuth2GrantPassword          = "password"
+	OAuth2GrantRefreshToken      = "refresh_token"
+	OAuth2GrantDeviceCode        = "device_code"
+	OAuth2GrantAuthorizationCode = "authorization_code" // with PKCE and a loopback redirect
+)
+
+// OAuth 2.0 client authentication methods
+const (
+	OAuth2ClientAuthBasic = "basic" // client_secret_basic
+	OAuth2ClientAuthBody  = "body"  // client_secret_post
+)
+
+// OAuth2Config holds how an OAuth 2.0 access token is obtained and sent
+type OAuth2Config struct {
+	GrantType     string `json:"grantType"`
+	TokenURL      string `json:"tokenUrl"`
+	AuthURL       string `json:"authUrl,omitempty"`       // authorization_code
+	DeviceAuthURL string `json:"deviceAuthUrl,omitempty"` // device_code
+	RedirectURI   string `json:"redirectUri,omitempty"`   // authorization_code loopback URI; empty picks a free port on 127.0.0.1
+	ClientID      string `json:"clientId"`
+	ClientSecret  string `json:"clientSecret,omitempty"` // empty for public clients
+	ClientAuth    string `json:"clientAuth,omitempty"`   // OAuth2ClientAuthBasic (default) or OAuth2ClientAuthBody
+	Scope         string `json:"scope,omitempty"`
+	Username      string `json:"username,omitempty"`     // password
+	Password      string `json:"password,omitempty"`     // password
+	RefreshToken  string `json:"refreshToken,omitempty"` // refresh_token
+
+	In     string `json:"in,omitempty"`     // token location: AuthInHeader (default) or AuthInQuery
+	Prefix string `json:"prefix,omitempty"` // header scheme; empty sends "Bearer"
+	Param  string `json:"param,omitempty"`  // query parameter; empty sends access_token
+}
+
+// OAuth2Token is an access token cached per environment
+type OAuth2Token struct {
+	ID           int64      `json:"id" db:"id"`
+	EnvID        int64      `json:"envId" db:"env_id"` // 0 when no environment is active
+	CacheKey     string     `json:"-" db:"cache_key"`  // identifies the OAuth2Config the token was obtained with
+	AccessToken  string     `json:"accessToken" db:"access_token"`
+	RefreshToken string     `json:"refreshToken" db:"refresh_token"`
+	TokenType    string     `json:"tokenType" db:"token_type"`
+	Scope        string     `json:"scope" db:"scope"`
+	ExpiresAt    *time.Time `json:"expiresAt" db:"expires_at"` // nil when the server gave no lifetime
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// OAuth2Prompt asks the user to act during an interactive grant: to sign in at
+// AuthorizationURL, or to enter UserCode at VerificationURI
+type OAuth2Prompt struct {
+	TabID            string `json:"tabId"`
+	AuthorizationURL string `json:"authorizationUrl,omitempty"`
+	UserCode         string `json:"userCode,omitempty"`
+	VerificationURI  string `json:"verificationUri,omitempty"`
+	ExpiresIn        int    `json:"expiresIn,omitempty"` // seconds the user code is valid
+}
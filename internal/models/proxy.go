@@ -0,0 +1,18 @@
+package models
+
+// ProxyConfig is a manually configured proxy. Proxy URLs may use the http, https,
+// socks5, socks5h, socks4 and socks4a schemes; a URL without a scheme is an HTTP proxy.
+//
+// When PAC is set or AutoDetect is on, the proxy auto-config script decides and the
+// fixed proxy URLs are ignored.
+type ProxyConfig struct {
+	Enabled    bool     `json:"enabled"`    // false sends requests directly
+	HTTP       string   `json:"http"`       // proxy for http:// requests
+	HTTPS      string   `json:"https"`      // proxy for https:// requests; empty uses HTTP
+	SOCKS      string   `json:"socks"`      // proxy for requests that have no HTTP(S) proxy
+	PAC        string   `json:"pac"`        // URL or file path of a proxy auto-config script
+	AutoDetect bool     `json:"autoDetect"` // discover the PAC script through WPAD
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	Bypass     []string `json:"bypass"` // hosts reached directly: names, *.domain, .domain, CIDR ranges, <local>
+}
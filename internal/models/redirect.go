@@ -0,0 +1,9 @@
+package models
+
+// RedirectPolicy controls how redirect responses are handled
+type RedirectPolicy struct {
+	Follow         bool `json:"follow"`
+	MaxRedirects   int  `json:"maxRedirects"`
+	PreserveMethod bool `json:"preserveMethod"` // keep method and body on 301/302 instead of switching to GET
+	StripAuth      bool `json:"stripAuth"`      // drop Authorization and Cookie headers when leaving the origin
+}
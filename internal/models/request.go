@@ -10,21 +10,47 @@ type KeyValue struct {
 	Type    string `json:"type,omitempty" db:"type"` // "text" or "file" for form-data
 }
 
+// RequestSettings holds per-request overrides of app-wide settings.
+// Nil fields inherit the app-wide value.
+type RequestSettings struct {
+	Redirect      *RedirectPolicy `json:"redirect,omitempty"`
+	SkipTLSVerify bool            `json:"skipTlsVerify,omitempty"` // accept any server certificate, e.g. self-signed dev servers
+	TLSProfile    string          `json:"tlsProfile,omitempty"`    // fingerprint profile; empty inherits the app-wide one
+	HeaderProfile string          `json:"headerProfile,omitempty"` // default header profile; empty inherits the app-wide one
+	CustomHeaders []KeyValue      `json:"customHeaders,omitempty"` // headers of the custom profile; nil inherits the app-wide list
+	CaptureRaw    *bool           `json:"captureRaw,omitempty"`    // record raw wire traffic; nil inherits the app-wide setting
+	Proxy         *ProxyConfig    `json:"proxy,omitempty"`         // proxy override; nil inherits the environment's or app-wide one
+	SSEReconnect  bool            `json:"sseReconnect,omitempty"`  // reconnect with Last-Event-ID when an event stream closes
+	Subprotocols  []string        `json:"subprotocols,omitempty"`  // WebSocket subprotocols offered in the handshake
+}
+
+// Request kinds
+const (
+	RequestKindHTTP      = "http"
+	RequestKindWebSocket = "websocket"
+	RequestKindGRPC      = "grpc" // Body holds a GRPCBody as JSON
+)
+
 // Request represents an HTTP request
 type Request struct {
-	ID           int64      `json:"id" db:"id"`
-	CollectionID int64      `json:"collectionId" db:"collection_id"`
-	FolderID     *int64     `json:"folderId" db:"folder_id"`
-	Name         string     `json:"name" db:"name"`
-	Method       string     `json:"method" db:"method"`
-	URL          string     `json:"url" db:"url"`
-	Headers      []KeyValue `json:"headers" db:"-"`
-	HeadersJSON  string     `json:"-" db:"headers"`
-	Params       []KeyValue `json:"params" db:"-"`
-	ParamsJSON   string     `json:"-" db:"params"`
-	Body         string     `json:"body" db:"body"`
-	BodyType     string     `json:"bodyType" db:"body_type"`
-	SortOrder    int        `json:"sortOrder" db:"sort_order"`
-	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time  `json:"updatedAt" db:"updated_at"`
+	ID           int64            `json:"id" db:"id"`
+	CollectionID int64            `json:"collectionId" db:"collection_id"`
+	FolderID     *int64           `json:"folderId" db:"folder_id"`
+	Name         string           `json:"name" db:"name"`
+	Kind         string           `json:"kind" db:"kind"` // RequestKindHTTP, RequestKindWebSocket or RequestKindGRPC
+	Method       string           `json:"method" db:"method"`
+	URL          string           `json:"url" db:"url"`
+	Headers      []KeyValue       `json:"headers" db:"-"`
+	HeadersJSON  string           `json:"-" db:"headers"`
+	Params       []KeyValue       `json:"params" db:"-"`
+	ParamsJSON   string           `json:"-" db:"params"`
+	Body         string           `json:"body" db:"body"`
+	BodyType     string           `json:"bodyType" db:"body_type"`
+	Settings     *RequestSettings `json:"settings" db:"-"`
+	SettingsJSON string           `json:"-" db:"settings"`
+	Auth         *AuthConfig      `json:"auth" db:"-"` // nil inherits the folder's or collection's
+	AuthJSON     string           `json:"-" db:"auth"`
+	SortOrder    int              `json:"sortOrder" db:"sort_order"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" db:"updated_at"`
 }
@@ -2,10 +2,94 @@ package models
 
 // Response represents an HTTP response
 type Response struct {
-	StatusCode int               `json:"statusCode"`
-	Status     string            `json:"status"`
-	Headers    map[string]string `json:"headers"`
-	Body       string            `json:"body"`
-	Size       int64             `json:"size"`
-	Duration   int64             `json:"duration"` // milliseconds
+	StatusCode int           `json:"statusCode"`
+	Status     string        `json:"status"`
+	URL        string        `json:"url"`     // final URL after redirects
+	Headers    []KeyValue    `json:"headers"` // repeated names kept; wire order when captured
+	Body       string        `json:"body"`
+	Size       int64         `json:"size"`     // of the full body, even when Body is only a preview
+	Duration   int64         `json:"duration"` // milliseconds
+	Timing     *Timing       `json:"timing"`   // final hop only
+	Redirects  []RedirectHop `json:"redirects"`
+
+	// BodyEncoding is "base64" when the body is not UTF-8 text, and empty otherwise
+	BodyEncoding string `json:"bodyEncoding"`
+
+	// Truncated means Body is a preview and the full body was spooled to a temporary
+	// file, which BodyHandle identifies for saving it
+	Truncated  bool   `json:"truncated"`
+	BodyHandle string `json:"bodyHandle"`
+
+	// Events are the events of a text/event-stream response, most recent last
+	Events []ServerSentEvent `json:"events"`
+
+	// SentHeaders are the request headers written on the wire for the final hop,
+	// including defaults and transport-added ones such as Host
+	SentHeaders []KeyValue `json:"sentHeaders"`
+
+	// Raw is the final hop's wire traffic; nil unless capture mode is on
+	Raw *RawCapture `json:"raw"`
+
+	// Resolved is the request as sent, with its variables replaced
+	Resolved *ResolvedRequest `json:"resolved"`
+}
+
+// RedirectHop represents an intermediate redirect response that was followed
+type RedirectHop struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	StatusCode  int         `json:"statusCode"`
+	Status      string      `json:"status"`
+	Location    string      `json:"location"`
+	Headers     []KeyValue  `json:"headers"`
+	SentHeaders []KeyValue  `json:"sentHeaders"`
+	Timing      *Timing     `json:"timing"`
+	Raw         *RawCapture `json:"raw"`
+}
+
+// Timing represents the per-phase breakdown of a request in milliseconds.
+// Phases that did not happen (e.g. DNS for an IP literal) are 0.
+type Timing struct {
+	DNS      float64 `json:"dns"`
+	Connect  float64 `json:"connect"` // includes the proxy tunnel when one is used
+	TLS      float64 `json:"tls"`
+	TTFB     float64 `json:"ttfb"` // request written to first response byte
+	Download float64 `json:"download"`
+	Total    float64 `json:"total"`
+	Reused   bool    `json:"reused"` // connection came from the keep-alive pool
+}
+
+// RawCapture is the traffic of a request as it was on the wire, recorded when
+// capture mode is on. HTTP/1.1 traffic is verbatim; HTTP/2 is summarised frame by frame.
+type RawCapture struct {
+	Protocol  string `json:"protocol"`
+	Request   string `json:"request"`  // request line, headers and body
+	Response  string `json:"response"` // response head before decompression
+	Truncated bool   `json:"truncated"`
+}
+
+// Transfer directions reported by TransferProgress
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+// TransferProgress reports how much of a request body has been sent or of a response
+// body received
+type TransferProgress struct {
+	TabID       string  `json:"tabId"`
+	Direction   string  `json:"direction"` // TransferUpload or TransferDownload
+	Transferred int64   `json:"transferred"`
+	Total       int64   `json:"total"` // 0 when unknown
+	Rate        float64 `json:"rate"`  // bytes per second so far
+	Done        bool    `json:"done"`
+}
+
+// ServerSentEvent is one event received from a text/event-stream response
+type ServerSentEvent struct {
+	TabID string `json:"tabId,omitempty"`
+	ID    string `json:"id"`    // last event ID when the event was dispatched
+	Event string `json:"event"` // event type, "message" unless the server set one
+	Data  string `json:"data"`
+	Time  int64  `json:"time"` // milliseconds since the request was sent
 }
@@ -0,0 +1,14 @@
+package models
+
+// TLS client fingerprint profiles. Each browser profile pairs a uTLS ClientHello
+// preset with that browser's default request headers.
+const (
+	TLSProfileChrome     = "chrome"
+	TLSProfileFirefox    = "firefox"
+	TLSProfileSafari     = "safari"
+	TLSProfileIOS        = "ios"
+	TLSProfileRandomized = "randomized" // randomized ClientHello on every connection
+	TLSProfileGo         = "go"         // stock Go crypto/tls handshake
+
+	DefaultTLSProfile = TLSProfileChrome
+)
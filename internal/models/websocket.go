@@ -0,0 +1,36 @@
+package models
+
+// WebSocket message types recorded in a session transcript
+const (
+	WebSocketText   = "text"
+	WebSocketBinary = "binary"
+	WebSocketPing   = "ping"
+	WebSocketPong   = "pong"
+	WebSocketOpen   = "open"  // the handshake succeeded
+	WebSocketClose  = "close" // a close frame, or the connection ending without one
+	WebSocketError  = "error" // the connection failed
+)
+
+// WebSocket message directions
+const (
+	WebSocketSent     = "sent"
+	WebSocketReceived = "received"
+)
+
+// WebSocketMessage is one entry of a WebSocket session transcript
+type WebSocketMessage struct {
+	TabID     string `json:"tabId,omitempty"`
+	Direction string `json:"direction"` // WebSocketSent or WebSocketReceived; empty for open and error
+	Type      string `json:"type"`
+	Data      string `json:"data"`           // base64 for binary frames; the reason for close
+	Code      int    `json:"code,omitempty"` // close code
+	Time      int64  `json:"time"`           // milliseconds since the connection opened
+}
+
+// WebSocketHandshake is the server's answer to the opening handshake
+type WebSocketHandshake struct {
+	StatusCode  int        `json:"statusCode"`
+	Status      string     `json:"status"`
+	Headers     []KeyValue `json:"headers"`
+	Subprotocol string     `json:"subprotocol"` // empty when the server picked none
+}
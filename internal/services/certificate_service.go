@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/database/repository"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// CertificateService manages imported client certificates and trusted CAs.
+// Imported material is normalised to PEM and copied into dir, so it keeps
+// working when the original files move.
+type CertificateService struct {
+	repo *repository.CertificateRepository
+	dir  string
+}
+
+// NewCertificateService creates a new CertificateService storing files in dir
+func NewCertificateService(db *sqlx.DB, dir string) *CertificateService {
+	return &CertificateService{
+		repo: repository.NewCertificateRepository(db),
+		dir:  dir,
+	}
+}
+
+// GetAll retrieves all certificates
+func (s *CertificateService) GetAll() ([]models.Certificate, error) {
+	return s.repo.GetAll()
+}
+
+// Update updates the name, host pattern and enabled state of a certificate
+func (s *CertificateService) Update(cert *models.Certificate) error {
+	cert.Host = strings.ToLower(strings.TrimSpace(cert.Host))
+	return s.repo.Update(cert)
+}
+
+// Delete deletes a certificate and its stored files
+func (s *CertificateService) Delete(id int64) error {
+	cert, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	os.Remove(cert.CertPath)
+	if cert.KeyPath != "" {
+		os.Remove(cert.KeyPath)
+	}
+	return nil
+}
+
+// ImportPEM imports a client certificate from PEM files. keyFile may be empty
+// when the key is in the same file as the certificate.
+func (s *CertificateService) ImportPEM(name, host, certFile, keyFile string) (*models.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM := certPEM
+	if keyFile != "" {
+		if keyPEM, err = os.ReadFile(keyFile); err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+	return s.saveClientCertificate(name, host, pair)
+}
+
+// ImportPKCS12 imports a client certificate from a PKCS#12 (.p12/.pfx) file
+func (s *CertificateService) ImportPKCS12(name, host, file, password string) (*models.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 file: %w", err)
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PKCS#12 file: %w", err)
+	}
+
+	pair := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, c := range chain {
+		pair.Certificate = append(pair.Certificate, c.Raw)
+	}
+	return s.saveClientCertificate(name, host, pair)
+}
+
+// ImportCA imports a bundle of one or more PEM encoded CA certificates to trust
+func (s *CertificateService) ImportCA(name, host, file string) (*models.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	certs, err := parseCertificatesPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle []byte
+	for _, c := range certs {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+
+	cert := newCertificateRecord(name, host, models.CertificateTypeCA, certs[0])
+	if cert.CertPath, err = s.writeFile("ca", bundle); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(cert); err != nil {
+		os.Remove(cert.CertPath)
+		return nil, err
+	}
+	return cert, nil
+}
+
+func (s *CertificateService) saveClientCertificate(name, host string, pair tls.Certificate) (*models.Certificate, error) {
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(pair.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key: %w", err)
+	}
+
+	var certPEM []byte
+	for _, der := range pair.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	cert := newCertificateRecord(name, host, models.CertificateTypeClient, leaf)
+	if cert.CertPath, err = s.writeFile("cert", certPEM); err != nil {
+		return nil, err
+	}
+	if cert.KeyPath, err = s.writeFile("key", keyPEM); err != nil {
+		os.Remove(cert.CertPath)
+		return nil, err
+	}
+	if err := s.repo.Create(cert); err != nil {
+		os.Remove(cert.CertPath)
+		os.Remove(cert.KeyPath)
+		return nil, err
+	}
+	return cert, nil
+}
+
+// writeFile stores data under a unique name in the certificate directory
+func (s *CertificateService) writeFile(kind string, data []byte) (string, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(s.dir, kind+"-*.pem")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// tlsMaterial is the client certificates and trusted roots that apply to one host
+type tlsMaterial struct {
+	certificates []tls.Certificate
+	rootCAs      *x509.CertPool // nil to use the system roots only
+}
+
+// materialFor loads the enabled certificates whose host pattern matches addr (host:port)
+func (s *CertificateService) materialFor(addr string) (*tlsMaterial, error) {
+	certs, err := s.repo.GetEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	material := &tlsMaterial{}
+	for _, cert := range certs {
+		if !matchCertificateHost(cert.Host, addr) {
+			continue
+		}
+
+		switch cert.Type {
+		case models.CertificateTypeClient:
+			pair, err := tls.LoadX509KeyPair(cert.CertPath, cert.KeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate %q: %w", cert.Name, err)
+			}
+			material.certificates = append(material.certificates, pair)
+		case models.CertificateTypeCA:
+			data, err := os.ReadFile(cert.CertPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load CA %q: %w", cert.Name, err)
+			}
+			if material.rootCAs == nil {
+				// Custom CAs extend the system roots rather than replace them
+				if material.rootCAs, err = x509.SystemCertPool(); err != nil {
+					material.rootCAs = x509.NewCertPool()
+				}
+			}
+			material.rootCAs.AppendCertsFromPEM(data)
+		}
+	}
+	return material, nil
+}
+
+// matchCertificateHost reports whether a host pattern applies to addr (host:port).
+// Patterns may use a leading "*." wildcard for any subdomain and may pin a port.
+func matchCertificateHost(pattern, addr string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.ToLower(host)
+
+	patternHost := pattern
+	if h, p, err := net.SplitHostPort(pattern); err == nil {
+		if _, err := strconv.Atoi(p); err == nil {
+			if p != port {
+				return false
+			}
+			patternHost = h
+		}
+	}
+
+	if suffix, ok := strings.CutPrefix(patternHost, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == patternHost
+}
+
+// parseCertificatesPEM parses every certificate in a PEM bundle
+func parseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+func newCertificateRecord(name, host, certType string, leaf *x509.Certificate) *models.Certificate {
+	if name == "" {
+		name = leaf.Subject.CommonName
+	}
+	notAfter := leaf.NotAfter.UTC().Truncate(time.Second)
+	return &models.Certificate{
+		Name:     name,
+		Type:     certType,
+		Host:     strings.ToLower(strings.TrimSpace(host)),
+		Subject:  leaf.Subject.String(),
+		Issuer:   leaf.Issuer.String(),
+		NotAfter: &notAfter,
+		Enabled:  true,
+	}
+}
@@ -197,6 +197,30 @@ func (s *CollectionService) ReorderRequests(collectionID int64, folderID *int64,
 	return nil
 }
 
+// ResolveAuth returns the auth a request of the collection and folder inherits: the
+// folder's unless it inherits too, then the collection's. It returns nil when neither
+// sets one.
+func (s *CollectionService) ResolveAuth(collectionID int64, folderID *int64) (*models.AuthConfig, error) {
+	if folderID != nil {
+		folder, err := s.folderRepo.GetByID(*folderID)
+		if err != nil {
+			return nil, err
+		}
+		if !folder.Auth.Inherits() {
+			return folder.Auth, nil
+		}
+	}
+
+	collection, err := s.collectionRepo.GetByID(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	if collection.Auth.Inherits() {
+		return nil, nil
+	}
+	return collection.Auth, nil
+}
+
 // GetCollectionTree retrieves a single collection's full tree
 func (s *CollectionService) GetCollectionTree(id int64) (*CollectionTree, error) {
 	collection, err := s.collectionRepo.GetByID(id)
@@ -253,6 +277,7 @@ func (s *CollectionService) ExportCollection(id int64) (*models.ExportFile, erro
 		Collection: models.ExportCollection{
 			Name:        tree.Collection.Name,
 			Description: tree.Collection.Description,
+			Auth:        tree.Collection.Auth,
 		},
 	}
 
@@ -261,6 +286,7 @@ func (s *CollectionService) ExportCollection(id int64) (*models.ExportFile, erro
 		exportFolder := models.ExportFolder{
 			Name:      ft.Folder.Name,
 			SortOrder: ft.Folder.SortOrder,
+			Auth:      ft.Folder.Auth,
 		}
 		for _, req := range ft.Requests {
 			exportFolder.Requests = append(exportFolder.Requests, convertToExportRequest(req))
@@ -279,16 +305,28 @@ func (s *CollectionService) ExportCollection(id int64) (*models.ExportFile, erro
 func convertToExportRequest(req models.Request) models.ExportRequest {
 	return models.ExportRequest{
 		Name:      req.Name,
+		Kind:      exportKind(req.Kind),
 		Method:    req.Method,
 		URL:       req.URL,
 		Headers:   req.Headers,
 		Params:    req.Params,
 		Body:      req.Body,
 		BodyType:  req.BodyType,
+		Settings:  req.Settings,
+		Auth:      req.Auth,
 		SortOrder: req.SortOrder,
 	}
 }
 
+// exportKind leaves the kind of HTTP requests out of export files, as older versions
+// wrote them
+func exportKind(kind string) string {
+	if kind == models.RequestKindHTTP {
+		return ""
+	}
+	return kind
+}
+
 // ImportCollection creates a new collection from an export file
 func (s *CollectionService) ImportCollection(data *models.ExportFile) (*models.Collection, error) {
 	// Determine sort order: place at the end
@@ -307,6 +345,7 @@ func (s *CollectionService) ImportCollection(data *models.ExportFile) (*models.C
 	collection := &models.Collection{
 		Name:        data.Collection.Name,
 		Description: data.Collection.Description,
+		Auth:        data.Collection.Auth,
 		SortOrder:   maxSortOrder + 1,
 	}
 	if err := s.collectionRepo.Create(collection); err != nil {
@@ -318,6 +357,7 @@ func (s *CollectionService) ImportCollection(data *models.ExportFile) (*models.C
 		folder := &models.Folder{
 			CollectionID: collection.ID,
 			Name:         ef.Name,
+			Auth:         ef.Auth,
 			SortOrder:    ef.SortOrder,
 		}
 		if err := s.folderRepo.Create(folder); err != nil {
@@ -329,12 +369,15 @@ func (s *CollectionService) ImportCollection(data *models.ExportFile) (*models.C
 				CollectionID: collection.ID,
 				FolderID:     &folder.ID,
 				Name:         er.Name,
+				Kind:         er.Kind,
 				Method:       er.Method,
 				URL:          er.URL,
 				Headers:      er.Headers,
 				Params:       er.Params,
 				Body:         er.Body,
 				BodyType:     er.BodyType,
+				Settings:     er.Settings,
+				Auth:         er.Auth,
 				SortOrder:    er.SortOrder,
 			}
 			if err := s.requestRepo.Create(req); err != nil {
@@ -348,12 +391,15 @@ func (s *CollectionService) ImportCollection(data *models.ExportFile) (*models.C
 		req := &models.Request{
 			CollectionID: collection.ID,
 			Name:         er.Name,
+			Kind:         er.Kind,
 			Method:       er.Method,
 			URL:          er.URL,
 			Headers:      er.Headers,
 			Params:       er.Params,
 			Body:         er.Body,
 			BodyType:     er.BodyType,
+			Settings:     er.Settings,
+			Auth:         er.Auth,
 			SortOrder:    er.SortOrder,
 		}
 		if err := s.requestRepo.Create(req); err != nil {
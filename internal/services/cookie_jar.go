@@ -0,0 +1,202 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/database/repository"
+	"github.com/SoulTraitor/postme/internal/models"
+	"golang.org/x/net/publicsuffix"
+)
+
+// persistentJar is an http.CookieJar backed by the cookies table.
+// It follows the RFC 6265 storage and matching rules used by browsers.
+type persistentJar struct {
+	repo  *repository.CookieRepository
+	envID int64
+}
+
+// SetCookies stores the cookies received in a response from u
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	now := time.Now()
+	for _, c := range cookies {
+		cookie, remove, ok := cookieFromResponse(u, c, now)
+		if !ok {
+			continue
+		}
+		cookie.EnvID = j.envID
+
+		if remove {
+			j.repo.DeleteByKey(j.envID, cookie.Domain, cookie.Path, cookie.Name)
+		} else {
+			j.repo.Save(&cookie)
+		}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u
+func (j *persistentJar) Cookies(u *url.URL) []*http.Cookie {
+	now := time.Now()
+	j.repo.DeleteExpired(now)
+
+	stored, err := j.repo.GetByEnvID(j.envID)
+	if err != nil {
+		return nil
+	}
+
+	var matched []models.Cookie
+	for _, c := range stored {
+		if cookieMatches(c, u, now) {
+			matched = append(matched, c)
+		}
+	}
+
+	// Longer paths first, then older cookies first (RFC 6265 section 5.4)
+	sort.SliceStable(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].CreatedAt.Before(matched[b].CreatedAt)
+	})
+
+	result := make([]*http.Cookie, 0, len(matched))
+	for _, c := range matched {
+		result = append(result, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return result
+}
+
+// cookieFromResponse converts a Set-Cookie received from u into a stored cookie.
+// remove is true when the cookie deletes an existing one; ok is false when it must be ignored.
+func cookieFromResponse(u *url.URL, c *http.Cookie, now time.Time) (cookie models.Cookie, remove bool, ok bool) {
+	host := normalizeCookieDomain(u.Hostname())
+	if c.Name == "" || host == "" {
+		return cookie, false, false
+	}
+
+	cookie = models.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   host,
+		HostOnly: true,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: sameSiteString(c.SameSite),
+	}
+
+	if c.Domain != "" {
+		domain := normalizeCookieDomain(c.Domain)
+		if !domainMatches(host, domain) {
+			return cookie, false, false
+		}
+		// Refuse cookies for a public suffix such as "com" or "co.uk" unless it is the host itself
+		if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain && host != domain {
+			return cookie, false, false
+		}
+		cookie.Domain = domain
+		// IP addresses only ever match exactly
+		cookie.HostOnly = net.ParseIP(host) != nil
+	}
+
+	if cookie.Path == "" || !strings.HasPrefix(cookie.Path, "/") {
+		cookie.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		return cookie, true, true
+	case c.MaxAge > 0:
+		expires := now.Add(time.Duration(c.MaxAge) * time.Second)
+		cookie.Expires = &expires
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			return cookie, true, true
+		}
+		expires := c.Expires
+		cookie.Expires = &expires
+	}
+
+	return cookie, false, true
+}
+
+// cookieMatches reports whether a stored cookie should be sent in a request to u
+func cookieMatches(c models.Cookie, u *url.URL, now time.Time) bool {
+	if c.Expires != nil && !c.Expires.After(now) {
+		return false
+	}
+
+	host := normalizeCookieDomain(u.Hostname())
+	if c.HostOnly {
+		if host != c.Domain {
+			return false
+		}
+	} else if !domainMatches(host, c.Domain) {
+		return false
+	}
+
+	if c.Secure && u.Scheme != "https" && u.Scheme != "wss" {
+		return false
+	}
+
+	return pathMatches(u.EscapedPath(), c.Path)
+}
+
+// domainMatches implements RFC 6265 domain matching of a request host against a cookie domain
+func domainMatches(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	if net.ParseIP(host) != nil {
+		return false
+	}
+	return strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatches implements RFC 6265 path matching
+func pathMatches(requestPath, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath returns the directory of a request path (RFC 6265 section 5.1.4)
+func defaultCookiePath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(requestPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+// normalizeCookieDomain lowercases a domain and strips the leading dot of legacy Domain attributes
+func normalizeCookieDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+func sameSiteString(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestCookieFromResponse(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	u, _ := url.Parse("https://api.example.com/v1/login")
+
+	tests := []struct {
+		name       string
+		cookie     http.Cookie
+		wantOK     bool
+		wantRemove bool
+		wantDomain string
+		wantHost   bool
+		wantPath   string
+	}{
+		{name: "host only", cookie: http.Cookie{Name: "sid", Value: "1"}, wantOK: true, wantDomain: "api.example.com", wantHost: true, wantPath: "/v1"},
+		{name: "parent domain", cookie: http.Cookie{Name: "sid", Domain: ".Example.com", Path: "/"}, wantOK: true, wantDomain: "example.com", wantPath: "/"},
+		{name: "foreign domain", cookie: http.Cookie{Name: "sid", Domain: "other.com"}},
+		{name: "public suffix", cookie: http.Cookie{Name: "sid", Domain: "com"}},
+		{name: "max age removes", cookie: http.Cookie{Name: "sid", MaxAge: -1}, wantOK: true, wantRemove: true, wantDomain: "api.example.com", wantHost: true, wantPath: "/v1"},
+		{name: "past expiry removes", cookie: http.Cookie{Name: "sid", Expires: now.Add(-time.Hour)}, wantOK: true, wantRemove: true, wantDomain: "api.example.com", wantHost: true, wantPath: "/v1"},
+	}
+
+	for _, tt := range tests {
+		got, remove, ok := cookieFromResponse(u, &tt.cookie, now)
+		if ok != tt.wantOK || remove != tt.wantRemove {
+			t.Fatalf("%s: ok = %v, remove = %v, want %v, %v", tt.name, ok, remove, tt.wantOK, tt.wantRemove)
+		}
+		if !ok {
+			continue
+		}
+		if got.Domain != tt.wantDomain || got.HostOnly != tt.wantHost || got.Path != tt.wantPath {
+			t.Fatalf("%s: got domain=%q hostOnly=%v path=%q", tt.name, got.Domain, got.HostOnly, got.Path)
+		}
+	}
+}
+
+func TestCookieMatches(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		cookie models.Cookie
+		url    string
+		want   bool
+	}{
+		{name: "subdomain of domain cookie", cookie: models.Cookie{Domain: "example.com", Path: "/"}, url: "http://api.example.com/", want: true},
+		{name: "subdomain of host only cookie", cookie: models.Cookie{Domain: "example.com", HostOnly: true, Path: "/"}, url: "http://api.example.com/"},
+		{name: "suffix is not a subdomain", cookie: models.Cookie{Domain: "example.com", Path: "/"}, url: "http://badexample.com/"},
+		{name: "path prefix", cookie: models.Cookie{Domain: "example.com", Path: "/v1"}, url: "http://example.com/v1/users", want: true},
+		{name: "partial path segment", cookie: models.Cookie{Domain: "example.com", Path: "/v1"}, url: "http://example.com/v10"},
+		{name: "secure over http", cookie: models.Cookie{Domain: "example.com", Path: "/", Secure: true}, url: "http://example.com/"},
+		{name: "secure over https", cookie: models.Cookie{Domain: "example.com", Path: "/", Secure: true}, url: "https://example.com/", want: true},
+		{name: "expired", cookie: models.Cookie{Domain: "example.com", Path: "/", Expires: &past}, url: "http://example.com/"},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := cookieMatches(tt.cookie, u, now); got != tt.want {
+			t.Fatalf("%s: cookieMatches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
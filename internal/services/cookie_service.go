@@ -0,0 +1,69 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/SoulTraitor/postme/internal/database/repository"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// CookieService handles cookie jar business logic
+type CookieService struct {
+	repo *repository.CookieRepository
+}
+
+// NewCookieService creates a new CookieService
+func NewCookieService(db *sqlx.DB) *CookieService {
+	return &CookieService{
+		repo: repository.NewCookieRepository(db),
+	}
+}
+
+// Jar returns the cookie jar for envID (0 for the shared jar)
+func (s *CookieService) Jar(envID int64) http.CookieJar {
+	return &persistentJar{repo: s.repo, envID: envID}
+}
+
+// GetAll retrieves all cookies of a jar
+func (s *CookieService) GetAll(envID int64) ([]models.Cookie, error) {
+	return s.repo.GetByEnvID(envID)
+}
+
+// GetByDomain retrieves the cookies of a jar stored for a domain
+func (s *CookieService) GetByDomain(envID int64, domain string) ([]models.Cookie, error) {
+	return s.repo.GetByDomain(envID, normalizeCookieDomain(domain))
+}
+
+// Save creates or replaces a cookie
+func (s *CookieService) Save(cookie *models.Cookie) error {
+	cookie.Domain = normalizeCookieDomain(cookie.Domain)
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	return s.repo.Save(cookie)
+}
+
+// Update updates a cookie
+func (s *CookieService) Update(cookie *models.Cookie) error {
+	cookie.Domain = normalizeCookieDomain(cookie.Domain)
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	return s.repo.Update(cookie)
+}
+
+// Delete deletes a cookie
+func (s *CookieService) Delete(id int64) error {
+	return s.repo.Delete(id)
+}
+
+// DeleteByDomain deletes all cookies of a jar stored for a domain
+func (s *CookieService) DeleteByDomain(envID int64, domain string) error {
+	return s.repo.DeleteByDomain(envID, normalizeCookieDomain(domain))
+}
+
+// Clear deletes all cookies of a jar
+func (s *CookieService) Clear(envID int64) error {
+	return s.repo.DeleteByEnvID(envID)
+}
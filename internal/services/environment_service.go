@@ -1,6 +1,9 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
+
 	"github.com/SoulTraitor/postme/internal/database/repository"
 	"github.com/SoulTraitor/postme/internal/models"
 	"github.com/jmoiron/sqlx"
@@ -8,13 +11,17 @@ import (
 
 // EnvironmentService handles environment business logic
 type EnvironmentService struct {
-	repo *repository.EnvironmentRepository
+	repo    *repository.EnvironmentRepository
+	cookies *repository.CookieRepository
+	tokens  *repository.OAuth2TokenRepository
 }
 
 // NewEnvironmentService creates a new EnvironmentService
 func NewEnvironmentService(db *sqlx.DB) *EnvironmentService {
 	return &EnvironmentService{
-		repo: repository.NewEnvironmentRepository(db),
+		repo:    repository.NewEnvironmentRepository(db),
+		cookies: repository.NewCookieRepository(db),
+		tokens:  repository.NewOAuth2TokenRepository(db),
 	}
 }
 
@@ -38,9 +45,15 @@ func (s *EnvironmentService) Update(env *models.Environment) error {
 	return s.repo.Update(env)
 }
 
-// Delete deletes an environment
+// Delete deletes an environment along with its scoped cookie jar and OAuth 2.0 tokens
 func (s *EnvironmentService) Delete(id int64) error {
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.cookies.DeleteByEnvID(id); err != nil {
+		return err
+	}
+	return s.tokens.DeleteByEnvID(id)
 }
 
 // GetGlobalVariables retrieves global variables
@@ -52,3 +65,27 @@ func (s *EnvironmentService) GetGlobalVariables() (*models.GlobalVariables, erro
 func (s *EnvironmentService) UpdateGlobalVariables(variables []models.Variable) error {
 	return s.repo.UpdateGlobalVariables(variables)
 }
+
+// Resolver returns a resolver of the global variables, overridden by those of the
+// environment envID, overridden in turn by the request-level variables. A nil or
+// deleted environment adds none.
+func (s *EnvironmentService) Resolver(envID *int64, request []models.Variable) (*VariableResolver, error) {
+	globals, err := s.repo.GetGlobalVariables()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	var globalVars, envVars []models.Variable
+	if globals != nil {
+		globalVars = globals.Variables
+	}
+	if envID != nil {
+		env, err := s.repo.GetByID(*envID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if env != nil {
+			envVars = env.Variables
+		}
+	}
+	return NewVariableResolver(globalVars, envVars, request), nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/database/repository"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// graphQLRequest is a GraphQL request as sent in a JSON body
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+}
+
+// parseGraphQLBody decodes the body of a request of the graphql body type. Variables
+// must be a JSON object, or empty or null for none.
+func parseGraphQLBody(body string) (*graphQLRequest, error) {
+	var payload models.GraphQLBody
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return nil, fmt.Errorf("invalid GraphQL body: %w", err)
+	}
+
+	req := &graphQLRequest{Query: payload.Query, OperationName: strings.TrimSpace(payload.OperationName)}
+	variables := strings.TrimSpace(payload.Variables)
+	if variables == "" || variables == "null" {
+		return req, nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(variables), &object); err != nil {
+		return nil, errors.New("GraphQL variables must be a JSON object")
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, []byte(variables))
+	req.Variables = compact.Bytes()
+	return req, nil
+}
+
+// encodeGraphQL returns the URL and body a GraphQL request is sent with. GET requests
+// carry the query, variables and operation name as URL parameters and have no body;
+// other methods send them as a JSON body.
+func encodeGraphQL(method, rawURL, body string) (string, []byte, error) {
+	req, err := parseGraphQLBody(body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !strings.EqualFold(method, http.MethodGet) {
+		payload, err := json.Marshal(req)
+		return rawURL, payload, err
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	query := target.Query()
+	query.Set("query", req.Query)
+	if req.Variables != nil {
+		query.Set("variables", string(req.Variables))
+	}
+	if req.OperationName != "" {
+		query.Set("operationName", req.OperationName)
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), nil, nil
+}
+
+// graphQLEndpoint returns the cache key of the GraphQL endpoint at rawURL: the URL
+// without its query and fragment
+func graphQLEndpoint(rawURL string) string {
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return strings.TrimSpace(rawURL)
+	}
+	target.RawQuery = ""
+	target.Fragment = ""
+	target.Host = strings.ToLower(target.Host)
+	return target.String()
+}
+
+// GraphQLService fetches GraphQL schemas by introspection, caches them per endpoint
+// and checks queries against them
+type GraphQLService struct {
+	repo   *repository.GraphQLSchemaRepository
+	client *HTTPClient
+
+	mu      sync.Mutex
+	indexes map[string]*graphQLSchemaIndex // parsed cached schemas, by endpoint
+}
+
+// NewGraphQLService creates a GraphQLService introspecting endpoints with client
+func NewGraphQLService(db *sqlx.DB, client *HTTPClient) *GraphQLService {
+	return &GraphQLService{
+		repo:    repository.NewGraphQLSchemaRepository(db),
+		client:  client,
+		indexes: make(map[string]*graphQLSchemaIndex),
+	}
+}
+
+// Introspect returns the schema of the endpoint req is sent to, running the
+// introspection query with the request's headers and settings unless the schema is
+// cached and refresh is false
+func (s *GraphQLService) Introspect(ctx context.Context, req ExecuteRequest, refresh bool) (*models.GraphQLSchema, error) {
+	endpoint := graphQLEndpoint(req.URL)
+	if !refresh {
+		if schema, err := s.GetSchema(endpoint); err != nil || schema != nil {
+			return schema, err
+		}
+	}
+
+	body, _ := json.Marshal(models.GraphQLBody{Query: introspectionQuery, OperationName: "IntrospectionQuery"})
+	req.Method = http.MethodPost
+	req.Body = string(body)
+	req.BodyType = models.BodyTypeGraphQL
+	req.OnProgress = nil
+	req.OnEvent = nil
+	resp, err := s.client.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data *struct {
+			Schema json.RawMessage `json:"__schema"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("introspection failed: server answered %s", resp.Status)
+		}
+		return nil, errors.New("introspection failed: the response is not a GraphQL result")
+	}
+	if result.Data == nil || len(result.Data.Schema) == 0 || string(result.Data.Schema) == "null" {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("introspection failed: %s", result.Errors[0].Message)
+		}
+		return nil, errors.New("introspection failed: the response has no schema")
+	}
+
+	index, err := newGraphQLSchemaIndex(result.Data.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("introspection failed: %w", err)
+	}
+	schema := &models.GraphQLSchema{
+		Endpoint:  endpoint,
+		Schema:    string(result.Data.Schema),
+		FetchedAt: time.Now(),
+	}
+	if err := s.repo.Upsert(schema); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.indexes[endpoint] = index
+	s.mu.Unlock()
+	return schema, nil
+}
+
+// GetSchema returns the cached schema of the endpoint at rawURL, or nil if there is none
+func (s *GraphQLService) GetSchema(rawURL string) (*models.GraphQLSchema, error) {
+	schema, err := s.repo.GetByEndpoint(graphQLEndpoint(rawURL))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return schema, err
+}
+
+// ClearSchema forgets the cached schema of the endpoint at rawURL
+func (s *GraphQLService) ClearSchema(rawURL string) error {
+	endpoint := graphQLEndpoint(rawURL)
+	s.mu.Lock()
+	delete(s.indexes, endpoint)
+	s.mu.Unlock()
+	return s.repo.Delete(endpoint)
+}
+
+// Validate checks the syntax of query and, when the schema of the endpoint at rawURL is
+// cached, that the fields and types it selects exist. A query without problems has no
+// errors.
+func (s *GraphQLService) Validate(rawURL, query string) ([]models.GraphQLError, error) {
+	doc, syntaxErr := parseGraphQLDocument(query)
+	if syntaxErr != nil {
+		return []models.GraphQLError{*syntaxErr}, nil
+	}
+
+	index, err := s.index(graphQLEndpoint(rawURL))
+	if err != nil || index == nil {
+		return []models.GraphQLError{}, err
+	}
+	return index.validate(doc), nil
+}
+
+// index returns the parsed cached schema of endpoint, or nil if none is cached
+func (s *GraphQLService) index(endpoint string) (*graphQLSchemaIndex, error) {
+	s.mu.Lock()
+	index := s.indexes[endpoint]
+	s.mu.Unlock()
+	if index != nil {
+		return index, nil
+	}
+
+	schema, err := s.GetSchema(endpoint)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	index, err = newGraphQLSchemaIndex(json.RawMessage(schema.Schema))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.indexes[endpoint] = index
+	s.mu.Unlock()
+	return index, nil
+}
+
+// introspectionQuery is the standard introspection query, deep enough to unwrap
+// list and non-null wrappers seven levels down
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// testGraphQLSchema is the __schema of a small API, shaped as an introspection result
+const testGraphQLSchema = `{
+  "queryType": {"name": "Query"},
+  "mutationType": null,
+  "subscriptionType": null,
+  "types": [
+    {"kind": "OBJECT", "name": "Query", "fields": [
+      {"name": "user", "type": {"kind": "OBJECT", "name": "User"}},
+      {"name": "search", "type": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "LIST", "name": null, "ofType": {"kind": "UNION", "name": "Result"}}}},
+      {"name": "version", "type": {"kind": "SCALAR", "name": "String"}}
+    ]},
+    {"kind": "OBJECT", "name": "User", "fields": [
+      {"name": "id", "type": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "SCALAR", "name": "ID"}}},
+      {"name": "name", "type": {"kind": "SCALAR", "name": "String"}},
+      {"name": "friends", "type": {"kind": "LIST", "name": null, "ofType": {"kind": "OBJECT", "name": "User"}}}
+    ]},
+    {"kind": "OBJECT", "name": "Post", "fields": [
+      {"name": "title", "type": {"kind": "SCALAR", "name": "String"}}
+    ]},
+    {"kind": "UNION", "name": "Result", "fields": null},
+    {"kind": "SCALAR", "name": "String", "fields": null},
+    {"kind": "SCALAR", "name": "ID", "fields": null}
+  ]
+}`
+
+func graphQLBody(query, variables, operationName string) string {
+	body, _ := json.Marshal(models.GraphQLBody{Query: query, Variables: variables, OperationName: operationName})
+	return string(body)
+}
+
+func TestExecuteGraphQLBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := map[string]any{"method": r.Method, "contentType": r.Header.Get("Content-Type"), "accept": r.Header.Get("Accept")}
+		if r.Method == http.MethodGet {
+			for _, key := range []string{"query", "variables", "operationName", "key"} {
+				got[key] = r.URL.Query().Get(key)
+			}
+		} else {
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			got["body"] = body
+		}
+		json.NewEncoder(w).Encode(got)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	execute := func(method, url, body string) map[string]any {
+		t.Helper()
+		resp, err := client.Execute(context.Background(), ExecuteRequest{Method: method, URL: url, Body: body, BodyType: models.BodyTypeGraphQL})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		var got map[string]any
+		json.Unmarshal([]byte(resp.Body), &got)
+		return got
+	}
+
+	got := execute("POST", server.URL, graphQLBody("query Q($id: ID) { user(id: $id) { name } }", `{ "id": "1" }`, "Q"))
+	want := map[string]any{"query": "query Q($id: ID) { user(id: $id) { name } }", "variables": map[string]any{"id": "1"}, "operationName": "Q"}
+	if !reflect.DeepEqual(got["body"], want) {
+		t.Errorf("POST body = %v, want %v", got["body"], want)
+	}
+	if got["contentType"] != "application/json" || !strings.Contains(got["accept"].(string), "application/graphql-response+json") {
+		t.Errorf("headers = %v", got)
+	}
+
+	// Empty variables and operation name are left out
+	got = execute("POST", server.URL, graphQLBody("{ version }", " ", ""))
+	if body := got["body"].(map[string]any); len(body) != 1 || body["query"] != "{ version }" {
+		t.Errorf("POST body = %v", body)
+	}
+
+	got = execute("GET", server.URL+"?key=abc", graphQLBody("{ user { name } }", `{"id": 2}`, "Named"))
+	if got["query"] != "{ user { name } }" || got["variables"] != `{"id":2}` || got["operationName"] != "Named" || got["key"] != "abc" {
+		t.Errorf("GET parameters = %v", got)
+	}
+
+	_, err := client.Execute(context.Background(), ExecuteRequest{Method: "POST", URL: server.URL, Body: graphQLBody("{ version }", "[1]", ""), BodyType: models.BodyTypeGraphQL})
+	if err == nil || !strings.Contains(err.Error(), "JSON object") {
+		t.Errorf("expected a variables error, got %v", err)
+	}
+}
+
+func TestGraphQLIntrospectionCache(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		var body graphQLRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if !strings.Contains(body.Query, "__schema") || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data": {"__schema": ` + testGraphQLSchema + `}}`))
+	}))
+	defer server.Close()
+
+	db := newTestDB(t)
+	service := NewGraphQLService(db, NewHTTPClient())
+	req := ExecuteRequest{
+		URL:     server.URL + "/graphql?ignored=1",
+		Headers: []models.KeyValue{{Key: "Authorization", Value: "Bearer token", Enabled: true}},
+	}
+
+	schema, err := service.Introspect(context.Background(), req, false)
+	if err != nil {
+		t.Fatalf("Introspect: %v", err)
+	}
+	if schema.Endpoint != server.URL+"/graphql" || !strings.Contains(schema.Schema, `"User"`) {
+		t.Errorf("schema = %+v", schema)
+	}
+
+	// Cached per endpoint, also across service instances
+	again, err := NewGraphQLService(db, NewHTTPClient()).Introspect(context.Background(), req, false)
+	if err != nil || again.Schema != schema.Schema || hits.Load() != 1 {
+		t.Errorf("cached introspection: hits %d, err %v", hits.Load(), err)
+	}
+	if _, err := service.Introspect(context.Background(), req, true); err != nil || hits.Load() != 2 {
+		t.Errorf("refresh: hits %d, err %v", hits.Load(), err)
+	}
+
+	if err := service.ClearSchema(server.URL + "/graphql"); err != nil {
+		t.Fatal(err)
+	}
+	if cached, err := service.GetSchema(server.URL + "/graphql"); cached != nil || err != nil {
+		t.Errorf("cleared schema = %+v, %v", cached, err)
+	}
+
+	// Errors of the endpoint are reported
+	req.Headers = nil
+	if _, err := service.Introspect(context.Background(), req, false); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected an introspection error, got %v", err)
+	}
+}
+
+func TestGraphQLValidate(t *testing.T) {
+	db := newTestDB(t)
+	service := NewGraphQLService(db, NewHTTPClient())
+	endpoint := "https://api.example.com/graphql"
+
+	// Without a cached schema only the syntax is checked
+	errs, err := service.Validate(endpoint, "{ anything { goes } }")
+	if err != nil || len(errs) != 0 {
+		t.Errorf("errors without schema = %v, %v", errs, err)
+	}
+	errs, _ = service.Validate(endpoint, "query {\n  user {\n    name\n")
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "Syntax Error") || errs[0].Line != 4 {
+		t.Errorf("syntax errors = %+v", errs)
+	}
+
+	if err := service.repo.Upsert(&models.GraphQLSchema{Endpoint: endpoint, Schema: testGraphQLSchema}); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := `# a comment
+query Q($id: ID = "x") @cached {
+  user(id: $id, filter: {tags: ["a", "b"]}) {
+    ...UserFields
+    friends { __typename id }
+  }
+  search { ... on User { name } ... on Post { title } }
+  version
+  __schema { types { name } }
+}
+fragment UserFields on User { id name @include(if: true) }`
+	if errs, _ := service.Validate(endpoint, valid); len(errs) != 0 {
+		t.Errorf("valid query reported %+v", errs)
+	}
+
+	invalid := `{
+  user { email friends }
+  version { length }
+  search { ... on Nope { x } ...Missing }
+}
+mutation { doIt }`
+	errs, _ = service.Validate(endpoint, invalid)
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Message)
+	}
+	want := []string{
+		`Cannot query field "email" on type "User".`,
+		`Field "friends" of type "User" must have a selection of subfields.`,
+		`Field "version" must not have a selection since type "String" has no subfields.`,
+		`Unknown type "Nope".`,
+		`Unknown fragment "Missing".`,
+		`Schema is not configured for mutations.`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %q\nwant %q", got, want)
+	}
+	if errs[0].Line != 2 || errs[0].Column != 10 {
+		t.Errorf("position of first error = %d:%d", errs[0].Line, errs[0].Column)
+	}
+}
@@ -0,0 +1,492 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// GraphQL token kinds
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlValue // numbers and strings
+)
+
+type gqlToken struct {
+	kind   int
+	text   string
+	line   int
+	column int
+}
+
+// describe names a token for syntax errors
+func (t gqlToken) describe() string {
+	switch t.kind {
+	case gqlEOF:
+		return "<EOF>"
+	case gqlName:
+		return fmt.Sprintf("Name %q", t.text)
+	case gqlValue:
+		return t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexGraphQL splits a GraphQL document into tokens, dropping whitespace, commas and comments
+func lexGraphQL(src string) ([]gqlToken, *models.GraphQLError) {
+	var tokens []gqlToken
+	line, lineStart := 1, 0
+	syntaxError := func(pos int, format string, args ...any) *models.GraphQLError {
+		return &models.GraphQLError{Message: "Syntax Error: " + fmt.Sprintf(format, args...), Line: line, Column: pos - lineStart + 1}
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{gqlPunct, "...", line, i - lineStart + 1})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+			tokens = append(tokens, gqlToken{gqlPunct, string(c), line, i - lineStart + 1})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, gqlToken{gqlName, src[start:i], line, start - lineStart + 1})
+		case c == '-' || c >= '0' && c <= '9':
+			start := i
+			i++
+			for i < len(src) && strings.IndexByte("0123456789.eE+-", src[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, gqlToken{gqlValue, src[start:i], line, start - lineStart + 1})
+		case strings.HasPrefix(src[i:], `"""`):
+			start, startLine, startColumn := i, line, i-lineStart+1
+			i += 3
+			for {
+				if i >= len(src) {
+					return nil, syntaxError(start, "Unterminated string.")
+				}
+				if strings.HasPrefix(src[i:], `\"""`) {
+					i += 4
+					continue
+				}
+				if strings.HasPrefix(src[i:], `"""`) {
+					i += 3
+					break
+				}
+				if src[i] == '\n' {
+					line, lineStart = line+1, i+1
+				}
+				i++
+			}
+			tokens = append(tokens, gqlToken{gqlValue, src[start:i], startLine, startColumn})
+		case c == '"':
+			start := i
+			i++
+			for {
+				if i >= len(src) || src[i] == '\n' {
+					return nil, syntaxError(start, "Unterminated string.")
+				}
+				if src[i] == '\\' {
+					i += 2
+					continue
+				}
+				i++
+				if src[i-1] == '"' {
+					break
+				}
+			}
+			tokens = append(tokens, gqlToken{gqlValue, src[start:i], line, start - lineStart + 1})
+		default:
+			return nil, syntaxError(i, "Unexpected character %q.", c)
+		}
+	}
+	return append(tokens, gqlToken{kind: gqlEOF, line: line, column: len(src) - lineStart + 1}), nil
+}
+
+// gqlSelection is a field, fragment spread or inline fragment of a selection set
+type gqlSelection struct {
+	token      gqlToken // the field or fragment name, or "..." for inline fragments
+	spread     bool
+	typeName   string // type condition of an inline fragment
+	selections []*gqlSelection
+	hasSet     bool // whether a selection set followed
+}
+
+// gqlDefinition is an operation or fragment definition
+type gqlDefinition struct {
+	token      gqlToken
+	operation  string // query, mutation or subscription; empty for fragments
+	fragment   string
+	typeName   string // type condition of a fragment
+	selections []*gqlSelection
+}
+
+// gqlParser is a recursive descent parser for executable GraphQL documents. Arguments,
+// variable definitions and directives are checked for balance but not interpreted.
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+// parseGraphQLDocument parses the operations and fragments of a query
+func parseGraphQLDocument(src string) (doc []*gqlDefinition, syntaxErr *models.GraphQLError) {
+	tokens, lexErr := lexGraphQL(src)
+	if lexErr != nil {
+		return nil, lexErr
+	}
+
+	p := &gqlParser{tokens: tokens}
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*models.GraphQLError)
+			if !ok {
+				panic(r)
+			}
+			doc, syntaxErr = nil, err
+		}
+	}()
+
+	if p.peek().kind == gqlEOF {
+		p.fail("Unexpected <EOF>.")
+	}
+	for p.peek().kind != gqlEOF {
+		doc = append(doc, p.definition())
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) peek() gqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != gqlEOF {
+		p.pos++
+	}
+	return t
+}
+
+// fail aborts parsing with a syntax error at the current token
+func (p *gqlParser) fail(format string, args ...any) {
+	t := p.peek()
+	panic(&models.GraphQLError{Message: "Syntax Error: " + fmt.Sprintf(format, args...), Line: t.line, Column: t.column})
+}
+
+func (p *gqlParser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == gqlPunct && t.text == text
+}
+
+func (p *gqlParser) expectPunct(text string) {
+	if !p.isPunct(text) {
+		p.fail("Expected %q, found %s.", text, p.peek().describe())
+	}
+	p.next()
+}
+
+func (p *gqlParser) expectName() gqlToken {
+	if p.peek().kind != gqlName {
+		p.fail("Expected Name, found %s.", p.peek().describe())
+	}
+	return p.next()
+}
+
+func (p *gqlParser) definition() *gqlDefinition {
+	t := p.peek()
+	if p.isPunct("{") {
+		return &gqlDefinition{token: t, operation: "query", selections: p.selectionSet()}
+	}
+	if t.kind != gqlName {
+		p.fail("Unexpected %s.", t.describe())
+	}
+
+	switch t.text {
+	case "query", "mutation", "subscription":
+		p.next()
+		def := &gqlDefinition{token: t, operation: t.text}
+		if p.peek().kind == gqlName {
+			p.next()
+		}
+		if p.isPunct("(") {
+			p.skipBalanced("(", ")")
+		}
+		p.directives()
+		def.selections = p.selectionSet()
+		return def
+	case "fragment":
+		p.next()
+		name := p.expectName()
+		if name.text == "on" {
+			p.pos--
+			p.fail("Unexpected Name \"on\".")
+		}
+		if t := p.peek(); t.kind != gqlName || t.text != "on" {
+			p.fail("Expected \"on\", found %s.", t.describe())
+		}
+		p.next()
+		typeName := p.expectName()
+		p.directives()
+		return &gqlDefinition{token: name, fragment: name.text, typeName: typeName.text, selections: p.selectionSet()}
+	}
+	p.fail("Unexpected %s.", t.describe())
+	return nil
+}
+
+func (p *gqlParser) selectionSet() []*gqlSelection {
+	p.expectPunct("{")
+	var selections []*gqlSelection
+	for !p.isPunct("}") {
+		if p.peek().kind == gqlEOF {
+			p.fail("Expected \"}\", found <EOF>.")
+		}
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.fail("Expected Name, found \"}\".")
+	}
+	p.next()
+	return selections
+}
+
+func (p *gqlParser) selection() *gqlSelection {
+	if p.isPunct("...") {
+		spreadToken := p.next()
+		if p.peek().kind == gqlName && p.peek().text != "on" {
+			return &gqlSelection{token: p.next(), spread: true}
+		}
+		sel := &gqlSelection{token: spreadToken}
+		if p.peek().kind == gqlName {
+			p.next() // on
+			sel.typeName = p.expectName().text
+		}
+		p.directives()
+		sel.selections = p.selectionSet()
+		sel.hasSet = true
+		return sel
+	}
+
+	name := p.expectName()
+	if p.isPunct(":") {
+		p.next()
+		name = p.expectName()
+	}
+	sel := &gqlSelection{token: name}
+	if p.isPunct("(") {
+		p.skipBalanced("(", ")")
+	}
+	p.directives()
+	if p.isPunct("{") {
+		sel.selections = p.selectionSet()
+		sel.hasSet = true
+	}
+	return sel
+}
+
+func (p *gqlParser) directives() {
+	for p.isPunct("@") {
+		p.next()
+		p.expectName()
+		if p.isPunct("(") {
+			p.skipBalanced("(", ")")
+		}
+	}
+}
+
+// skipBalanced skips from an opening punctuator to its matching close, nested ones included
+func (p *gqlParser) skipBalanced(open, close string) {
+	p.expectPunct(open)
+	for depth := 1; depth > 0; {
+		t := p.next()
+		switch {
+		case t.kind == gqlEOF:
+			p.fail("Expected %q, found <EOF>.", close)
+		case t.kind == gqlPunct && (t.text == "(" || t.text == "[" || t.text == "{"):
+			depth++
+		case t.kind == gqlPunct && (t.text == ")" || t.text == "]" || t.text == "}"):
+			depth--
+		}
+	}
+}
+
+// gqlTypeRef is a type reference of an introspection result, possibly wrapped in
+// LIST and NON_NULL types
+type gqlTypeRef struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	OfType *gqlTypeRef `json:"ofType"`
+}
+
+// named returns the name of the type under the wrappers
+func (t *gqlTypeRef) named() string {
+	for t != nil && t.Name == "" {
+		t = t.OfType
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// gqlType is a type of an introspected schema with the named types of its fields
+type gqlType struct {
+	kind   string
+	fields map[string]string
+}
+
+// graphQLSchemaIndex is the part of an introspected schema needed to check selections
+type graphQLSchemaIndex struct {
+	roots map[string]string // operation to root type name
+	types map[string]*gqlType
+}
+
+// newGraphQLSchemaIndex indexes the __schema of an introspection result
+func newGraphQLSchemaIndex(raw json.RawMessage) (*graphQLSchemaIndex, error) {
+	var schema struct {
+		QueryType        *struct{ Name string } `json:"queryType"`
+		MutationType     *struct{ Name string } `json:"mutationType"`
+		SubscriptionType *struct{ Name string } `json:"subscriptionType"`
+		Types            []struct {
+			Kind   string `json:"kind"`
+			Name   string `json:"name"`
+			Fields []struct {
+				Name string      `json:"name"`
+				Type *gqlTypeRef `json:"type"`
+			} `json:"fields"`
+		} `json:"types"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if schema.QueryType == nil || schema.QueryType.Name == "" {
+		return nil, fmt.Errorf("invalid schema: no query type")
+	}
+
+	index := &graphQLSchemaIndex{roots: map[string]string{"query": schema.QueryType.Name}, types: make(map[string]*gqlType)}
+	if schema.MutationType != nil && schema.MutationType.Name != "" {
+		index.roots["mutation"] = schema.MutationType.Name
+	}
+	if schema.SubscriptionType != nil && schema.SubscriptionType.Name != "" {
+		index.roots["subscription"] = schema.SubscriptionType.Name
+	}
+	for _, t := range schema.Types {
+		typ := &gqlType{kind: t.Kind, fields: make(map[string]string, len(t.Fields))}
+		for _, f := range t.Fields {
+			typ.fields[f.Name] = f.Type.named()
+		}
+		index.types[t.Name] = typ
+	}
+	return index, nil
+}
+
+// validate checks that the selections of doc exist in the schema
+func (x *graphQLSchemaIndex) validate(doc []*gqlDefinition) []models.GraphQLError {
+	errs := []models.GraphQLError{}
+	report := func(t gqlToken, format string, args ...any) {
+		errs = append(errs, models.GraphQLError{Message: fmt.Sprintf(format, args...), Line: t.line, Column: t.column})
+	}
+
+	fragments := make(map[string]bool)
+	for _, def := range doc {
+		if def.fragment != "" {
+			fragments[def.fragment] = true
+		}
+	}
+
+	var check func(parent string, selections []*gqlSelection, root bool)
+	check = func(parent string, selections []*gqlSelection, root bool) {
+		for _, sel := range selections {
+			switch {
+			case sel.spread:
+				if !fragments[sel.token.text] {
+					report(sel.token, "Unknown fragment %q.", sel.token.text)
+				}
+			case sel.token.text == "...":
+				typeName := parent
+				if sel.typeName != "" {
+					if x.types[sel.typeName] == nil {
+						report(sel.token, "Unknown type %q.", sel.typeName)
+						continue
+					}
+					typeName = sel.typeName
+				}
+				check(typeName, sel.selections, false)
+			default:
+				x.checkField(parent, sel, root, report, check)
+			}
+		}
+	}
+
+	for _, def := range doc {
+		if def.fragment != "" {
+			if x.types[def.typeName] == nil {
+				report(def.token, "Unknown type %q.", def.typeName)
+				continue
+			}
+			check(def.typeName, def.selections, false)
+			continue
+		}
+		root, ok := x.roots[def.operation]
+		if !ok {
+			report(def.token, "Schema is not configured for %ss.", def.operation)
+			continue
+		}
+		check(root, def.selections, def.operation == "query")
+	}
+	return errs
+}
+
+// checkField checks one field selected on the type parent and its subselections
+func (x *graphQLSchemaIndex) checkField(parent string, sel *gqlSelection, queryRoot bool,
+	report func(gqlToken, string, ...any), check func(string, []*gqlSelection, bool)) {
+	name := sel.token.text
+	var fieldType string
+	switch {
+	case name == "__typename":
+		fieldType = "String"
+	case queryRoot && name == "__schema":
+		fieldType = "__Schema"
+	case queryRoot && name == "__type":
+		fieldType = "__Type"
+	default:
+		var ok bool
+		if typ := x.types[parent]; typ != nil {
+			fieldType, ok = typ.fields[name]
+		}
+		if !ok {
+			report(sel.token, "Cannot query field %q on type %q.", name, parent)
+			return
+		}
+	}
+
+	typ := x.types[fieldType]
+	if typ == nil {
+		return // a type the schema does not list, such as an introspection type it left out
+	}
+	composite := typ.kind == "OBJECT" || typ.kind == "INTERFACE" || typ.kind == "UNION"
+	switch {
+	case composite && !sel.hasSet:
+		report(sel.token, "Field %q of type %q must have a selection of subfields.", name, fieldType)
+	case !composite && sel.hasSet:
+		report(sel.token, "Field %q must not have a selection since type %q has no subfields.", name, fieldType)
+	case composite:
+		check(fieldType, sel.selections, false)
+	}
+}
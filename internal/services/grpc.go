@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Methods of the server reflection service. Both versions exchange the same messages.
+const (
+	reflectionMethod        = "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+	reflectionMethodV1Alpha = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+)
+
+// grpcTemplateDepth is how many levels of nested messages a method's template fills in
+const grpcTemplateDepth = 3
+
+// parseGRPCBody decodes the body of a gRPC request
+func parseGRPCBody(body string) (*models.GRPCBody, error) {
+	var payload models.GRPCBody
+	if strings.TrimSpace(body) == "" {
+		return &payload, nil
+	}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return nil, fmt.Errorf("invalid gRPC body: %w", err)
+	}
+	return &payload, nil
+}
+
+// loadProtoFiles compiles the .proto files at paths. Imports are looked up in
+// importPaths, or in the directory of each file when there are none; the well-known
+// google/protobuf files are always available.
+func loadProtoFiles(ctx context.Context, paths, importPaths []string) (*protoregistry.Files, error) {
+	if len(importPaths) == 0 {
+		seen := make(map[string]bool)
+		for _, path := range paths {
+			if dir := filepath.Dir(path); !seen[dir] {
+				seen[dir] = true
+				importPaths = append(importPaths, dir)
+			}
+		}
+	}
+
+	// The compiler names files relative to the import path they are found in
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name, err := protoImportName(path, importPaths)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	compiled, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	files := new(protoregistry.Files)
+	for _, file := range compiled {
+		if err := registerFile(files, file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// protoImportName returns the name of the file at path relative to the first of
+// importPaths containing it
+func protoImportName(path string, importPaths []string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return "", err
+	}
+	for _, dir := range importPaths {
+		dirAbs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dirAbs, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not under any import path", path)
+}
+
+// registerFile adds file and, first, the files it imports to files
+func registerFile(files *protoregistry.Files, file protoreflect.FileDescriptor) error {
+	if _, err := files.FindFileByPath(file.Path()); err == nil {
+		return nil
+	}
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if err := registerFile(files, imports.Get(i).FileDescriptor); err != nil {
+			return err
+		}
+	}
+	return files.RegisterFile(file)
+}
+
+// reflectFiles asks the reflection service of the server on conn for the files
+// describing its services, and returns them and the names of the services
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn) (*protoregistry.Files, []string, error) {
+	client, list, err := newReflectionClient(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.close()
+
+	var services []string
+	for _, service := range list.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	var pending []string // imports not fetched yet
+	add := func(resp *reflectionpb.ServerReflectionResponse) error {
+		for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(data, file); err != nil {
+				return fmt.Errorf("invalid file descriptor from server: %w", err)
+			}
+			if protos[file.GetName()] == nil {
+				protos[file.GetName()] = file
+				pending = append(pending, file.GetDependency()...)
+			}
+		}
+		return nil
+	}
+
+	for _, service := range services {
+		resp, err := client.ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+		})
+		var answerErr *reflectionAnswerError
+		if errors.As(err, &answerErr) {
+			// A service the server cannot describe is left out
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := add(resp); err != nil {
+			return nil, nil, err
+		}
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if protos[name] != nil {
+			continue
+		}
+		resp, err := client.ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err == nil {
+			err = add(resp)
+		}
+		if protos[name] == nil {
+			// Servers often leave out the well-known files, which are built in
+			known, knownErr := protoregistry.GlobalFiles.FindFileByPath(name)
+			if knownErr != nil {
+				if err == nil {
+					err = fmt.Errorf("server has no file %s", name)
+				}
+				return nil, nil, err
+			}
+			protos[name] = protodesc.ToFileDescriptorProto(known)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range protos {
+		set.File = append(set.File, file)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid file descriptors from server: %w", err)
+	}
+	return files, services, nil
+}
+
+// reflectionClient is a stream to the server reflection service
+type reflectionClient struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// newReflectionClient opens a stream to the reflection service, falling back to the
+// v1alpha service of older servers, and returns it with the list of services. An
+// unknown service only shows in the first answer, so the list is asked right away.
+func newReflectionClient(ctx context.Context, conn *grpc.ClientConn) (*reflectionClient, *reflectionpb.ServerReflectionResponse, error) {
+	desc := &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}
+	var err error
+	for _, method := range []string{reflectionMethod, reflectionMethodV1Alpha} {
+		streamCtx, cancel := context.WithCancel(ctx)
+		var stream grpc.ClientStream
+		stream, err = conn.NewStream(streamCtx, desc, method)
+		if err == nil {
+			client := &reflectionClient{stream: stream, cancel: cancel}
+			var list *reflectionpb.ServerReflectionResponse
+			list, err = client.ask(&reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+			})
+			if err == nil {
+				return client, list, nil
+			}
+		}
+		cancel()
+		if status.Code(err) != codes.Unimplemented {
+			break
+		}
+	}
+	if status.Code(err) == codes.Unimplemented {
+		return nil, nil, errors.New("server does not support reflection; add the .proto files of the service")
+	}
+	return nil, nil, fmt.Errorf("server reflection failed: %w", err)
+}
+
+// reflectionAnswerError is an error answer of the reflection service
+type reflectionAnswerError struct {
+	code    codes.Code
+	message string
+}
+
+func (e *reflectionAnswerError) Error() string {
+	return fmt.Sprintf("server reflection failed: %s: %s", e.code, e.message)
+}
+
+// ask sends req and returns the answer, turning an error answer into a
+// *reflectionAnswerError
+func (c *reflectionClient) ask(req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+	if err := c.stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	resp := new(reflectionpb.ServerReflectionResponse)
+	if err := c.stream.RecvMsg(resp); err != nil {
+		if err == io.EOF {
+			return nil, errors.New("reflection stream closed")
+		}
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, &reflectionAnswerError{code: codes.Code(e.GetErrorCode()), message: e.GetErrorMessage()}
+	}
+	return resp, nil
+}
+
+// close ends the stream
+func (c *reflectionClient) close() {
+	c.stream.CloseSend()
+	c.cancel()
+}
+
+// describeServices returns the services of files named in names, or every service
+// of files when names is nil, sorted by name
+func describeServices(files *protoregistry.Files, names []string) []models.GRPCServiceInfo {
+	var descs []protoreflect.ServiceDescriptor
+	if names == nil {
+		files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+			for i := 0; i < file.Services().Len(); i++ {
+				descs = append(descs, file.Services().Get(i))
+			}
+			return true
+		})
+	} else {
+		for _, name := range names {
+			if desc, err := files.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+				if service, ok := desc.(protoreflect.ServiceDescriptor); ok {
+					descs = append(descs, service)
+				}
+			}
+		}
+	}
+
+	services := make([]models.GRPCServiceInfo, 0, len(descs))
+	for _, desc := range descs {
+		service := models.GRPCServiceInfo{Name: string(desc.FullName()), Methods: []models.GRPCMethodInfo{}}
+		for i := 0; i < desc.Methods().Len(); i++ {
+			method := desc.Methods().Get(i)
+			service.Methods = append(service.Methods, models.GRPCMethodInfo{
+				Name:            string(method.Name()),
+				FullName:        fmt.Sprintf("%s/%s", desc.FullName(), method.Name()),
+				InputType:       string(method.Input().FullName()),
+				OutputType:      string(method.Output().FullName()),
+				ClientStreaming: method.IsStreamingClient(),
+				ServerStreaming: method.IsStreamingServer(),
+				Template:        messageTemplate(method.Input()),
+			})
+		}
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+// findMethod returns the method named name, as package.Service/Method, in files
+func findMethod(files *protoregistry.Files, name string) (protoreflect.MethodDescriptor, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	if name == "" {
+		return nil, errors.New("no gRPC method selected")
+	}
+	service, method, ok := strings.Cut(name, "/")
+	if !ok {
+		// Also accept package.Service.Method
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid gRPC method %q: expected package.Service/Method", name)
+		}
+		service, method = name[:i], name[i+1:]
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %s", service)
+	}
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(method))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("service %s has no method %s", service, method)
+	}
+	return methodDesc, nil
+}
+
+// methodPath returns the path method is called at, /package.Service/Method
+func methodPath(method protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+}
+
+// decodeMessages parses the request messages of method from text: one JSON object,
+// or a JSON array of them for client streaming methods. Empty text is one empty message.
+func decodeMessages(method protoreflect.MethodDescriptor, text string, types *dynamicpb.Types) ([]proto.Message, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []proto.Message{dynamicpb.NewMessage(method.Input())}, nil
+	}
+
+	items := []json.RawMessage{json.RawMessage(text)}
+	if method.IsStreamingClient() && strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), &items); err != nil {
+			return nil, fmt.Errorf("invalid request messages: %w", err)
+		}
+	}
+
+	options := protojson.UnmarshalOptions{Resolver: types}
+	messages := make([]proto.Message, 0, len(items))
+	for i, item := range items {
+		message := dynamicpb.NewMessage(method.Input())
+		if err := options.Unmarshal(item, message); err != nil {
+			if len(items) > 1 {
+				return nil, fmt.Errorf("invalid request message %d: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("invalid request message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// encodeMessage returns message as JSON
+func encodeMessage(message proto.Message, types *dynamicpb.Types) (string, error) {
+	data, err := protojson.MarshalOptions{Resolver: types, Multiline: true, Indent: "  "}.Marshal(message)
+	return string(data), err
+}
+
+// messageTemplate returns a message of type desc as JSON with every field at its
+// default, nested messages filled in a few levels down
+func messageTemplate(desc protoreflect.MessageDescriptor) string {
+	message := dynamicpb.NewMessage(desc)
+	fillTemplate(message, grpcTemplateDepth)
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true, Multiline: true, Indent: "  "}.Marshal(message)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// fillTemplate sets the singular message fields of message to empty messages, depth
+// levels down. The well-known types are left unset since their JSON is not an object
+// of their fields.
+func fillTemplate(message protoreflect.Message, depth int) {
+	if depth == 0 {
+		return
+	}
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Message() == nil || field.IsList() || field.IsMap() || field.ContainingOneof() != nil {
+			continue
+		}
+		if strings.HasPrefix(string(field.Message().FullName()), "google.protobuf.") {
+			continue
+		}
+		nested := message.NewField(field)
+		fillTemplate(nested.Message(), depth-1)
+		message.Set(field, nested)
+	}
+}
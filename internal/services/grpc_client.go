@@ -0,0 +1,542 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxGRPCWebMessage bounds the size of one gRPC-Web response message
+const maxGRPCWebMessage = 64 << 20
+
+// GRPCRequest represents a gRPC call or a lookup of the services of a server
+type GRPCRequest struct {
+	URL     string            `json:"url"`
+	Headers []models.KeyValue `json:"headers"` // sent as metadata; values of -bin keys are base64
+	Body    string            `json:"body"`    // a models.GRPCBody as JSON
+	Timeout float64           `json:"timeout"`
+
+	// Settings overrides app-wide settings for this call
+	Settings *models.RequestSettings `json:"settings"`
+
+	// EnvironmentID is the active environment, used to pick the cookie jar and proxy override
+	EnvironmentID *int64 `json:"environmentId"`
+}
+
+// GRPCService calls gRPC and gRPC-Web services over the proxy and TLS settings of an
+// HTTPClient
+type GRPCService struct {
+	client *HTTPClient
+}
+
+// NewGRPCService creates a GRPCService connecting like client
+func NewGRPCService(client *HTTPClient) *GRPCService {
+	return &GRPCService{client: client}
+}
+
+// ListServices returns the services described by the .proto files of the request's
+// body or, without any, by the server's reflection service
+func (s *GRPCService) ListServices(ctx context.Context, req GRPCRequest) ([]models.GRPCServiceInfo, error) {
+	body, err := parseGRPCBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body.ProtoFiles) > 0 {
+		files, err := loadProtoFiles(ctx, body.ProtoFiles, body.ImportPaths)
+		if err != nil {
+			return nil, err
+		}
+		return describeServices(files, nil), nil
+	}
+	if body.Web {
+		return nil, errors.New("server reflection is not available over gRPC-Web; add the .proto files of the service")
+	}
+
+	ctx, cancel := withRequestTimeout(ctx, req.Timeout)
+	defer cancel()
+	conn, err := s.client.dialGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	files, names, err := reflectFiles(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return describeServices(files, names), nil
+}
+
+// Invoke calls the method of the request's body with its message, passing each
+// response message to onMessage as it arrives. Unary, server streaming and client
+// streaming methods are supported; a bidirectional stream sends every message before
+// reading. A call that fails on the server is a response with its status, not an error.
+func (s *GRPCService) Invoke(ctx context.Context, req GRPCRequest, onMessage func(models.GRPCMessage)) (*models.GRPCResponse, error) {
+	body, err := parseGRPCBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withRequestTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	var conn *grpc.ClientConn
+	var files *protoregistry.Files
+	if len(body.ProtoFiles) > 0 {
+		if files, err = loadProtoFiles(ctx, body.ProtoFiles, body.ImportPaths); err != nil {
+			return nil, err
+		}
+	} else if body.Web {
+		return nil, errors.New("server reflection is not available over gRPC-Web; add the .proto files of the service")
+	}
+	if !body.Web {
+		if conn, err = s.client.dialGRPC(ctx, req); err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if files == nil {
+			if files, _, err = reflectFiles(ctx, conn); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	method, err := findMethod(files, body.Method)
+	if err != nil {
+		return nil, err
+	}
+	types := dynamicpb.NewTypes(files)
+	messages, err := decodeMessages(method, body.Message, types)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &grpcCall{method: method, types: types, start: time.Now(), onMessage: onMessage}
+	if body.Web {
+		err = s.client.invokeGRPCWeb(ctx, req, call, messages)
+	} else {
+		err = call.invoke(ctx, conn, req.Headers, messages)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return call.response(), nil
+}
+
+// withRequestTimeout bounds ctx by timeout seconds when it is set
+func withRequestTimeout(ctx context.Context, timeout float64) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(timeout*float64(time.Second)))
+	}
+	return context.WithCancel(ctx)
+}
+
+// grpcTarget splits the URL of a gRPC request into the address to dial and whether
+// the connection uses TLS. Without a scheme the server is reached over TLS.
+func grpcTarget(rawURL string) (addr, host string, secure bool, err error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "grpcs://" + rawURL
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false, err
+	}
+	switch strings.ToLower(target.Scheme) {
+	case "grpc", "http":
+	case "grpcs", "https":
+		secure = true
+	default:
+		return "", "", false, errors.New("gRPC URL must start with grpc://, grpcs://, http:// or https://")
+	}
+	host = target.Hostname()
+	if host == "" {
+		return "", "", false, errors.New("gRPC URL has no host")
+	}
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if secure {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port), host, secure, nil
+}
+
+// dialGRPC connects to the server of req over HTTP/2 through the proxy chain, TLS
+// settings and client certificates that HTTP requests use. The TLS handshake is Go's
+// own, since gRPC needs HTTP/2 negotiated with ALPN.
+func (c *HTTPClient) dialGRPC(ctx context.Context, req GRPCRequest) (*grpc.ClientConn, error) {
+	addr, host, secure, err := grpcTarget(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The proxy chain is decided as for the equivalent HTTP URL
+	probeURL := url.URL{Scheme: "http", Host: addr}
+	if secure {
+		probeURL.Scheme = "https"
+	}
+	if proxy := c.proxyOverride(ExecuteRequest{Settings: req.Settings, EnvironmentID: req.EnvironmentID}); proxy != nil {
+		ctx = withProxyConfig(ctx, proxy)
+	}
+	probe, err := http.NewRequestWithContext(ctx, http.MethodPost, probeURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	transport := c.client.Transport.(*utlsTransport)
+	c.mu.Unlock()
+	proxies, err := transport.proxiesFor(probe)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := insecure.NewCredentials()
+	if secure {
+		skipVerify := req.Settings != nil && req.Settings.SkipTLSVerify
+		config, err := transport.tlsConfig(addr, host, tlsOptions{skipVerify: skipVerify})
+		if err != nil {
+			return nil, err
+		}
+		config.NextProtos = []string{"h2"}
+		creds = credentials.NewTLS(config)
+	}
+
+	return grpc.NewClient("passthrough:///"+addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithNoProxy(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return transport.dialChain(ctx, proxies, addr)
+		}),
+	)
+}
+
+// grpcMetadata turns request headers into outgoing metadata, decoding the base64
+// values of binary (-bin) keys
+func grpcMetadata(headers []models.KeyValue) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, h := range headers {
+		if !h.Enabled || h.Key == "" {
+			continue
+		}
+		key := strings.ToLower(h.Key)
+		value := h.Value
+		if strings.HasSuffix(key, "-bin") {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("value of binary header %s must be base64: %w", h.Key, err)
+			}
+			value = string(decoded)
+		}
+		md.Append(key, value)
+	}
+	return md, nil
+}
+
+// metadataList returns md as key-value pairs sorted by key, with binary values as base64
+func metadataList(md metadata.MD) []models.KeyValue {
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := []models.KeyValue{}
+	for _, key := range keys {
+		for _, value := range md[key] {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			list = append(list, models.KeyValue{Key: key, Value: value, Enabled: true})
+		}
+	}
+	return list
+}
+
+// grpcCall collects the outcome of one call
+type grpcCall struct {
+	method    protoreflect.MethodDescriptor
+	types     *dynamicpb.Types
+	start     time.Time
+	onMessage func(models.GRPCMessage)
+
+	status   *status.Status
+	headers  metadata.MD
+	trailers metadata.MD
+	messages []string
+	duration int64
+}
+
+// invoke runs the call on conn, sending every message and then reading the answers
+func (call *grpcCall) invoke(ctx context.Context, conn *grpc.ClientConn, headers []models.KeyValue, messages []proto.Message) error {
+	md, err := grpcMetadata(headers)
+	if err != nil {
+		return err
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	desc := &grpc.StreamDesc{
+		ClientStreams: call.method.IsStreamingClient(),
+		ServerStreams: call.method.IsStreamingServer(),
+	}
+	stream, err := conn.NewStream(ctx, desc, methodPath(call.method))
+	if err != nil {
+		call.finish(status.Convert(err))
+		return nil
+	}
+	for _, message := range messages {
+		if err := stream.SendMsg(message); err != nil {
+			// The server's status is read from the stream below
+			break
+		}
+	}
+	stream.CloseSend()
+
+	for {
+		message := dynamicpb.NewMessage(call.method.Output())
+		err := stream.RecvMsg(message)
+		if err == nil {
+			if err := call.received(message); err != nil {
+				return err
+			}
+		}
+		// The one answer of a non-streaming method comes with its status
+		if err != nil || !desc.ServerStreams || len(call.messages) >= maxStreamEvents {
+			if err == io.EOF {
+				err = nil
+			}
+			call.headers, _ = stream.Header()
+			call.trailers = stream.Trailer()
+			call.finish(status.Convert(err))
+			return nil
+		}
+	}
+}
+
+// received records a response message and passes it on
+func (call *grpcCall) received(message proto.Message) error {
+	text, err := encodeMessage(message, call.types)
+	if err != nil {
+		return fmt.Errorf("failed to decode response message: %w", err)
+	}
+	call.messages = append(call.messages, text)
+	if call.onMessage != nil {
+		call.onMessage(models.GRPCMessage{Data: text, Time: time.Since(call.start).Milliseconds()})
+	}
+	return nil
+}
+
+// finish records the status the call ended with
+func (call *grpcCall) finish(st *status.Status) {
+	call.duration = time.Since(call.start).Milliseconds()
+	call.status = st
+}
+
+// response returns the outcome of the call
+func (call *grpcCall) response() *models.GRPCResponse {
+	return &models.GRPCResponse{
+		Code:     int(call.status.Code()),
+		Status:   call.status.Code().String(),
+		Message:  call.status.Message(),
+		Headers:  metadataList(call.headers),
+		Trailers: metadataList(call.trailers),
+		Messages: append([]string{}, call.messages...),
+		Duration: call.duration,
+	}
+}
+
+// invokeGRPCWeb runs call as a gRPC-Web request to the base URL of req, through the
+// same proxy, TLS and cookie settings as other HTTP requests
+func (c *HTTPClient) invokeGRPCWeb(ctx context.Context, req GRPCRequest, call *grpcCall, messages []proto.Message) error {
+	base := strings.TrimSpace(req.URL)
+	switch {
+	case strings.HasPrefix(base, "grpc://"):
+		base = "http://" + strings.TrimPrefix(base, "grpc://")
+	case strings.HasPrefix(base, "grpcs://"):
+		base = "https://" + strings.TrimPrefix(base, "grpcs://")
+	case !strings.Contains(base, "://"):
+		base = "https://" + base
+	}
+
+	var body bytes.Buffer
+	for _, message := range messages {
+		data, err := proto.Marshal(message)
+		if err != nil {
+			return err
+		}
+		writeGRPCWebFrame(&body, 0, data)
+	}
+
+	ctx, _, err := c.requestContext(ctx, ExecuteRequest{Settings: req.Settings, EnvironmentID: req.EnvironmentID})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(base, "/")+methodPath(call.method), &body)
+	if err != nil {
+		return err
+	}
+	for _, h := range req.Headers {
+		if h.Enabled && h.Key != "" {
+			httpReq.Header.Add(h.Key, h.Value)
+		}
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc-web+proto")
+	httpReq.Header.Set("Accept", "application/grpc-web+proto")
+	httpReq.Header.Set("X-Grpc-Web", "1")
+	// An empty value stops the transport from adding its own Go-http-client User-Agent
+	if _, ok := httpReq.Header["User-Agent"]; !ok {
+		httpReq.Header["User-Agent"] = []string{""}
+	}
+
+	resp, _, _, err := c.doWithRedirects(httpReq, models.RedirectPolicy{}, c.cookieJar(req.EnvironmentID), nil, false)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	call.headers = metadata.MD{}
+	for key, values := range resp.Header {
+		key = strings.ToLower(key)
+		if key != "grpc-status" && key != "grpc-message" {
+			call.headers[key] = values
+		}
+	}
+	// A trailers-only answer carries the status in its headers
+	trailers := http.Header{}
+	for _, key := range []string{"Grpc-Status", "Grpc-Message"} {
+		if value := resp.Header.Get(key); value != "" {
+			trailers.Set(key, value)
+		}
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			flags, data, err := readGRPCWebFrame(reader)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read gRPC-Web response: %w", err)
+			}
+			if flags&0x80 != 0 {
+				frameTrailers, err := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n")))).ReadMIMEHeader()
+				if err != nil && err != io.EOF {
+					return fmt.Errorf("invalid gRPC-Web trailers: %w", err)
+				}
+				for key, values := range frameTrailers {
+					trailers[key] = values
+				}
+				continue
+			}
+			if len(call.messages) >= maxStreamEvents {
+				continue
+			}
+			message := dynamicpb.NewMessage(call.method.Output())
+			if err := proto.Unmarshal(data, message); err != nil {
+				return fmt.Errorf("failed to decode response message: %w", err)
+			}
+			if err := call.received(message); err != nil {
+				return err
+			}
+		}
+	}
+
+	call.trailers = metadata.MD{}
+	for key, values := range trailers {
+		key = strings.ToLower(key)
+		if key != "grpc-status" && key != "grpc-message" {
+			call.trailers[key] = values
+		}
+	}
+	call.finish(grpcWebStatus(resp.StatusCode, trailers))
+	return nil
+}
+
+// grpcWebStatus returns the status of a gRPC-Web call from its trailers or, without
+// a grpc-status trailer, from the HTTP status of the answer
+func grpcWebStatus(httpStatus int, trailers http.Header) *status.Status {
+	if value := trailers.Get("Grpc-Status"); value != "" {
+		code, err := strconv.Atoi(value)
+		if err != nil {
+			return status.Newf(codes.Unknown, "invalid grpc-status %q", value)
+		}
+		message, _ := url.PathUnescape(trailers.Get("Grpc-Message"))
+		return status.New(codes.Code(code), message)
+	}
+
+	// As mapped by the gRPC HTTP to gRPC status code mapping
+	message := fmt.Sprintf("server answered %d %s", httpStatus, http.StatusText(httpStatus))
+	switch httpStatus {
+	case http.StatusOK:
+		return status.New(codes.Unknown, "answer has no grpc-status")
+	case http.StatusBadRequest:
+		return status.New(codes.Internal, message)
+	case http.StatusUnauthorized:
+		return status.New(codes.Unauthenticated, message)
+	case http.StatusForbidden:
+		return status.New(codes.PermissionDenied, message)
+	case http.StatusNotFound:
+		return status.New(codes.Unimplemented, message)
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return status.New(codes.Unavailable, message)
+	default:
+		return status.New(codes.Unknown, message)
+	}
+}
+
+// writeGRPCWebFrame appends a length-prefixed frame to w
+func writeGRPCWebFrame(w *bytes.Buffer, flags byte, data []byte) {
+	var header [5]byte
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	w.Write(header[:])
+	w.Write(data)
+}
+
+// readGRPCWebFrame reads one length-prefixed frame from r. It returns io.EOF when r
+// ends between frames.
+func readGRPCWebFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("truncated frame")
+		}
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxGRPCWebMessage {
+		return 0, nil, fmt.Errorf("message of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, errors.New("truncated frame")
+	}
+	return header[0], data, nil
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var testProtoFiles = []string{filepath.Join("testdata", "grpc", "greeter.proto")}
+
+// testGreeter answers the methods of testdata/grpc/greeter.proto
+type testGreeter struct {
+	input, output protoreflect.MessageDescriptor
+}
+
+func newTestGreeter(t *testing.T) (*testGreeter, *protoregistry.Files) {
+	t.Helper()
+	files, err := loadProtoFiles(context.Background(), testProtoFiles, nil)
+	if err != nil {
+		t.Fatalf("loadProtoFiles: %v", err)
+	}
+	method, err := findMethod(files, "test.greeter.Greeter/SayHello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testGreeter{input: method.Input(), output: method.Output()}, files
+}
+
+// reply returns a HelloReply with text as its message
+func (g *testGreeter) reply(text string) proto.Message {
+	message := dynamicpb.NewMessage(g.output)
+	message.Set(g.output.Fields().ByName("message"), protoreflect.ValueOfString(text))
+	return message
+}
+
+// name returns the name field of a HelloRequest
+func (g *testGreeter) name(message *dynamicpb.Message) string {
+	return message.Get(g.input.Fields().ByName("name")).String()
+}
+
+// sayHello greets the name of request; the name "fail" is not found
+func (g *testGreeter) sayHello(ctx context.Context, request *dynamicpb.Message) (proto.Message, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs("x-echo", strings.Join(md.Get("x-token"), ","), "x-raw-bin", strings.Join(md.Get("x-raw-bin"), ",")))
+	grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "done"))
+	if g.name(request) == "fail" {
+		return nil, status.Error(codes.NotFound, "no such person")
+	}
+	return g.reply("Hello " + g.name(request)), nil
+}
+
+// serviceDesc returns the service with handlers working on dynamic messages
+func (g *testGreeter) serviceDesc() *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: "test.greeter.Greeter",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "SayHello",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				request := dynamicpb.NewMessage(g.input)
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				return g.sayHello(ctx, request)
+			},
+		}},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "StreamHellos",
+			ServerStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				request := dynamicpb.NewMessage(g.input)
+				if err := stream.RecvMsg(request); err != nil {
+					return err
+				}
+				count := request.Get(g.input.Fields().ByName("count")).Int()
+				for i := int64(1); i <= count; i++ {
+					if err := stream.SendMsg(g.reply(fmt.Sprintf("Hello %s #%d", g.name(request), i))); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}, {
+			StreamName:    "CollectHellos",
+			ClientStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				var names []string
+				for {
+					request := dynamicpb.NewMessage(g.input)
+					err := stream.RecvMsg(request)
+					if err == io.EOF {
+						return stream.SendMsg(g.reply("Hello " + strings.Join(names, " and ")))
+					}
+					if err != nil {
+						return err
+					}
+					names = append(names, g.name(request))
+				}
+			},
+		}},
+	}
+}
+
+// testResolver finds descriptors in the test files, then in the linked-in ones such
+// as those of the reflection service
+type testResolver struct {
+	files *protoregistry.Files
+}
+
+func (r testResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r testResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := r.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// startTestGRPCServer serves the greeter, and the reflection service of the given
+// version ("v1", "v1alpha" or "" for none), over TLS when cert is set
+func startTestGRPCServer(t *testing.T, reflectionVersion string, cert *tls.Certificate) string {
+	t.Helper()
+	greeter, files := newTestGreeter(t)
+
+	var options []grpc.ServerOption
+	if cert != nil {
+		options = append(options, grpc.Creds(credentials.NewServerTLSFromCert(cert)))
+	}
+	server := grpc.NewServer(options...)
+	server.RegisterService(greeter.serviceDesc(), struct{}{})
+
+	reflectionOptions := reflection.ServerOptions{Services: server, DescriptorResolver: testResolver{files}}
+	switch reflectionVersion {
+	case "v1":
+		reflectionpb.RegisterServerReflectionServer(server, reflection.NewServerV1(reflectionOptions))
+	case "v1alpha":
+		reflectionv1alphapb.RegisterServerReflectionServer(server, reflection.NewServer(reflectionOptions))
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+	return ln.Addr().String()
+}
+
+func grpcBody(method, message string, protoFiles []string, web bool) string {
+	body, _ := json.Marshal(models.GRPCBody{Method: method, Message: message, ProtoFiles: protoFiles, Web: web})
+	return string(body)
+}
+
+func TestGRPCListServices(t *testing.T) {
+	service := NewGRPCService(NewHTTPClient())
+
+	fromFiles, err := service.ListServices(context.Background(), GRPCRequest{Body: grpcBody("", "", testProtoFiles, false)})
+	if err != nil {
+		t.Fatalf("ListServices from files: %v", err)
+	}
+	if len(fromFiles) != 1 || fromFiles[0].Name != "test.greeter.Greeter" || len(fromFiles[0].Methods) != 3 {
+		t.Fatalf("services = %+v", fromFiles)
+	}
+	stream := fromFiles[0].Methods[1]
+	if stream.FullName != "test.greeter.Greeter/StreamHellos" || !stream.ServerStreaming || stream.ClientStreaming || stream.InputType != "test.greeter.HelloRequest" {
+		t.Errorf("method = %+v", stream)
+	}
+
+	// Templates fill in nested messages, but not the well-known types
+	var template map[string]any
+	if err := json.Unmarshal([]byte(fromFiles[0].Methods[0].Template), &template); err != nil {
+		t.Fatalf("template %q: %v", fromFiles[0].Methods[0].Template, err)
+	}
+	want := map[string]any{"name": "", "count": float64(0), "person": map[string]any{"email": "", "address": map[string]any{"city": ""}, "born": nil}}
+	if !reflect.DeepEqual(template, want) {
+		t.Errorf("template = %v, want %v", template, want)
+	}
+
+	// Reflection lists the same methods, along with the reflection service itself
+	addr := startTestGRPCServer(t, "v1", nil)
+	fromServer, err := service.ListServices(context.Background(), GRPCRequest{URL: "grpc://" + addr})
+	if err != nil {
+		t.Fatalf("ListServices by reflection: %v", err)
+	}
+	var names []string
+	for _, s := range fromServer {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"grpc.reflection.v1.ServerReflection", "test.greeter.Greeter"}) {
+		t.Errorf("reflected services = %v", names)
+	}
+	if !reflect.DeepEqual(fromServer[1], fromFiles[0]) {
+		t.Errorf("reflected service = %+v\nwant %+v", fromServer[1], fromFiles[0])
+	}
+
+	addr = startTestGRPCServer(t, "", nil)
+	if _, err := service.ListServices(context.Background(), GRPCRequest{URL: "grpc://" + addr}); err == nil || !strings.Contains(err.Error(), "does not support reflection") {
+		t.Errorf("expected a reflection error, got %v", err)
+	}
+}
+
+func TestGRPCInvoke(t *testing.T) {
+	addr := startTestGRPCServer(t, "v1", nil)
+	service := NewGRPCService(NewHTTPClient())
+	invoke := func(method, message string, onMessage func(models.GRPCMessage)) *models.GRPCResponse {
+		t.Helper()
+		resp, err := service.Invoke(context.Background(), GRPCRequest{
+			URL: "grpc://" + addr,
+			Headers: []models.KeyValue{
+				{Key: "X-Token", Value: "secret", Enabled: true},
+				{Key: "x-raw-bin", Value: "AAE=", Enabled: true},
+				{Key: "x-disabled", Value: "no", Enabled: false},
+			},
+			Body: grpcBody(method, message, nil, false),
+		}, onMessage)
+		if err != nil {
+			t.Fatalf("Invoke %s: %v", method, err)
+		}
+		return resp
+	}
+
+	resp := invoke("test.greeter.Greeter/SayHello", `{"name": "Ada"}`, nil)
+	if resp.Code != 0 || resp.Status != "OK" || len(resp.Messages) != 1 || !strings.Contains(resp.Messages[0], `"Hello Ada"`) {
+		t.Errorf("response = %+v", resp)
+	}
+	headers := make(map[string]string)
+	for _, h := range resp.Headers {
+		headers[h.Key] = h.Value
+	}
+	if headers["x-echo"] != "secret" || headers["x-raw-bin"] != "AAE=" {
+		t.Errorf("headers = %v", resp.Headers)
+	}
+	if !reflect.DeepEqual(resp.Trailers, []models.KeyValue{{Key: "x-trailer", Value: "done", Enabled: true}}) {
+		t.Errorf("trailers = %v", resp.Trailers)
+	}
+
+	// A failed call is a response with the server's status
+	resp = invoke("test.greeter.Greeter/SayHello", `{"name": "fail"}`, nil)
+	if resp.Code != int(codes.NotFound) || resp.Status != "NotFound" || resp.Message != "no such person" || len(resp.Messages) != 0 || len(resp.Trailers) != 1 {
+		t.Errorf("error response = %+v", resp)
+	}
+
+	var live []string
+	resp = invoke("test.greeter.Greeter/StreamHellos", `{"name": "Bob", "count": 3}`, func(m models.GRPCMessage) {
+		live = append(live, m.Data)
+	})
+	if resp.Code != 0 || len(resp.Messages) != 3 || !strings.Contains(resp.Messages[2], "Hello Bob #3") || !reflect.DeepEqual(live, resp.Messages) {
+		t.Errorf("stream response = %+v, live %v", resp, live)
+	}
+
+	resp = invoke("test.greeter.Greeter.CollectHellos", `[{"name": "Ann"}, {"name": "Cid"}]`, nil)
+	if len(resp.Messages) != 1 || !strings.Contains(resp.Messages[0], "Hello Ann and Cid") {
+		t.Errorf("client stream response = %+v", resp)
+	}
+
+	// Problems on our side are errors
+	for _, body := range []string{
+		grpcBody("test.greeter.Greeter/Missing", "{}", nil, false),
+		grpcBody("test.greeter.Greeter/SayHello", `{"unknown": 1}`, nil, false),
+		grpcBody("test.greeter.Greeter/SayHello", `[{"name": "a"}]`, nil, false),
+	} {
+		if _, err := service.Invoke(context.Background(), GRPCRequest{URL: "grpc://" + addr, Body: body}, nil); err == nil {
+			t.Errorf("expected an error for %s", body)
+		}
+	}
+	if _, err := service.Invoke(context.Background(), GRPCRequest{URL: "ftp://" + addr, Body: grpcBody("a.B/C", "", testProtoFiles, false)}, nil); err == nil {
+		t.Error("expected a URL error")
+	}
+}
+
+func TestGRPCThroughProxyAndTLS(t *testing.T) {
+	certificate, key := newTestCertificate(t, nil, nil, "127.0.0.1", true)
+	addr := startTestGRPCServer(t, "v1alpha", &tls.Certificate{Certificate: [][]byte{certificate.Raw}, PrivateKey: key})
+
+	proxyAddr, targets := startTestSOCKSProxy(t, "", "")
+	service := NewGRPCService(NewHTTPClient())
+	settings := &models.RequestSettings{
+		Proxy: &models.ProxyConfig{Enabled: true, SOCKS: "socks5h://" + proxyAddr},
+	}
+	req := GRPCRequest{
+		URL:      "localhost:" + strings.Split(addr, ":")[1], // TLS without a scheme
+		Body:     grpcBody("test.greeter.Greeter/SayHello", `{"name": "Eve"}`, nil, false),
+		Settings: settings,
+	}
+
+	// The test server's certificate is not trusted unless verification is skipped
+	if _, err := service.Invoke(context.Background(), req, nil); err == nil {
+		t.Fatal("expected a certificate error")
+	}
+	<-targets
+
+	// Reflection falls back to the v1alpha service
+	settings.SkipTLSVerify = true
+	resp, err := service.Invoke(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if resp.Code != 0 || len(resp.Messages) != 1 || !strings.Contains(resp.Messages[0], "Hello Eve") {
+		t.Errorf("response = %+v", resp)
+	}
+	if target := <-targets; !strings.HasPrefix(target, "localhost:") {
+		t.Errorf("proxy was asked for %q", target)
+	}
+}
+
+func TestGRPCWeb(t *testing.T) {
+	greeter, _ := newTestGreeter(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/test.greeter.Greeter/SayHello" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/grpc-web+proto" || r.Header.Get("X-Grpc-Web") != "1" {
+			http.Error(w, "not gRPC-Web", http.StatusBadRequest)
+			return
+		}
+		_, data, err := readGRPCWebFrame(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request := dynamicpb.NewMessage(greeter.input)
+		proto.Unmarshal(data, request)
+
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		if greeter.name(request) == "fail" {
+			// Trailers-only answer
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such person")
+			return
+		}
+		w.Header().Set("X-Echo", r.Header.Get("X-Token"))
+		var body bytes.Buffer
+		reply, _ := proto.Marshal(greeter.reply("Hello " + greeter.name(request)))
+		writeGRPCWebFrame(&body, 0, reply)
+		writeGRPCWebFrame(&body, 0x80, []byte("grpc-status: 0\r\ngrpc-message: \r\nx-trailer: done\r\n"))
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	service := NewGRPCService(NewHTTPClient())
+	invoke := func(method, name string) *models.GRPCResponse {
+		t.Helper()
+		resp, err := service.Invoke(context.Background(), GRPCRequest{
+			URL:     server.URL + "/api/",
+			Headers: []models.KeyValue{{Key: "X-Token", Value: "web", Enabled: true}},
+			Body:    grpcBody(method, `{"name": "`+name+`"}`, testProtoFiles, true),
+		}, nil)
+		if err != nil {
+			t.Fatalf("Invoke: %v", err)
+		}
+		return resp
+	}
+
+	resp := invoke("test.greeter.Greeter/SayHello", "Web")
+	if resp.Code != 0 || len(resp.Messages) != 1 || !strings.Contains(resp.Messages[0], "Hello Web") {
+		t.Errorf("response = %+v", resp)
+	}
+	if !reflect.DeepEqual(resp.Trailers, []models.KeyValue{{Key: "x-trailer", Value: "done", Enabled: true}}) {
+		t.Errorf("trailers = %v", resp.Trailers)
+	}
+	var echoed bool
+	for _, h := range resp.Headers {
+		echoed = echoed || (h.Key == "x-echo" && h.Value == "web")
+	}
+	if !echoed {
+		t.Errorf("headers = %v", resp.Headers)
+	}
+
+	if resp := invoke("test.greeter.Greeter/SayHello", "fail"); resp.Code != int(codes.NotFound) || resp.Message != "no such person" {
+		t.Errorf("trailers-only response = %+v", resp)
+	}
+	if resp := invoke("test.greeter.Greeter/StreamHellos", "x"); resp.Code != int(codes.Unimplemented) {
+		t.Errorf("response of unknown path = %+v", resp)
+	}
+
+	// Reflection needs HTTP/2
+	if _, err := service.ListServices(context.Background(), GRPCRequest{URL: server.URL, Body: grpcBody("", "", nil, true)}); err == nil {
+		t.Error("expected reflection over gRPC-Web to fail")
+	}
+}
+
+func TestGRPCMetadataHeaders(t *testing.T) {
+	if _, err := grpcMetadata([]models.KeyValue{{Key: "x-bin", Value: "not base64!", Enabled: true}}); err == nil {
+		t.Error("expected an error for a binary header that is not base64")
+	}
+	md, _ := grpcMetadata([]models.KeyValue{{Key: "A", Value: "1", Enabled: true}, {Key: "a", Value: "2", Enabled: true}})
+	if got := metadataList(md); !reflect.DeepEqual(got, []models.KeyValue{{Key: "a", Value: "1", Enabled: true}, {Key: "a", Value: "2", Enabled: true}}) {
+		t.Errorf("metadata = %v", got)
+	}
+}
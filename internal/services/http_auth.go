@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// applyAuth adds the credentials of auth to req. An Authorization or API key header the
+// user set themselves is kept. Digest credentials are only sent in answer to a
+// challenge, see digestRetry.
+func applyAuth(req *http.Request, auth *models.AuthConfig) {
+	if auth == nil {
+		return
+	}
+
+	switch auth.Type {
+	case models.AuthTypeBasic:
+		if req.Header.Get("Authorization") == "" {
+			req.SetBasicAuth(auth.Username, auth.Password)
+		}
+	case models.AuthTypeBearer:
+		if req.Header.Get("Authorization") == "" {
+			prefix := auth.Prefix
+			if prefix == "" {
+				prefix = "Bearer"
+			}
+			req.Header.Set("Authorization", prefix+" "+auth.Token)
+		}
+	case models.AuthTypeAPIKey:
+		if auth.Key == "" {
+			return
+		}
+		if auth.In == models.AuthInQuery {
+			// Appended rather than re-encoded so the order of the other parameters is kept
+			if _, ok := req.URL.Query()[auth.Key]; !ok {
+				param := url.QueryEscape(auth.Key) + "=" + url.QueryEscape(auth.Value)
+				if req.URL.RawQuery == "" {
+					req.URL.RawQuery = param
+				} else {
+					req.URL.RawQuery += "&" + param
+				}
+			}
+		} else if req.Header.Get(auth.Key) == "" {
+			req.Header.Set(auth.Key, auth.Value)
+		}
+	}
+}
+
+type authHeadersKey struct{}
+
+// withAuthHeaders records on ctx the headers other than Authorization that carry the
+// credentials of auth, such as the header of an API key, so that redirects to another
+// origin drop them along with Authorization
+func withAuthHeaders(ctx context.Context, auth *models.AuthConfig) context.Context {
+	if auth == nil || auth.Type != models.AuthTypeAPIKey || auth.Key == "" || auth.In == models.AuthInQuery {
+		return ctx
+	}
+	return context.WithValue(ctx, authHeadersKey{}, []string{auth.Key})
+}
+
+// authHeadersFrom returns the credential headers recorded on ctx by withAuthHeaders
+func authHeadersFrom(ctx context.Context) []string {
+	headers, _ := ctx.Value(authHeadersKey{}).([]string)
+	return headers
+}
+
+// digestRetry returns req again with an Authorization answering the Digest challenge of
+// resp, sent to the URL that issued it. It returns nil if resp carries no Digest
+// challenge or the request body cannot be sent again.
+func digestRetry(req *http.Request, resp *http.Response, auth *models.AuthConfig) (*http.Request, error) {
+	challenge := findDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return nil, nil
+	}
+
+	// The challenged request may differ from req after redirects
+	sent := resp.Request
+	retry := req.Clone(req.Context())
+	retry.Method, retry.URL, retry.Host = sent.Method, sent.URL, sent.Host
+	retry.Body, retry.GetBody, retry.ContentLength = nil, sent.GetBody, sent.ContentLength
+	if sent.GetBody != nil {
+		body, err := sent.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	} else if sent.Body != nil && sent.Body != http.NoBody {
+		return nil, nil
+	}
+
+	authorization, err := challenge.authorization(retry.Method, sent.URL.RequestURI(), auth.Username, auth.Password, newNonce())
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	return retry, nil
+}
+
+// digestChallenge is a WWW-Authenticate Digest challenge (RFC 7616)
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+}
+
+// findDigestChallenge returns the first Digest challenge of the WWW-Authenticate header
+// values with a supported algorithm, or nil if there is none
+func findDigestChallenge(values []string) *digestChallenge {
+	for _, challenge := range parseAuthChallenges(values) {
+		if challenge.scheme != "digest" || digestHash(challenge.params["algorithm"]) == nil {
+			continue
+		}
+		digest := &digestChallenge{
+			realm:     challenge.params["realm"],
+			nonce:     challenge.params["nonce"],
+			opaque:    challenge.params["opaque"],
+			algorithm: challenge.params["algorithm"],
+		}
+		for _, qop := range strings.Split(challenge.params["qop"], ",") {
+			if qop = strings.TrimSpace(qop); qop != "" {
+				digest.qop = append(digest.qop, strings.ToLower(qop))
+			}
+		}
+		return digest
+	}
+	return nil
+}
+
+// digestHash returns the hash of a Digest algorithm, or nil if it is not supported. An
+// empty algorithm is MD5.
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return nil
+}
+
+// authorization returns the Authorization header value answering the challenge for a
+// request of method to uri. Only the "auth" quality of protection is supported; the
+// integrity of the body ("auth-int") is not.
+func (c *digestChallenge) authorization(method, uri, username, password, cnonce string) (string, error) {
+	newHash := digestHash(c.algorithm)
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	qop := ""
+	if len(c.qop) > 0 {
+		for _, offered := range c.qop {
+			if offered == "auth" {
+				qop = offered
+			}
+		}
+		if qop == "" {
+			return "", fmt.Errorf("digest auth: unsupported qop %q", strings.Join(c.qop, ", "))
+		}
+	}
+
+	const nc = "00000001" // every request answers a fresh challenge
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Digest username=%s, realm=%s, nonce=%s, uri=%s",
+		quoteAuthParam(username), quoteAuthParam(c.realm), quoteAuthParam(c.nonce), quoteAuthParam(uri))
+	if c.algorithm != "" {
+		fmt.Fprintf(&b, ", algorithm=%s", c.algorithm)
+	}
+	fmt.Fprintf(&b, ", response=%s", quoteAuthParam(response))
+	if c.opaque != "" {
+		fmt.Fprintf(&b, ", opaque=%s", quoteAuthParam(c.opaque))
+	}
+	if qop != "" {
+		fmt.Fprintf(&b, ", qop=%s, nc=%s, cnonce=%s", qop, nc, quoteAuthParam(cnonce))
+	}
+	return b.String(), nil
+}
+
+// newNonce returns a random nonce, such as a Digest client nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// quoteAuthParam returns s as an HTTP quoted-string
+func quoteAuthParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// authChallenge is one challenge of a WWW-Authenticate header: a lowercased scheme and
+// its parameters by lowercased name
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseAuthChallenges parses the challenges of WWW-Authenticate header values; one value
+// may hold several, separated by commas like their parameters (RFC 9110 section 11.6.1)
+func parseAuthChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, s := range values {
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if s == "" {
+				break
+			}
+			scheme, rest := readAuthToken(s)
+			if scheme == "" {
+				// Skip what cannot be parsed, such as the padding of a token68
+				_, after, found := strings.Cut(s, ",")
+				if !found {
+					break
+				}
+				s = after
+				continue
+			}
+			challenge := authChallenge{scheme: strings.ToLower(scheme), params: map[string]string{}}
+			s = rest
+			for {
+				s = strings.TrimLeft(s, " \t,")
+				name, rest := readAuthToken(s)
+				rest = strings.TrimLeft(rest, " \t")
+				if name == "" || !strings.HasPrefix(rest, "=") {
+					// The next challenge, or a token68 this parser has no use for
+					break
+				}
+				var value string
+				value, s = readAuthValue(strings.TrimLeft(rest[1:], " \t"))
+				challenge.params[strings.ToLower(name)] = value
+			}
+			challenges = append(challenges, challenge)
+		}
+	}
+	return challenges
+}
+
+// readAuthToken splits a leading token off s
+func readAuthToken(s string) (token, rest string) {
+	i := 0
+	for i < len(s) && isAuthTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// readAuthValue splits a leading token or quoted-string off s, unquoting the latter
+func readAuthValue(s string) (value, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		return readAuthToken(s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// isAuthTokenChar reports whether c may appear in a token (RFC 9110 section 5.6.2)
+func isAuthTokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
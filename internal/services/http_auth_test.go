@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteAppliesAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization")+"|"+r.Header.Get("X-Api-Key")+"|"+r.URL.RawQuery)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		headers []models.KeyValue
+		auth    *models.AuthConfig
+		want    string
+	}{
+		{
+			name: "basic",
+			auth: &models.AuthConfig{Type: models.AuthTypeBasic, Username: "aladdin", Password: "open sesame"},
+			want: "Basic YWxhZGRpbjpvcGVuIHNlc2FtZQ==||",
+		},
+		{
+			name: "bearer",
+			auth: &models.AuthConfig{Type: models.AuthTypeBearer, Token: "abc"},
+			want: "Bearer abc||",
+		},
+		{
+			name: "bearer with prefix",
+			auth: &models.AuthConfig{Type: models.AuthTypeBearer, Token: "abc", Prefix: "Token"},
+			want: "Token abc||",
+		},
+		{
+			name: "api key header",
+			auth: &models.AuthConfig{Type: models.AuthTypeAPIKey, Key: "X-Api-Key", Value: "k1"},
+			want: "|k1|",
+		},
+		{
+			name: "api key query keeps parameter order",
+			url:  "?z=1&a=2",
+			auth: &models.AuthConfig{Type: models.AuthTypeAPIKey, Key: "api key", Value: "k&1", In: models.AuthInQuery},
+			want: "||z=1&a=2&api+key=k%261",
+		},
+		{
+			name:    "typed header wins",
+			headers: []models.KeyValue{{Key: "Authorization", Value: "Custom x", Enabled: true}},
+			auth:    &models.AuthConfig{Type: models.AuthTypeBearer, Token: "abc"},
+			want:    "Custom x||",
+		},
+		{
+			name: "none",
+			auth: &models.AuthConfig{Type: models.AuthTypeNone, Token: "abc"},
+			want: "||",
+		},
+	}
+
+	client := NewHTTPClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Execute(context.Background(), ExecuteRequest{
+				Method:  http.MethodGet,
+				URL:     server.URL + "/" + tt.url,
+				Headers: tt.headers,
+				Auth:    tt.auth,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body != tt.want {
+				t.Errorf("server saw %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestAuthorizationVectors(t *testing.T) {
+	// RFC 7616 section 3.9.1
+	tests := []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			challenge := findDigestChallenge([]string{
+				`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=` + tt.algorithm +
+					`, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			})
+			if challenge == nil {
+				t.Fatal("challenge not found")
+			}
+			got, err := challenge.authorization("GET", "/dir/index.html", "Mufasa", "Circle of Life",
+				"f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, `response="`+tt.response+`"`) {
+				t.Errorf("authorization = %s, want response %s", got, tt.response)
+			}
+			if !strings.Contains(got, `qop=auth, nc=00000001`) || !strings.Contains(got, `opaque="FQhe/`) {
+				t.Errorf("authorization = %s, missing qop or opaque", got)
+			}
+		})
+	}
+}
+
+func TestParseAuthChallenges(t *testing.T) {
+	challenges := parseAuthChallenges([]string{
+		`Bearer abc==, Basic realm="a, b", Digest realm="r", nonce="n\"1", algorithm=SHA-256`,
+		`Newauth`,
+	})
+	var schemes []string
+	for _, c := range challenges {
+		schemes = append(schemes, c.scheme)
+	}
+	if got := strings.Join(schemes, " "); got != "bearer basic digest newauth" {
+		t.Fatalf("schemes = %q", got)
+	}
+	if realm := challenges[1].params["realm"]; realm != "a, b" {
+		t.Errorf("basic realm = %q", realm)
+	}
+	if nonce := challenges[2].params["nonce"]; nonce != `n"1` {
+		t.Errorf("digest nonce = %q", nonce)
+	}
+}
+
+func TestExecuteAnswersDigestChallenge(t *testing.T) {
+	const realm, nonce = "test", "abc123"
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		params := map[string]string{}
+		if rest, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest "); ok {
+			params = parseAuthChallenges([]string{"Digest " + rest})[0].params
+		}
+		ha1 := md5hex("user:" + realm + ":pass")
+		ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+		want := md5hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+		if params["response"] != want || params["uri"] != r.URL.RequestURI() {
+			w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth", nonce="`+nonce+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	execute := func(password string) *models.Response {
+		t.Helper()
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method:   http.MethodPost,
+			URL:      server.URL + "/dir/index.html?x=1",
+			Body:     "payload",
+			BodyType: "text",
+			Auth:     &models.AuthConfig{Type: models.AuthTypeDigest, Username: "user", Password: password},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := execute("pass")
+	if resp.StatusCode != http.StatusOK || resp.Body != "payload" {
+		t.Fatalf("got %d %q, want the body echoed after the challenge", resp.StatusCode, resp.Body)
+	}
+	if attempts != 2 {
+		t.Errorf("server saw %d requests, want 2", attempts)
+	}
+
+	attempts = 0
+	if resp := execute("wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d with a wrong password, want 401", resp.StatusCode)
+	}
+	if attempts != 2 {
+		t.Errorf("server saw %d requests with a wrong password, want 2", attempts)
+	}
+}
+
+func TestResolveAuth(t *testing.T) {
+	service := NewCollectionService(newTestDB(t))
+	bearer := &models.AuthConfig{Type: models.AuthTypeBearer, Token: "shared"}
+	basic := &models.AuthConfig{Type: models.AuthTypeBasic, Username: "u"}
+
+	collection := &models.Collection{Name: "c", Auth: bearer}
+	if err := service.Create(collection); err != nil {
+		t.Fatal(err)
+	}
+	bare := &models.Collection{Name: "bare"}
+	if err := service.Create(bare); err != nil {
+		t.Fatal(err)
+	}
+	folder := func(collectionID int64, auth *models.AuthConfig) *int64 {
+		f := &models.Folder{CollectionID: collectionID, Name: "f", Auth: auth}
+		if err := service.CreateFolder(f); err != nil {
+			t.Fatal(err)
+		}
+		return &f.ID
+	}
+
+	tests := []struct {
+		name         string
+		collectionID int64
+		folderID     *int64
+		want         *models.AuthConfig
+	}{
+		{"collection", collection.ID, nil, bearer},
+		{"folder inherits", collection.ID, folder(collection.ID, &models.AuthConfig{Type: models.AuthTypeInherit}), bearer},
+		{"folder without auth", collection.ID, folder(collection.ID, nil), bearer},
+		{"folder overrides", collection.ID, folder(collection.ID, basic), basic},
+		{"folder stops inheritance", collection.ID, folder(collection.ID, &models.AuthConfig{Type: models.AuthTypeNone}), &models.AuthConfig{Type: models.AuthTypeNone}},
+		{"collection without auth", bare.ID, folder(bare.ID, nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.ResolveAuth(tt.collectionID, tt.folderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("ResolveAuth = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+const (
+	// responsePreviewSize is how much of a spooled body is sent to the UI
+	responsePreviewSize = 256 << 10
+
+	// maxSpooledResponses is how many spooled bodies are kept; older ones are deleted
+	maxSpooledResponses = 20
+)
+
+// bodyEncodingBase64 marks a body that is not UTF-8 text and was base64-encoded
+const bodyEncodingBase64 = "base64"
+
+// responseBody is a read response body: all of it, or a preview of a spooled one
+type responseBody struct {
+	data      []byte
+	size      int64
+	truncated bool
+	handle    string
+}
+
+// responseSpool keeps response bodies that are too large for memory in temporary files,
+// identified by random handles
+type responseSpool struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]string // handle -> path
+	order []string          // handles, oldest first
+}
+
+// newResponseSpool creates an empty responseSpool; its directory is made on first use
+func newResponseSpool() *responseSpool {
+	return &responseSpool{files: make(map[string]string)}
+}
+
+// create makes a new spool file and returns it with its handle
+func (s *responseSpool) create() (*os.File, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "postme-responses-")
+		if err != nil {
+			return nil, "", err
+		}
+		s.dir = dir
+	}
+	file, err := os.CreateTemp(s.dir, "body-*")
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	handle := hex.EncodeToString(id)
+	s.files[handle] = file.Name()
+	s.order = append(s.order, handle)
+
+	for len(s.order) > maxSpooledResponses {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		os.Remove(s.files[oldest])
+		delete(s.files, oldest)
+	}
+	return file, handle, nil
+}
+
+// open opens the spooled body of handle
+func (s *responseSpool) open(handle string) (*os.File, error) {
+	s.mu.Lock()
+	path, ok := s.files[handle]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.New("response body is no longer available, send the request again")
+	}
+	return os.Open(path)
+}
+
+// clear deletes every spooled body
+func (s *responseSpool) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+	s.files = make(map[string]string)
+	s.order = nil
+}
+
+// readResponseBody reads r into memory up to limit bytes. A longer body is copied in
+// full to a spool file and only its first responsePreviewSize bytes are kept.
+func readResponseBody(r io.Reader, limit int64, spool *responseSpool) (*responseBody, error) {
+	recorder := newBodyRecorder(limit, spool)
+	if _, err := io.Copy(recorder, r); err != nil {
+		recorder.finish()
+		return nil, err
+	}
+	return recorder.finish()
+}
+
+// bodyRecorder collects a body written to it in memory, moving it to a spool file once
+// it grows past limit bytes
+type bodyRecorder struct {
+	limit int64
+	spool *responseSpool
+
+	buf    bytes.Buffer
+	size   int64
+	file   *os.File
+	handle string
+}
+
+// newBodyRecorder creates a bodyRecorder spooling to spool past limit bytes
+func newBodyRecorder(limit int64, spool *responseSpool) *bodyRecorder {
+	return &bodyRecorder{limit: limit, spool: spool}
+}
+
+func (b *bodyRecorder) Write(p []byte) (int, error) {
+	if b.file == nil && int64(b.buf.Len()+len(p)) > b.limit {
+		file, handle, err := b.spool.create()
+		if err != nil {
+			return 0, fmt.Errorf("failed to spool response body: %w", err)
+		}
+		b.file, b.handle = file, handle
+		if _, err := file.Write(b.buf.Bytes()); err != nil {
+			return 0, fmt.Errorf("failed to spool response body: %w", err)
+		}
+	}
+
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+		// The buffer stopped at the limit; it still holds the preview when that is larger
+		if room := responsePreviewSize - b.buf.Len(); room > 0 {
+			b.buf.Write(p[:min(room, n)])
+		}
+	} else {
+		n, err = b.buf.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// finish returns the recorded body: all of it, or a preview when it was spooled
+func (b *bodyRecorder) finish() (*responseBody, error) {
+	if b.file == nil {
+		return &responseBody{data: b.buf.Bytes(), size: b.size}, nil
+	}
+	if err := b.file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to spool response body: %w", err)
+	}
+	preview := b.buf.Bytes()[:min(responsePreviewSize, b.buf.Len())]
+	return &responseBody{data: preview, size: b.size, truncated: true, handle: b.handle}, nil
+}
+
+// encodeResponseBody returns data as a string that survives JSON: UTF-8 text as is,
+// anything else base64-encoded. A preview may end inside a character, which is not
+// held against it.
+func encodeResponseBody(data []byte, truncated bool) (string, string) {
+	text := data
+	if truncated {
+		text = trimPartialRune(data)
+	}
+	if utf8.Valid(text) {
+		return string(text), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), bodyEncodingBase64
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// SaveResponseBody writes the full body of a response to path: the spooled file of
+// resp.BodyHandle when the body was truncated, otherwise resp.Body itself
+func (c *HTTPClient) SaveResponseBody(resp models.Response, path string) error {
+	if !resp.Truncated {
+		data := []byte(resp.Body)
+		if resp.BodyEncoding == bodyEncodingBase64 {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(resp.Body); err != nil {
+				return fmt.Errorf("invalid response body: %w", err)
+			}
+		}
+		return os.WriteFile(path, data, 0o644)
+	}
+
+	src, err := c.spool.open(resp.BodyHandle)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// ReleaseResponseBodies deletes every spooled response body
+func (c *HTTPClient) ReleaseResponseBodies() {
+	c.spool.clear()
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteSpoolsLargeResponse(t *testing.T) {
+	full := strings.Repeat("0123456789abcdef", 1<<16) // 1 MiB
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(full))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	defer client.ReleaseResponseBodies()
+	client.SetResponseMemoryLimit(512 << 10)
+
+	resp, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !resp.Truncated || resp.BodyHandle == "" {
+		t.Fatalf("expected a spooled body, got truncated=%v handle=%q", resp.Truncated, resp.BodyHandle)
+	}
+	if resp.Size != int64(len(full)) {
+		t.Errorf("size = %d, want %d", resp.Size, len(full))
+	}
+	if resp.Body != full[:responsePreviewSize] {
+		t.Errorf("preview has %d bytes, want the first %d", len(resp.Body), responsePreviewSize)
+	}
+
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := client.SaveResponseBody(*resp, path); err != nil {
+		t.Fatalf("SaveResponseBody: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != full {
+		t.Errorf("saved %d bytes, want the full %d", len(saved), len(full))
+	}
+
+	// Small bodies stay in memory
+	client.SetResponseMemoryLimit(0)
+	resp, err = client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Truncated || resp.Body != full {
+		t.Errorf("expected the full body under the default limit, truncated=%v", resp.Truncated)
+	}
+
+	client.ReleaseResponseBodies()
+	if err := client.SaveResponseBody(models.Response{Truncated: true, BodyHandle: "gone"}, path); err == nil {
+		t.Error("expected an error for a released body")
+	}
+}
+
+func TestExecuteKeepsBinaryBody(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0xff, 0xfe, 0x80}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(binary)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	resp, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.BodyEncoding != "base64" {
+		t.Fatalf("encoding = %q, want base64", resp.BodyEncoding)
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(resp.Body)
+	if !bytes.Equal(decoded, binary) {
+		t.Errorf("decoded body = %x, want %x", decoded, binary)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := client.SaveResponseBody(*resp, path); err != nil {
+		t.Fatalf("SaveResponseBody: %v", err)
+	}
+	if saved, _ := os.ReadFile(path); !bytes.Equal(saved, binary) {
+		t.Errorf("saved %x, want %x", saved, binary)
+	}
+}
+
+func TestEncodeResponseBodyPreviewEndingMidRune(t *testing.T) {
+	data := []byte("héllo wörld")
+	cut := data[:9] // ends with the first of the two bytes of ö
+
+	if text, encoding := encodeResponseBody(cut, true); encoding != "" || text != "héllo w" {
+		t.Errorf("preview = %q (%s), want %q", text, encoding, "héllo w")
+	}
+	if _, encoding := encodeResponseBody(cut, false); encoding != "base64" {
+		t.Error("a complete body with a broken character should be treated as binary")
+	}
+}
+
+func TestReadResponseBodyPreviewBeyondLimit(t *testing.T) {
+	spool := newResponseSpool()
+	defer spool.clear()
+
+	data := bytes.Repeat([]byte("0123456789"), responsePreviewSize/5)
+	// Written in chunks, most of them after spooling started
+	reader := struct{ io.Reader }{bytes.NewReader(data[:responsePreviewSize+10])}
+	body, err := readResponseBody(reader, 1000, spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !body.truncated || body.size != responsePreviewSize+10 || !bytes.Equal(body.data, data[:responsePreviewSize]) {
+		t.Errorf("preview of %d bytes, size %d, truncated %v; want the first %d bytes", len(body.data), body.size, body.truncated, responsePreviewSize)
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// maxCaptureBytes bounds how much traffic is kept per direction
+const maxCaptureBytes = 256 << 10
+
+// maxCapturedDataFrame bounds how much of each request DATA frame is shown in HTTP/2 summaries
+const maxCapturedDataFrame = 4 << 10
+
+// wireCapture records the plaintext bytes of one request's connection.
+// Captured requests always get a connection of their own, so the bytes belong to
+// that request alone.
+type wireCapture struct {
+	mu        sync.Mutex
+	protocol  string
+	sent      bytes.Buffer
+	received  bytes.Buffer
+	truncated bool
+}
+
+type wireCaptureKey struct{}
+
+// withWireCapture asks the transport to record the traffic of requests made with ctx
+func withWireCapture(ctx context.Context, capture *wireCapture) context.Context {
+	return context.WithValue(ctx, wireCaptureKey{}, capture)
+}
+
+// wireCaptureFrom returns the capture attached to ctx, or nil
+func wireCaptureFrom(ctx context.Context) *wireCapture {
+	capture, _ := ctx.Value(wireCaptureKey{}).(*wireCapture)
+	return capture
+}
+
+// wrap returns conn with its traffic recorded. protocol is "h2" for HTTP/2 connections.
+func (c *wireCapture) wrap(conn net.Conn, protocol string) net.Conn {
+	c.mu.Lock()
+	c.protocol = protocol
+	c.mu.Unlock()
+	return &captureConn{Conn: conn, capture: c}
+}
+
+func (c *wireCapture) record(buf *bytes.Buffer, p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if room := maxCaptureBytes - buf.Len(); len(p) > room {
+		p = p[:max(room, 0)]
+		c.truncated = true
+	}
+	buf.Write(p)
+}
+
+// result renders the captured traffic
+func (c *wireCapture) result() *models.RawCapture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sent.Len() == 0 {
+		return nil
+	}
+
+	raw := &models.RawCapture{Protocol: "HTTP/1.1", Truncated: c.truncated}
+	if c.protocol == "h2" {
+		raw.Protocol = "HTTP/2"
+		raw.Request = summarizeH2Frames(bytes.TrimPrefix(c.sent.Bytes(), []byte(http2.ClientPreface)), true)
+		raw.Response = summarizeH2Frames(c.received.Bytes(), false)
+	} else {
+		raw.Request = printableWire(c.sent.Bytes())
+		raw.Response = printableWire(responseHead(c.received.Bytes()))
+	}
+	return raw
+}
+
+// captureConn tees a connection's traffic into a wireCapture
+type captureConn struct {
+	net.Conn
+	capture *wireCapture
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.capture.record(&c.capture.received, p[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.capture.record(&c.capture.sent, p[:n])
+	}
+	return n, err
+}
+
+// roundTripOnce sends req on a transport that is discarded after the response
+func roundTripOnce(transport *http.Transport, req *http.Request) (*http.Response, error) {
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		transport.CloseIdleConnections()
+		return nil, err
+	}
+	// Close the connection once the body is done so it is not left open
+	resp.Body = &afterCloseBody{ReadCloser: resp.Body, after: transport.CloseIdleConnections}
+	return resp, nil
+}
+
+// capturingDial wraps a dial function so every connection it opens is recorded
+func capturingDial(dial func(ctx context.Context, network, addr string) (net.Conn, error), capture *wireCapture) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return capture.wrap(conn, negotiatedProtocol(conn)), nil
+	}
+}
+
+// responseHead returns the head of the final HTTP/1.x response, skipping
+// interim 1xx responses, without the body
+func responseHead(data []byte) []byte {
+	for {
+		end := bytes.Index(data, []byte("\r\n\r\n"))
+		if end < 0 {
+			return data
+		}
+		head := data[:end+4]
+		if !bytes.HasPrefix(head, []byte("HTTP/1.1 1")) || bytes.HasPrefix(head, []byte("HTTP/1.1 101")) {
+			return head
+		}
+		data = data[end+4:]
+	}
+}
+
+// summarizeH2Frames renders a stream of HTTP/2 frames as one line per frame, with
+// decoded header fields and, for frames sent by the client, the DATA payload
+func summarizeH2Frames(data []byte, sent bool) string {
+	framer := newCaptureFramer(data)
+
+	var b strings.Builder
+	for {
+		frame, err := framer.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(&b, "... (%v)\n", err)
+			break
+		}
+
+		switch f := frame.(type) {
+		case *http2.MetaHeadersFrame:
+			fmt.Fprintf(&b, "HEADERS stream=%d%s\n", f.StreamID, frameFlags(f.StreamEnded(), false))
+			for _, field := range f.Fields {
+				fmt.Fprintf(&b, "  %s: %s\n", field.Name, field.Value)
+			}
+		case *http2.DataFrame:
+			fmt.Fprintf(&b, "DATA stream=%d length=%d%s\n", f.StreamID, len(f.Data()), frameFlags(f.StreamEnded(), false))
+			if sent && len(f.Data()) > 0 {
+				payload := f.Data()
+				if len(payload) > maxCapturedDataFrame {
+					payload = payload[:maxCapturedDataFrame]
+				}
+				fmt.Fprintf(&b, "  %s\n", printableWire(payload))
+			}
+		case *http2.SettingsFrame:
+			fmt.Fprintf(&b, "SETTINGS%s", frameFlags(false, f.IsAck()))
+			f.ForeachSetting(func(s http2.Setting) error {
+				fmt.Fprintf(&b, " %s=%d", s.ID, s.Val)
+				return nil
+			})
+			b.WriteString("\n")
+		case *http2.WindowUpdateFrame:
+			fmt.Fprintf(&b, "WINDOW_UPDATE stream=%d increment=%d\n", f.StreamID, f.Increment)
+		case *http2.RSTStreamFrame:
+			fmt.Fprintf(&b, "RST_STREAM stream=%d code=%s\n", f.StreamID, f.ErrCode)
+		case *http2.GoAwayFrame:
+			fmt.Fprintf(&b, "GOAWAY last_stream=%d code=%s\n", f.LastStreamID, f.ErrCode)
+		case *http2.PingFrame:
+			fmt.Fprintf(&b, "PING%s\n", frameFlags(false, f.IsAck()))
+		default:
+			h := frame.Header()
+			fmt.Fprintf(&b, "%s stream=%d length=%d\n", h.Type, h.StreamID, h.Length)
+		}
+	}
+	return b.String()
+}
+
+// newCaptureFramer returns a framer reading captured HTTP/2 frames with header blocks decoded
+func newCaptureFramer(data []byte) *http2.Framer {
+	framer := http2.NewFramer(nil, bytes.NewReader(data))
+	decoder := hpack.NewDecoder(4096, nil)
+	decoder.SetAllowedMaxDynamicTableSize(1 << 16)
+	framer.ReadMetaHeaders = decoder
+	framer.MaxHeaderListSize = 1 << 20
+	return framer
+}
+
+func frameFlags(endStream, ack bool) string {
+	switch {
+	case endStream:
+		return " END_STREAM"
+	case ack:
+		return " ACK"
+	}
+	return ""
+}
+
+// printableWire converts captured bytes to text, escaping anything that is not valid UTF-8
+func printableWire(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, "\\x%02x", data[0])
+		} else {
+			b.Write(data[:size])
+		}
+		data = data[size:]
+	}
+	return b.String()
+}
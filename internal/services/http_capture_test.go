@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteCapturesHTTP1Traffic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reply", "yes")
+		w.Write([]byte("response-body"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	client.SetCaptureRawTraffic(true)
+
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "POST",
+		URL:      server.URL + "/echo",
+		Body:     "request-body",
+		BodyType: "text",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Raw == nil {
+		t.Fatal("expected raw capture")
+	}
+	if resp.Raw.Protocol != "HTTP/1.1" {
+		t.Errorf("protocol = %q", resp.Raw.Protocol)
+	}
+	if !strings.HasPrefix(resp.Raw.Request, "POST /echo HTTP/1.1\r\n") || !strings.HasSuffix(resp.Raw.Request, "\r\n\r\nrequest-body") {
+		t.Errorf("request = %q", resp.Raw.Request)
+	}
+	if !strings.HasPrefix(resp.Raw.Response, "HTTP/1.1 200 OK\r\n") || !strings.Contains(resp.Raw.Response, "X-Reply: yes") {
+		t.Errorf("response = %q", resp.Raw.Response)
+	}
+	if strings.Contains(resp.Raw.Response, "response-body") {
+		t.Error("response capture should stop at the head")
+	}
+
+	// Per-request settings override the app-wide switch
+	off := false
+	resp, err = client.Execute(context.Background(), ExecuteRequest{
+		Method:   "GET",
+		URL:      server.URL,
+		Settings: &models.RequestSettings{CaptureRaw: &off},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Raw != nil {
+		t.Error("expected no capture when disabled per request")
+	}
+}
+
+func TestExecuteCapturesHTTP2Frames(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	client := NewHTTPClient()
+	capture := true
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "GET",
+		URL:      server.URL + "/h2",
+		Settings: &models.RequestSettings{SkipTLSVerify: true, CaptureRaw: &capture},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Body != "HTTP/2.0" {
+		t.Fatalf("server saw %q, want HTTP/2.0", resp.Body)
+	}
+	if resp.Raw == nil || resp.Raw.Protocol != "HTTP/2" {
+		t.Fatalf("raw = %+v", resp.Raw)
+	}
+	if !strings.Contains(resp.Raw.Request, "HEADERS stream=1 END_STREAM\n") || !strings.Contains(resp.Raw.Request, "  :path: /h2\n") {
+		t.Errorf("request frames = %q", resp.Raw.Request)
+	}
+	if !strings.Contains(resp.Raw.Response, "  :status: 200\n") {
+		t.Errorf("response frames = %q", resp.Raw.Response)
+	}
+}
@@ -1,19 +1,18 @@
 package services
 
 import (
-	"bufio"
-	"bytes"
 	"compress/flate"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -21,42 +20,212 @@ import (
 	"github.com/SoulTraitor/postme/internal/models"
 	"github.com/andybalholm/brotli"
 	utls "github.com/refraction-networking/utls"
-	"golang.org/x/net/http2"
 )
 
-// Default User-Agent that mimics a modern browser
-const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-
 // HTTPClient handles HTTP request execution
 type HTTPClient struct {
-	client         *http.Client
-	useSystemProxy bool
-	mu             sync.Mutex
+	client           *http.Client
+	useSystemProxy   bool
+	manualProxy      models.ProxyConfig
+	reuseConnections bool
+	redirectPolicy   models.RedirectPolicy
+	tlsProfile       string
+	headerProfile    string
+	customHeaders    []models.KeyValue
+	captureRaw       bool
+	memoryLimit      int64
+	mu               sync.Mutex
+
+	// Client certificates and CAs; nil until SetCertificateService is called
+	certs *CertificateService
+
+	// Environments, for their proxy overrides; nil until SetEnvironmentService is called
+	environments *EnvironmentService
+
+	// Proxy auto-config scripts, kept across client rebuilds
+	pac *pacResolver
+
+	// Response bodies larger than memoryLimit
+	spool *responseSpool
+
+	// OAuth 2.0 token cache; nil until SetOAuth2Service is called
+	oauth2 *OAuth2Service
+
+	// Persistent cookie jar; nil until SetCookieService is called
+	cookies           *CookieService
+	cookieJarEnabled  bool
+	scopeCookiesByEnv bool
 }
 
-// utlsTransport wraps http.Transport to use uTLS for TLS fingerprint spoofing
+// utlsTransport wraps http.Transport to use uTLS for TLS fingerprint spoofing.
+// The fingerprint profile is chosen per request through tlsOptions.
 type utlsTransport struct {
-	proxyFunc func(*http.Request) (*url.URL, error)
+	proxyFunc proxyChainFunc
 	dialer    *net.Dialer
+	certs     *CertificateService // client certificates and CAs; may be nil
+
+	// Connection reuse across requests. Connections made without certificate
+	// verification are kept apart so verified requests never reuse them.
+	disableReuse  bool
+	plain         *http.Transport
+	plainInsecure *http.Transport
+	pool          *connPool
+
+	// Plain HTTP transports per proxy, since a chain picks the proxy before the
+	// request reaches a transport
+	proxiedMu    sync.Mutex
+	proxiedPlain map[string]*http.Transport
 }
 
+// newUTLSTransport creates a utlsTransport with its own connection pool
+func newUTLSTransport(proxyFunc proxyChainFunc, dialer *net.Dialer, certs *CertificateService, disableReuse bool) *utlsTransport {
+	t := &utlsTransport{
+		proxyFunc:    proxyFunc,
+		dialer:       dialer,
+		certs:        certs,
+		disableReuse: disableReuse,
+		pool:         newConnPool(),
+		proxiedPlain: make(map[string]*http.Transport),
+	}
+	t.plain = t.newPlainTransport(false, nil, nil)
+	t.plainInsecure = t.newPlainTransport(true, nil, nil)
+	return t
+}
+
+// newPlainTransport creates the standard transport used for plain HTTP requests,
+// connecting directly when proxyURL is nil. With a SOCKS proxy, every connection is
+// tunnelled through it. With a capture, every connection it dials is recorded. The
+// response header order is always recorded.
+func (t *utlsTransport) newPlainTransport(skipVerify bool, proxyURL *url.URL, capture *wireCapture) *http.Transport {
+	var proxy func(*http.Request) (*url.URL, error)
+	dial := t.dialer.DialContext
+	dialTLS := t.dialPlainTLS(skipVerify)
+	if proxyURL != nil && isSOCKSProxy(proxyURL) {
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return t.dialThroughProxy(ctx, proxyURL, addr)
+		}
+	} else if proxyURL != nil {
+		proxy = http.ProxyURL(proxyURL)
+	}
+	if capture != nil {
+		dial = capturingDial(dial, capture)
+		dialTLS = capturingDial(dialTLS, capture)
+	}
+	dial = headerOrderDial(dial)
+	dialTLS = headerOrderDial(dialTLS)
+
+	return &http.Transport{
+		Proxy:              proxy,
+		DialContext:        dial,
+		DialTLSContext:     dialTLS,
+		ForceAttemptHTTP2:  true,
+		DisableKeepAlives:  t.disableReuse,
+		DisableCompression: true,
+		IdleConnTimeout:    idleConnTimeout,
+	}
+}
+
+// RoundTrip sends req through the first proxy of its chain that can be reached
 func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	proxies, err := t.proxiesFor(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proxyURL := range proxies[:len(proxies)-1] {
+		resp, err := t.roundTripVia(req, proxyURL)
+		if err == nil || !proxyUnreachable(err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		// Nothing was sent; retry with the next proxy, rewinding the body if the
+		// transport already closed it
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+	return t.roundTripVia(req, proxies[len(proxies)-1])
+}
+
+// roundTripVia sends req through proxyURL, or directly when it is nil
+func (t *utlsTransport) roundTripVia(req *http.Request, proxyURL *url.URL) (*http.Response, error) {
 	// For HTTPS requests, use uTLS
 	if req.URL.Scheme == "https" {
-		return t.roundTripHTTPS(req)
+		return t.roundTripHTTPS(req, proxyURL)
 	}
 
 	// For HTTP requests, use standard transport
-	transport := &http.Transport{
-		Proxy:             t.proxyFunc,
-		DialContext:       t.dialer.DialContext,
-		ForceAttemptHTTP2: true,
+	skipVerify := tlsOptionsFrom(req.Context()).skipVerify
+	if capture := wireCaptureFrom(req.Context()); capture != nil {
+		return roundTripOnce(t.newPlainTransport(skipVerify, proxyURL, capture), req)
 	}
-	return transport.RoundTrip(req)
+	return t.plainTransport(skipVerify, proxyURL).RoundTrip(req)
+}
+
+// plainTransport returns the pooled transport for plain HTTP requests through proxyURL,
+// or for direct ones when proxyURL is nil
+func (t *utlsTransport) plainTransport(skipVerify bool, proxyURL *url.URL) *http.Transport {
+	if proxyURL == nil {
+		if skipVerify {
+			return t.plainInsecure
+		}
+		return t.plain
+	}
+
+	key := proxyURL.String()
+	if skipVerify {
+		key += "|insecure"
+	}
+
+	t.proxiedMu.Lock()
+	defer t.proxiedMu.Unlock()
+
+	transport, ok := t.proxiedPlain[key]
+	if !ok {
+		transport = t.newPlainTransport(skipVerify, proxyURL, nil)
+		t.proxiedPlain[key] = transport
+	}
+	return transport
+}
+
+// proxiesFor returns the proxy chain of req; it always has at least one entry
+func (t *utlsTransport) proxiesFor(req *http.Request) ([]*url.URL, error) {
+	if t.proxyFunc == nil {
+		return []*url.URL{nil}, nil
+	}
+	proxies, err := t.proxyFunc(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxies) == 0 {
+		return []*url.URL{nil}, nil
+	}
+	return proxies, nil
+}
+
+// CloseIdleConnections closes all pooled connections that are not serving a request
+func (t *utlsTransport) CloseIdleConnections() {
+	t.plain.CloseIdleConnections()
+	t.plainInsecure.CloseIdleConnections()
+	t.proxiedMu.Lock()
+	for _, transport := range t.proxiedPlain {
+		transport.CloseIdleConnections()
+	}
+	t.proxiedMu.Unlock()
+	t.pool.flush()
 }
 
-func (t *utlsTransport) roundTripHTTPS(req *http.Request) (*http.Response, error) {
+func (t *utlsTransport) roundTripHTTPS(req *http.Request, proxyURL *url.URL) (*http.Response, error) {
 	ctx := req.Context()
+	opts := tlsOptionsFrom(ctx)
 
 	// Determine target address
 	host := req.URL.Host
@@ -64,125 +233,170 @@ func (t *utlsTransport) roundTripHTTPS(req *http.Request) (*http.Response, error
 		host = host + ":443"
 	}
 
-	// Check for proxy
-	var conn net.Conn
-	var err error
+	// Captured requests get a connection of their own so the bytes are theirs alone
+	capture := wireCaptureFrom(ctx)
+	reuse := !t.disableReuse && capture == nil
 
-	if t.proxyFunc != nil {
-		proxyURL, proxyErr := t.proxyFunc(req)
-		if proxyErr == nil && proxyURL != nil {
-			// Connect through proxy
-			conn, err = t.dialThroughProxy(ctx, proxyURL, host)
-		} else {
-			// Direct connection
-			conn, err = t.dialer.DialContext(ctx, "tcp", host)
+	key := t.poolKey(proxyURL, host, opts)
+	if reuse {
+		if h2Conn, conn := t.pool.getH2(key); h2Conn != nil {
+			traceGotConn(ctx, conn, true)
+			return h2Conn.RoundTrip(req)
+		}
+		if transport := t.pool.getH1(key); transport != nil {
+			return transport.RoundTrip(req)
 		}
-	} else {
-		conn, err = t.dialer.DialContext(ctx, "tcp", host)
 	}
 
+	tlsConn, err := t.dialTLS(ctx, proxyURL, host, req.URL.Hostname(), opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create uTLS connection with Chrome fingerprint
-	tlsConn := utls.UClient(conn, &utls.Config{
-		ServerName: req.URL.Hostname(),
-	}, utls.HelloChrome_120)
-
-	// Perform TLS handshake
-	if err := tlsConn.Handshake(); err != nil {
-		conn.Close()
-		return nil, err
-	}
-
 	// Check if ALPN negotiated HTTP/2
-	alpn := tlsConn.ConnectionState().NegotiatedProtocol
+	alpn := negotiatedProtocol(tlsConn)
+	if capture != nil {
+		tlsConn = capture.wrap(tlsConn, alpn)
+	}
+	tlsConn = newHeaderOrderConn(tlsConn, alpn)
 
 	if alpn == "h2" {
 		// Use HTTP/2 transport
-		h2Transport := &http2.Transport{}
-		h2Conn, err := h2Transport.NewClientConn(tlsConn)
+		h2Conn, err := t.pool.h2Transport.NewClientConn(tlsConn)
 		if err != nil {
 			tlsConn.Close()
 			return nil, err
 		}
-		return h2Conn.RoundTrip(req)
+		traceGotConn(ctx, tlsConn, false)
+		if reuse {
+			t.pool.putH2(key, h2Conn, tlsConn)
+			return h2Conn.RoundTrip(req)
+		}
+
+		// Close the connection as soon as this request's stream is done
+		resp, err := h2Conn.RoundTrip(req)
+		if err != nil {
+			h2Conn.Close()
+			return nil, err
+		}
+		resp.Body = &afterCloseBody{ReadCloser: resp.Body, after: func() { h2Conn.Close() }}
+		return resp, nil
 	}
 
-	// Use HTTP/1.1
+	// Use HTTP/1.1. The transport takes over the connection we already dialed and
+	// dials further uTLS connections itself when its idle pool runs dry.
+	var pendingMu sync.Mutex
+	pending := tlsConn
 	transport := &http.Transport{
 		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return tlsConn, nil
+			pendingMu.Lock()
+			conn := pending
+			pending = nil
+			pendingMu.Unlock()
+
+			if conn != nil {
+				return conn, nil
+			}
+			conn, err := t.dialTLS(ctx, proxyURL, host, req.URL.Hostname(), opts)
+			if err != nil {
+				return nil, err
+			}
+			protocol := negotiatedProtocol(conn)
+			if capture != nil {
+				conn = capture.wrap(conn, protocol)
+			}
+			return newHeaderOrderConn(conn, protocol), nil
 		},
+		DisableKeepAlives:  t.disableReuse,
+		DisableCompression: true,
+		IdleConnTimeout:    idleConnTimeout,
+	}
+	if capture != nil {
+		return roundTripOnce(transport, req)
+	}
+	if reuse {
+		t.pool.putH1(key, transport)
 	}
 
 	return transport.RoundTrip(req)
 }
 
-func (t *utlsTransport) dialThroughProxy(ctx context.Context, proxyURL *url.URL, targetHost string) (net.Conn, error) {
-	// Connect to proxy
-	proxyHost := proxyURL.Host
-	if !strings.Contains(proxyHost, ":") {
-		if proxyURL.Scheme == "https" {
-			proxyHost = proxyHost + ":443"
-		} else {
-			proxyHost = proxyHost + ":80"
-		}
-	}
-
-	conn, err := t.dialer.DialContext(ctx, "tcp", proxyHost)
+// dialTLS opens a connection to addr, directly or through proxyURL, and performs the
+// TLS handshake of the request's fingerprint profile
+func (t *utlsTransport) dialTLS(ctx context.Context, proxyURL *url.URL, addr, serverName string, opts tlsOptions) (net.Conn, error) {
+	config, err := t.tlsConfig(addr, serverName, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Send CONNECT request
-	connectReq := &http.Request{
-		Method: "CONNECT",
-		URL:    &url.URL{Opaque: targetHost},
-		Host:   targetHost,
-		Header: make(http.Header),
-	}
+	var conn net.Conn
 
-	// Add proxy authentication if present
-	if proxyURL.User != nil {
-		password, _ := proxyURL.User.Password()
-		connectReq.SetBasicAuth(proxyURL.User.Username(), password)
+	if proxyURL != nil {
+		// Connect through proxy
+		conn, err = t.dialThroughProxy(ctx, proxyURL, addr)
+	} else {
+		// Direct connection
+		conn, err = t.dialer.DialContext(ctx, "tcp", addr)
 	}
 
-	if err := connectReq.Write(conn); err != nil {
-		conn.Close()
+	if err != nil {
 		return nil, err
 	}
 
-	// Read response
-	br := bufio.NewReader(conn)
-	resp, err := http.ReadResponse(br, connectReq)
-	if err != nil {
-		conn.Close()
-		return nil, err
+	traceTLSHandshakeStart(ctx)
+
+	if opts.profile.standard {
+		// Stock Go handshake
+		config.NextProtos = []string{"h2", "http/1.1"}
+		tlsConn := tls.Client(conn, config)
+		err = tlsConn.HandshakeContext(ctx)
+		traceTLSHandshakeDone(ctx, tlsConn.ConnectionState(), err)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
 	}
 
-	if resp.StatusCode != 200 {
+	// Create uTLS connection with browser fingerprint
+	tlsConn := utls.UClient(conn, toUTLSConfig(config), opts.profile.hello)
+
+	// Perform TLS handshake
+	err = tlsConn.Handshake()
+	traceTLSHandshakeDone(ctx, utlsConnectionState(tlsConn.ConnectionState()), err)
+	if err != nil {
 		conn.Close()
-		return nil, &net.OpError{Op: "dial", Err: &proxyError{resp.Status}}
+		return nil, err
 	}
 
-	return conn, nil
+	return tlsConn, nil
 }
 
-type proxyError struct {
-	status string
-}
-
-func (e *proxyError) Error() string {
-	return "proxy connect failed: " + e.status
+// poolKey identifies connections that can serve each other's requests
+func (t *utlsTransport) poolKey(proxyURL *url.URL, addr string, opts tlsOptions) string {
+	proxy := ""
+	if proxyURL != nil {
+		proxy = proxyURL.String()
+	}
+	key := proxy + "|" + addr + "|" + opts.profile.name
+	if opts.skipVerify {
+		key += "|insecure"
+	}
+	return key
 }
 
 // NewHTTPClient creates a new HTTPClient with browser-like TLS fingerprint
 func NewHTTPClient() *HTTPClient {
 	c := &HTTPClient{
-		useSystemProxy: true,
+		useSystemProxy:   true,
+		reuseConnections: true,
+		redirectPolicy:   defaultRedirectPolicy(),
+		tlsProfile:       models.DefaultTLSProfile,
+		headerProfile:    models.DefaultHeaderProfile,
+		cookieJarEnabled: true,
+		memoryLimit:      models.DefaultResponseMemoryLimit,
+		pac:              newPACResolver(),
+		spool:            newResponseSpool(),
 	}
 
 	c.rebuildClient()
@@ -195,19 +409,22 @@ func (c *HTTPClient) rebuildClient() {
 		KeepAlive: 30 * time.Second,
 	}
 
-	var proxyFunc func(*http.Request) (*url.URL, error)
-	if c.useSystemProxy {
-		proxyFunc = c.getProxyFunc()
-	}
+	proxyFunc := c.getProxyFunc()
 
-	transport := &utlsTransport{
-		proxyFunc: proxyFunc,
-		dialer:    dialer,
+	// Drop connections of the previous transport, its settings no longer apply
+	if c.client != nil {
+		c.client.CloseIdleConnections()
 	}
 
+	transport := newUTLSTransport(proxyFunc, dialer, c.certs, !c.reuseConnections)
+
 	c.client = &http.Client{
 		Transport: transport,
 		Timeout:   0, // We handle timeout via context
+		// Redirects are followed by doWithRedirects so every hop can be recorded
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 }
 
@@ -220,16 +437,268 @@ func (c *HTTPClient) SetUseSystemProxy(useProxy bool) {
 	c.rebuildClient()
 }
 
-// getProxyFunc returns a proxy function based on platform settings and environment variables.
-func (c *HTTPClient) getProxyFunc() func(*http.Request) (*url.URL, error) {
-	return func(req *http.Request) (*url.URL, error) {
-		// Windows reads the system proxy from registry. Other platforms currently
-		// fall through to the standard HTTP(S)_PROXY environment variables below.
-		if proxyURL := getSystemProxyURL(); proxyURL != "" {
-			return url.Parse(proxyURL)
+// SetManualProxy sets the manually configured proxy. When enabled it is used instead
+// of the system proxy. Requests may override it through ExecuteRequest.Settings or
+// their environment.
+func (c *HTTPClient) SetManualProxy(config models.ProxyConfig) error {
+	if err := validateProxyConfig(config); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.manualProxy = config
+	c.rebuildClient()
+	return nil
+}
+
+// SetEnvironmentService sets the store of environments, whose proxy overrides apply
+// to requests run in them
+func (c *HTTPClient) SetEnvironmentService(environments *EnvironmentService) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.environments = environments
+}
+
+// SetReuseConnections enables or disables keep-alive reuse of pooled connections.
+// When disabled, every request dials a fresh connection.
+func (c *HTTPClient) SetReuseConnections(reuse bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reuseConnections = reuse
+	c.rebuildClient()
+}
+
+// SetRedirectPolicy sets the app-wide redirect policy. Requests may override it
+// through ExecuteRequest.Settings.
+func (c *HTTPClient) SetRedirectPolicy(policy models.RedirectPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.redirectPolicy = policy
+}
+
+// SetTLSProfile sets the app-wide TLS fingerprint profile. Requests may override it
+// through ExecuteRequest.Settings.
+func (c *HTTPClient) SetTLSProfile(name string) error {
+	if _, err := lookupTLSProfile(name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tlsProfile = name
+	return nil
+}
+
+// SetDefaultHeaders sets the app-wide default header profile and the header list
+// used by the custom profile. Requests may override both through ExecuteRequest.Settings.
+func (c *HTTPClient) SetDefaultHeaders(profile string, custom []models.KeyValue) error {
+	if _, err := defaultHeaders(profile, custom, tlsProfile{}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.headerProfile = profile
+	c.customHeaders = custom
+	return nil
+}
+
+// SetCaptureRawTraffic enables or disables recording the raw wire traffic of requests.
+// Captured requests always use a fresh connection. Requests may override it through
+// ExecuteRequest.Settings.
+func (c *HTTPClient) SetCaptureRawTraffic(capture bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.captureRaw = capture
+}
+
+// SetResponseMemoryLimit sets the size in bytes above which response bodies are spooled
+// to a temporary file and only a preview is returned. Zero or less restores the default.
+func (c *HTTPClient) SetResponseMemoryLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit <= 0 {
+		limit = models.DefaultResponseMemoryLimit
+	}
+	c.memoryLimit = limit
+}
+
+// SetCertificateService sets the store of client certificates and trusted CAs.
+// Certificate changes apply to new connections; call FlushConnections to drop existing ones.
+func (c *HTTPClient) SetCertificateService(certs *CertificateService) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.certs = certs
+	c.rebuildClient()
+}
+
+// SetCookieService sets the store backing the persistent cookie jar
+func (c *HTTPClient) SetCookieService(cookies *CookieService) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cookies = cookies
+}
+
+// SetOAuth2Service sets the service obtaining and caching OAuth 2.0 tokens
+func (c *HTTPClient) SetOAuth2Service(oauth2 *OAuth2Service) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.oauth2 = oauth2
+}
+
+// httpClient returns the current http.Client, which settings changes replace
+func (c *HTTPClient) httpClient() *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.client
+}
+
+// oauth2Service returns the OAuth 2.0 service, or an error before it is set
+func (c *HTTPClient) oauth2Service() (*OAuth2Service, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.oauth2 == nil {
+		return nil, errors.New("oauth2: token cache not available")
+	}
+	return c.oauth2, nil
+}
+
+// oauth2Auth returns the auth sending the OAuth 2.0 access token of req, obtaining or
+// refreshing it first when needed. ctx must carry the proxy and TLS settings of req.
+func (c *HTTPClient) oauth2Auth(ctx context.Context, req ExecuteRequest) (*models.AuthConfig, error) {
+	service, err := c.oauth2Service()
+	if err != nil {
+		return nil, err
+	}
+	token, err := service.Token(ctx, c.httpClient().Do, req.Auth.OAuth2, envIDOf(req.EnvironmentID))
+	if err != nil {
+		return nil, err
+	}
+	return oauth2Auth(req.Auth.OAuth2, token), nil
+}
+
+// AuthorizeOAuth2 obtains a new OAuth 2.0 access token for the auth of req, with its
+// proxy and TLS settings, calling prompt with what the user must open or enter
+func (c *HTTPClient) AuthorizeOAuth2(ctx context.Context, req ExecuteRequest, prompt func(models.OAuth2Prompt)) (*models.OAuth2Token, error) {
+	if req.Auth == nil || req.Auth.Type != models.AuthTypeOAuth2 {
+		return nil, errors.New("oauth2: the request does not use OAuth 2.0")
+	}
+	service, err := c.oauth2Service()
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err = c.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return service.Authorize(ctx, c.httpClient().Do, req.Auth.OAuth2, envIDOf(req.EnvironmentID), prompt)
+}
+
+// envIDOf returns the ID of an environment, or 0 when none is active
+func envIDOf(envID *int64) int64 {
+	if envID == nil {
+		return 0
+	}
+	return *envID
+}
+
+// SetCookieJarEnabled enables or disables storing and sending cookies across requests
+func (c *HTTPClient) SetCookieJarEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cookieJarEnabled = enabled
+}
+
+// SetScopeCookiesByEnvironment makes each environment use its own cookie jar
+// instead of the shared one
+func (c *HTTPClient) SetScopeCookiesByEnvironment(scoped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scopeCookiesByEnv = scoped
+}
+
+// cookieJar returns the jar for a request run in envID, or nil if the jar is disabled
+func (c *HTTPClient) cookieJar(envID *int64) http.CookieJar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cookies == nil || !c.cookieJarEnabled {
+		return nil
+	}
+	var scope int64
+	if c.scopeCookiesByEnv && envID != nil {
+		scope = *envID
+	}
+	return c.cookies.Jar(scope)
+}
+
+// proxyOverride returns the proxy configuration that replaces the app-wide one for
+// req: its own, else its environment's. nil means no override.
+func (c *HTTPClient) proxyOverride(req ExecuteRequest) *models.ProxyConfig {
+	if req.Settings != nil && req.Settings.Proxy != nil {
+		return req.Settings.Proxy
+	}
+
+	c.mu.Lock()
+	environments := c.environments
+	c.mu.Unlock()
+	if environments == nil || req.EnvironmentID == nil {
+		return nil
+	}
+	env, err := environments.GetByID(*req.EnvironmentID)
+	if err != nil {
+		return nil
+	}
+	return env.Proxy
+}
+
+// FlushConnections closes all idle pooled connections
+func (c *HTTPClient) FlushConnections() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.client.CloseIdleConnections()
+}
+
+// getProxyFunc returns a proxy function based on the request's proxy override, the
+// manual proxy, or platform settings and environment variables, in that order.
+func (c *HTTPClient) getProxyFunc() proxyChainFunc {
+	manual, useSystemProxy, pac := c.manualProxy, c.useSystemProxy, c.pac
+	return func(req *http.Request) ([]*url.URL, error) {
+		if override := proxyConfigFrom(req.Context()); override != nil {
+			return pac.proxiesForURL(req.Context(), override, req.URL)
+		}
+		if manual.Enabled {
+			return pac.proxiesForURL(req.Context(), &manual, req.URL)
+		}
+		if !useSystemProxy {
+			return nil, nil
+		}
+
+		// Windows reads the system proxy from registry and Linux from the desktop
+		// settings. Otherwise fall through to the standard HTTP(S)_PROXY environment
+		// variables below.
+		if config := getSystemProxy(); config != nil {
+			return pac.proxiesForURL(req.Context(), config, req.URL)
 		}
 
-		return http.ProxyFromEnvironment(req)
+		return singleProxy(http.ProxyFromEnvironment)(req)
 	}
 }
 
@@ -241,6 +710,23 @@ type ExecuteRequest struct {
 	Body     string            `json:"body"`
 	BodyType string            `json:"bodyType"`
 	Timeout  float64           `json:"timeout"`
+
+	// Settings overrides app-wide settings for this request; nil fields inherit them
+	Settings *models.RequestSettings `json:"settings"`
+
+	// EnvironmentID is the active environment, used to pick the cookie jar and proxy override
+	EnvironmentID *int64 `json:"environmentId"`
+
+	// Auth is the resolved auth of the request; nil, "none" and "inherit" send none
+	Auth *models.AuthConfig `json:"auth"`
+
+	// OnProgress, if set, is called as the request body is sent and the response body
+	// received, from the goroutine doing the transfer
+	OnProgress func(models.TransferProgress) `json:"-"`
+
+	// OnEvent, if set, is called with each event of a text/event-stream response as it
+	// arrives
+	OnEvent func(models.ServerSentEvent) `json:"-"`
 }
 
 // Execute executes an HTTP request
@@ -252,8 +738,9 @@ func (c *HTTPClient) Execute(ctx context.Context, req ExecuteRequest) (*models.R
 		defer cancel()
 	}
 
-	// Create body reader and content type
+	// Create body reader and content type; files are streamed from disk
 	var bodyReader io.Reader
+	var stream *streamBody
 	var contentType string
 
 	if req.BodyType == "form-data" && req.Body != "" {
@@ -261,30 +748,22 @@ func (c *HTTPClient) Execute(ctx context.Context, req ExecuteRequest) (*models.R
 		var formItems []models.KeyValue
 		if err := json.Unmarshal([]byte(req.Body), &formItems); err == nil {
 			// Build multipart form
-			var buf bytes.Buffer
-			writer := multipart.NewWriter(&buf)
+			stream = &streamBody{}
+			writer := multipart.NewWriter(stream)
 			for _, item := range formItems {
 				if item.Enabled && item.Key != "" {
 					if item.Type == "file" && item.Value != "" {
 						// File upload
-						file, err := os.Open(item.Value)
-						if err != nil {
-							return nil, fmt.Errorf("failed to open form file %s: %w", item.Value, err)
-						}
 						// Get just the filename for the form
 						fileName := item.Value
 						if idx := strings.LastIndexAny(fileName, "/\\"); idx >= 0 {
 							fileName = fileName[idx+1:]
 						}
-						part, err := writer.CreateFormFile(item.Key, fileName)
-						if err != nil {
-							file.Close()
+						if _, err := writer.CreateFormFile(item.Key, fileName); err != nil {
 							return nil, fmt.Errorf("failed to create form file: %w", err)
 						}
-						_, err = io.Copy(part, file)
-						file.Close()
-						if err != nil {
-							return nil, fmt.Errorf("failed to write form file: %w", err)
+						if err := stream.addFile(item.Value); err != nil {
+							return nil, fmt.Errorf("failed to open form file %s: %w", item.Value, err)
 						}
 					} else {
 						// Text field
@@ -293,7 +772,6 @@ func (c *HTTPClient) Execute(ctx context.Context, req ExecuteRequest) (*models.R
 				}
 			}
 			writer.Close()
-			bodyReader = &buf
 			contentType = writer.FormDataContentType()
 		}
 	} else if req.BodyType == "x-www-form-urlencoded" && req.Body != "" {
@@ -310,37 +788,58 @@ func (c *HTTPClient) Execute(ctx context.Context, req ExecuteRequest) (*models.R
 			contentType = "application/x-www-form-urlencoded"
 		}
 	} else if req.BodyType == "binary" && req.Body != "" {
-		// Body contains file path - read the file
-		file, err := os.Open(req.Body)
-		if err != nil {
+		// Body contains file path - send the file
+		stream = &streamBody{}
+		if err := stream.addFile(req.Body); err != nil {
 			return nil, fmt.Errorf("failed to open binary file: %w", err)
 		}
-		defer file.Close()
-
-		// Read file into buffer
-		fileContent, err := io.ReadAll(file)
+		contentType = "application/octet-stream"
+	} else if req.BodyType == models.BodyTypeGraphQL && req.Body != "" {
+		// GET requests carry the query in the URL; other methods send it as JSON
+		target, payload, err := encodeGraphQL(req.Method, req.URL, req.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read binary file: %w", err)
+			return nil, err
+		}
+		req.URL = target
+		if payload != nil {
+			bodyReader = strings.NewReader(string(payload))
+			contentType = "application/json"
 		}
-		bodyReader = bytes.NewReader(fileContent)
-		contentType = "application/octet-stream"
 	} else if req.Body != "" && req.BodyType != "none" {
 		bodyReader = strings.NewReader(req.Body)
 	}
 
+	ctx, opts, err := c.requestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := req.Auth
+	if auth != nil && auth.Type == models.AuthTypeOAuth2 {
+		if auth, err = c.oauth2Auth(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = withAuthHeaders(ctx, auth)
+
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bodyReader)
 	if err != nil {
 		return nil, err
 	}
-
-	// Set headers
+	if stream != nil {
+		stream.attach(httpReq)
+	}
+	// Set headers; a repeated key sends the header once per value
 	for _, h := range req.Headers {
 		if h.Enabled && h.Key != "" {
-			httpReq.Header.Set(h.Key, h.Value)
+			httpReq.Header.Add(h.Key, h.Value)
 		}
 	}
 
+	applyAuth(httpReq, auth)
+
 	// Set content-type based on body type (if not already set by user)
 	if httpReq.Header.Get("Content-Type") == "" {
 		if contentType != "" {
@@ -353,96 +852,245 @@ func (c *HTTPClient) Execute(ctx context.Context, req ExecuteRequest) (*models.R
 			httpReq.Header.Set("Content-Type", "text/plain")
 		}
 	}
-
-	// Set default User-Agent if not already set (helps bypass Cloudflare detection)
-	if httpReq.Header.Get("User-Agent") == "" {
-		httpReq.Header.Set("User-Agent", defaultUserAgent)
+	if req.BodyType == models.BodyTypeGraphQL && httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/graphql-response+json, application/json")
 	}
 
-	// Set Accept header if not set (browser-like behavior)
-	if httpReq.Header.Get("Accept") == "" {
-		httpReq.Header.Set("Accept", "*/*")
+	// Fill in the default headers of the header profile. The browser profile matches
+	// the TLS fingerprint (helps bypass Cloudflare detection).
+	c.mu.Lock()
+	headerProfile, customHeaders := c.headerProfile, c.customHeaders
+	c.mu.Unlock()
+	if req.Settings != nil && req.Settings.HeaderProfile != "" {
+		headerProfile = req.Settings.HeaderProfile
+		if req.Settings.CustomHeaders != nil {
+			customHeaders = req.Settings.CustomHeaders
+		}
 	}
-
-	// Set Accept-Language if not set
-	if httpReq.Header.Get("Accept-Language") == "" {
-		httpReq.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	defaults, err := defaultHeaders(headerProfile, customHeaders, opts.profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range defaults {
+		if httpReq.Header.Get(h.Key) == "" {
+			httpReq.Header.Set(h.Key, h.Value)
+		}
 	}
 
-	// Set Accept-Encoding for compression (browser-like)
-	if httpReq.Header.Get("Accept-Encoding") == "" {
-		httpReq.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	// An empty value stops the transport from adding its own Go-http-client User-Agent
+	if _, ok := httpReq.Header["User-Agent"]; !ok {
+		httpReq.Header["User-Agent"] = []string{""}
 	}
 
-	// Set Sec-Fetch headers (modern browser behavior)
-	if httpReq.Header.Get("Sec-Fetch-Dest") == "" {
-		httpReq.Header.Set("Sec-Fetch-Dest", "empty")
+	// Signed as each hop is sent, once every header it covers is set. The body is read
+	// once the Content-Type is known, and before upload progress is tracked so signing
+	// is not reported as sending.
+	var sign func(*http.Request) error
+	if signsRequest(auth) {
+		signed, err := readSignedBody(httpReq, auth)
+		if err != nil {
+			return nil, err
+		}
+		sign = requestSigner(auth, signed)
 	}
-	if httpReq.Header.Get("Sec-Fetch-Mode") == "" {
-		httpReq.Header.Set("Sec-Fetch-Mode", "cors")
+	if req.OnProgress != nil {
+		trackUploadProgress(httpReq, req.OnProgress)
 	}
-	if httpReq.Header.Get("Sec-Fetch-Site") == "" {
-		httpReq.Header.Set("Sec-Fetch-Site", "cross-site")
+
+	// Execute request, following redirects per policy
+	c.mu.Lock()
+	policy, capture, memoryLimit := c.redirectPolicy, c.captureRaw, c.memoryLimit
+	c.mu.Unlock()
+	if req.Settings != nil && req.Settings.Redirect != nil {
+		policy = *req.Settings.Redirect
+	}
+	if req.Settings != nil && req.Settings.CaptureRaw != nil {
+		capture = *req.Settings.CaptureRaw
 	}
 
-	// Execute request
 	startTime := time.Now()
-	resp, err := c.client.Do(httpReq)
+	jar := c.cookieJar(req.EnvironmentID)
+	resp, hop, redirects, err := c.doWithRedirects(httpReq, policy, jar, sign, capture)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode == http.StatusUnauthorized && req.Auth != nil && req.Auth.Type == models.AuthTypeDigest {
+		// Answer the challenge once; a second 401 is the response
+		retry, err := digestRetry(httpReq, resp, req.Auth)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if retry != nil {
+			io.CopyN(io.Discard, resp.Body, maxRedirectDrain)
+			resp.Body.Close()
+			var more []models.RedirectHop
+			resp, hop, more, err = c.doWithRedirects(retry, policy, jar, sign, capture)
+			if err != nil {
+				return nil, err
+			}
+			redirects = append(redirects, more...)
+			httpReq = retry
+		}
+	}
 	defer resp.Body.Close()
 
 	duration := time.Since(startTime).Milliseconds()
 
-	// Read response body with decompression
-	var respBodyReader io.Reader = resp.Body
-	contentEncoding := resp.Header.Get("Content-Encoding")
-	switch strings.ToLower(contentEncoding) {
-	case "gzip":
-		gr, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decompress gzip: %w", err)
+	// Read the response body; an event stream is read event by event until it ends
+	var body *responseBody
+	var events []models.ServerSentEvent
+	if isEventStream(resp) {
+		stream := newEventStream(startTime, req.OnEvent)
+		var resend func(*http.Request) (*http.Response, error)
+		if req.Settings != nil && req.Settings.SSEReconnect {
+			resend = func(next *http.Request) (*http.Response, error) {
+				resp, _, _, err := c.doWithRedirects(next, policy, jar, sign, false)
+				return resp, err
+			}
+		}
+		recorder := newBodyRecorder(memoryLimit, c.spool)
+		err = readEventStream(ctx, httpReq, resp, stream, recorder, req.OnProgress, resend)
+		if err == nil {
+			body, err = recorder.finish()
+		}
+		events = stream.events
+	} else {
+		var reader io.Reader
+		var done func()
+		if reader, done, err = responseReader(resp, req.OnProgress); err == nil {
+			body, err = readResponseBody(reader, memoryLimit, c.spool)
+			done()
 		}
-		defer gr.Close()
-		respBodyReader = gr
-	case "deflate":
-		respBodyReader = flate.NewReader(resp.Body)
-	case "br":
-		respBodyReader = brotli.NewReader(resp.Body)
 	}
-
-	body, err := io.ReadAll(respBodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
-
-	// Extract headers
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
-	}
+	hop.timer.finish()
+	bodyText, bodyEncoding := encodeResponseBody(body.data, body.truncated)
 
 	return &models.Response{
 		StatusCode: resp.StatusCode,
 		Status:     resp.Status,
-		Headers:    headers,
-		Body:       string(body),
-		Size:       int64(len(body)),
+		URL:        resp.Request.URL.String(),
+		Headers:    hop.responseHeaders(resp),
+		Body:       bodyText,
+		Size:       body.size,
 		Duration:   duration,
+		Timing:     hop.timer.timing(),
+		Redirects:  redirects,
+
+		BodyEncoding: bodyEncoding,
+		Truncated:    body.truncated,
+		BodyHandle:   body.handle,
+
+		Events: events,
+
+		SentHeaders: hop.headers.headers(),
+		Raw:         hop.raw(),
 	}, nil
 }
 
+// requestContext returns ctx carrying the proxy override and the TLS options of req,
+// and those TLS options
+func (c *HTTPClient) requestContext(ctx context.Context, req ExecuteRequest) (context.Context, tlsOptions, error) {
+	// Apply the request's or environment's proxy override
+	if proxy := c.proxyOverride(req); proxy != nil {
+		ctx = withProxyConfig(ctx, proxy)
+	}
+
+	// Resolve the fingerprint profile and TLS options for this request
+	c.mu.Lock()
+	profileName := c.tlsProfile
+	c.mu.Unlock()
+	opts := tlsOptions{}
+	if req.Settings != nil {
+		opts.skipVerify = req.Settings.SkipTLSVerify
+		if req.Settings.TLSProfile != "" {
+			profileName = req.Settings.TLSProfile
+		}
+	}
+	profile, err := lookupTLSProfile(profileName)
+	if err != nil {
+		return nil, opts, err
+	}
+	opts.profile = profile
+	return withTLSOptions(ctx, opts), opts, nil
+}
+
+// responseReader returns the decompressed body of resp, reporting progress in bytes
+// received when onProgress is set. done is called once the body has been read.
+func responseReader(resp *http.Response, onProgress func(models.TransferProgress)) (r io.Reader, done func(), err error) {
+	var wireBody io.Reader = resp.Body
+	var download *progressReader
+	if onProgress != nil {
+		download = newProgressReader(resp.Body, models.TransferDownload, resp.ContentLength, onProgress)
+		wireBody = download
+	}
+
+	var closer io.Closer
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		gr, err := gzip.NewReader(wireBody)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress gzip: %w", err)
+		}
+		r, closer = gr, gr
+	case "deflate":
+		fr := flate.NewReader(wireBody)
+		r, closer = fr, fr
+	case "br":
+		r = brotli.NewReader(wireBody)
+	default:
+		r = wireBody
+	}
+
+	done = func() {
+		if closer != nil {
+			closer.Close()
+		}
+		if download != nil {
+			download.finish()
+		}
+	}
+	return r, done, nil
+}
+
+// BuildEventsJSON builds JSON string from the events of an event stream
+func BuildEventsJSON(events []models.ServerSentEvent) string {
+	if len(events) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(events)
+	return string(data)
+}
+
 // BuildRequestHeadersJSON builds JSON string from headers
 func BuildRequestHeadersJSON(headers []models.KeyValue) string {
 	data, _ := json.Marshal(headers)
 	return string(data)
 }
 
+// BuildRawCaptureJSON builds JSON string from captured wire traffic
+func BuildRawCaptureJSON(raw *models.RawCapture) string {
+	if raw == nil {
+		return ""
+	}
+	data, _ := json.Marshal(raw)
+	return string(data)
+}
+
 // BuildResponseHeadersJSON builds JSON string from response headers
-func BuildResponseHeadersJSON(headers map[string]string) string {
+func BuildResponseHeadersJSON(headers []models.KeyValue) string {
 	data, _ := json.Marshal(headers)
 	return string(data)
 }
+
+// BuildRedirectsJSON builds JSON string from a redirect chain
+func BuildRedirectsJSON(redirects []models.RedirectHop) string {
+	if len(redirects) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(redirects)
+	return string(data)
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// maxOrderHeadBytes bounds how much of an HTTP/1.x response head, or of one HTTP/2
+// header block, is buffered to learn its header order
+const maxOrderHeadBytes = 64 << 10
+
+// maxOrderResponses bounds how many HTTP/2 responses a connection remembers the
+// header fields of
+const maxOrderResponses = 64
+
+// headerOrderConn records the order of the response header fields received on a
+// connection, which http.Header forgets. It only looks at response heads and header
+// blocks; bodies pass through untouched.
+type headerOrderConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	h2     bool
+	broken bool
+
+	// HTTP/1.x: the head read so far for the request waiting for it
+	head    []byte
+	waiting *headerOrder
+
+	// HTTP/2: the frame being read, the header block being decoded and the fields
+	// of recent final responses, oldest first
+	frame     []byte
+	skip      int
+	decoder   *hpack.Decoder
+	block     []hpack.HeaderField
+	responses [][]hpack.HeaderField
+}
+
+// headerOrder is the response header order of one request sent on a headerOrderConn
+type headerOrder struct {
+	conn  *headerOrderConn
+	names []string
+}
+
+// newHeaderOrderConn returns conn with its response header order recorded. protocol is
+// "h2" for HTTP/2 connections.
+func newHeaderOrderConn(conn net.Conn, protocol string) *headerOrderConn {
+	c := &headerOrderConn{Conn: conn, h2: protocol == "h2"}
+	if c.h2 {
+		c.decoder = hpack.NewDecoder(4096, func(field hpack.HeaderField) {
+			c.block = append(c.block, field)
+		})
+	}
+	return c
+}
+
+// headerOrderDial wraps a dial function so every connection it opens records its
+// response header order
+func headerOrderDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return newHeaderOrderConn(conn, negotiatedProtocol(conn)), nil
+	}
+}
+
+// expect starts recording the order for a request about to be sent on c. An HTTP/1.x
+// connection serves one request at a time, so its next response head is that request's.
+func (c *headerOrderConn) expect() *headerOrder {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	order := &headerOrder{conn: c}
+	if !c.h2 {
+		c.head = c.head[:0]
+		c.waiting = order
+	}
+	return order
+}
+
+func (c *headerOrderConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		if c.h2 {
+			c.readFrames(p[:n])
+		} else {
+			c.readHead(p[:n])
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// readHead buffers p until the head of the final (non-1xx) response is complete, then
+// hands its header names to the waiting request
+func (c *headerOrderConn) readHead(p []byte) {
+	if c.waiting == nil {
+		return
+	}
+	c.head = append(c.head, p...)
+	for {
+		end := bytes.Index(c.head, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(c.head) > maxOrderHeadBytes {
+				c.waiting, c.head = nil, nil
+			}
+			return
+		}
+		head := c.head[:end]
+		if interimResponse(head) {
+			c.head = c.head[end+4:]
+			continue
+		}
+		c.waiting.names = headNames(head)
+		c.waiting, c.head = nil, nil
+		return
+	}
+}
+
+// interimResponse reports whether head is that of a 1xx response other than 101
+// Switching Protocols, which is followed by the final one
+func interimResponse(head []byte) bool {
+	_, status, _ := bytes.Cut(head, []byte(" "))
+	return bytes.HasPrefix(status, []byte("1")) && !bytes.HasPrefix(status, []byte("101"))
+}
+
+// headNames returns the header names of an HTTP/1.x message head, once per field line
+func headNames(head []byte) []string {
+	lines := strings.Split(string(head), "\r\n")
+	var names []string
+	for _, line := range lines[1:] {
+		if name, _, ok := strings.Cut(line, ":"); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readFrames follows the HTTP/2 frames in p, decoding every header block so the HPACK
+// table stays in step with the server's
+func (c *headerOrderConn) readFrames(p []byte) {
+	for len(p) > 0 && !c.broken {
+		if c.skip > 0 {
+			n := min(c.skip, len(p))
+			c.skip -= n
+			p = p[n:]
+			continue
+		}
+
+		// Complete the 9-byte frame header, then the payload of frames carrying headers
+		if len(c.frame) < 9 {
+			n := min(9-len(c.frame), len(p))
+			c.frame = append(c.frame, p[:n]...)
+			p = p[n:]
+			if len(c.frame) < 9 {
+				return
+			}
+		}
+		length := int(c.frame[0])<<16 | int(c.frame[1])<<8 | int(c.frame[2])
+		switch http2.FrameType(c.frame[3]) {
+		case http2.FrameHeaders, http2.FramePushPromise, http2.FrameContinuation:
+		default:
+			c.frame, c.skip = c.frame[:0], length
+			continue
+		}
+		if length > maxOrderHeadBytes {
+			c.broken = true
+			return
+		}
+		n := min(9+length-len(c.frame), len(p))
+		c.frame = append(c.frame, p[:n]...)
+		p = p[n:]
+		if len(c.frame) < 9+length {
+			return
+		}
+		c.headerFrame(c.frame)
+		c.frame = c.frame[:0]
+	}
+}
+
+// headerFrame decodes the header block fragment of a complete HEADERS, PUSH_PROMISE or
+// CONTINUATION frame
+func (c *headerOrderConn) headerFrame(frame []byte) {
+	typ, flags, payload := http2.FrameType(frame[3]), http2.Flags(frame[4]), frame[9:]
+	if typ != http2.FrameContinuation && flags.Has(http2.FlagHeadersPadded) {
+		if len(payload) == 0 || int(payload[0]) >= len(payload) {
+			c.broken = true
+			return
+		}
+		payload = payload[1 : len(payload)-int(payload[0])]
+	}
+	skip := 0
+	if typ == http2.FrameHeaders && flags.Has(http2.FlagHeadersPriority) {
+		skip = 5
+	} else if typ == http2.FramePushPromise {
+		skip = 4
+	}
+	if len(payload) < skip {
+		c.broken = true
+		return
+	}
+
+	if _, err := c.decoder.Write(payload[skip:]); err != nil {
+		c.broken = true
+		return
+	}
+	if !flags.Has(http2.FlagHeadersEndHeaders) {
+		return
+	}
+	if err := c.decoder.Close(); err != nil {
+		c.broken = true
+		return
+	}
+
+	// Keep final responses; interim ones, trailers and pushed requests have no
+	// :status or a 1xx one
+	fields := c.block
+	c.block = nil
+	if typ == http2.FramePushPromise || len(fields) == 0 || fields[0].Name != ":status" || strings.HasPrefix(fields[0].Value, "1") {
+		return
+	}
+	if len(c.responses) == maxOrderResponses {
+		c.responses = c.responses[1:]
+	}
+	c.responses = append(c.responses, fields)
+}
+
+// order returns the names of the response headers as received, once per field line,
+// or nil when the order is unknown. On HTTP/2 the response is the latest one on the
+// connection carrying every value of header.
+func (o *headerOrder) order(header http.Header) []string {
+	c := o.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.h2 {
+		return o.names
+	}
+	for i := len(c.responses) - 1; i >= 0; i-- {
+		if names, ok := matchFields(c.responses[i], header); ok {
+			return names
+		}
+	}
+	return nil
+}
+
+// matchFields returns the regular field names of fields if they hold every value of
+// header in order
+func matchFields(fields []hpack.HeaderField, header http.Header) ([]string, bool) {
+	received := make(http.Header, len(fields))
+	var names []string
+	for _, field := range fields {
+		if isPseudoHeader(field.Name) {
+			continue
+		}
+		received.Add(field.Name, field.Value)
+		names = append(names, field.Name)
+	}
+	for name, values := range header {
+		got := received[name]
+		if len(got) < len(values) {
+			return nil, false
+		}
+		for i, value := range values {
+			if got[i] != value {
+				return nil, false
+			}
+		}
+	}
+	return names, true
+}
+
+// headerOrderRecorder learns the response header order of a request from the
+// connection it was sent on
+type headerOrderRecorder struct {
+	mu      sync.Mutex
+	current *headerOrder
+}
+
+// attach adds a GotConn hook to trace that starts recording the header order of the
+// request on its connection, keeping any GotConn hook already set
+func (o *headerOrderRecorder) attach(trace *httptrace.ClientTrace) {
+	gotConn := trace.GotConn
+	trace.GotConn = func(info httptrace.GotConnInfo) {
+		if gotConn != nil {
+			gotConn(info)
+		}
+		if conn, ok := info.Conn.(*headerOrderConn); ok {
+			o.mu.Lock()
+			o.current = conn.expect()
+			o.mu.Unlock()
+		}
+	}
+}
+
+// order returns the names of the headers of resp as received, or nil when unknown
+func (o *headerOrderRecorder) order(resp *http.Response) []string {
+	o.mu.Lock()
+	current := o.current
+	o.mu.Unlock()
+
+	if current == nil {
+		return nil
+	}
+	return current.order(resp.Header)
+}
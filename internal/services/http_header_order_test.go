@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestExecuteKeepsWireHeaderOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Two responses on one keep-alive connection, the first after an interim 103
+	responses := []string{
+		"HTTP/1.1 103 Early Hints\r\nLink: </a.css>\r\n\r\n" +
+			"HTTP/1.1 200 OK\r\nZ-Last: 1\r\nSet-Cookie: a=1\r\nA-First: 2\r\nSet-Cookie: b=2\r\nContent-Length: 2\r\n\r\nok",
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\nB: 1\r\nA: 2\r\n\r\nok",
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for _, response := range responses {
+			if _, err := http.ReadRequest(reader); err != nil {
+				return
+			}
+			conn.Write([]byte(response))
+		}
+	}()
+
+	client := NewHTTPClient()
+	want := []string{
+		"Z-Last=1,Set-Cookie=a=1,A-First=2,Set-Cookie=b=2,Content-Length=2",
+		"Content-Length=2,B=1,A=2",
+	}
+	for i := range responses {
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method:   "GET",
+			URL:      "http://" + ln.Addr().String(),
+			Settings: &models.RequestSettings{HeaderProfile: models.HeaderProfileNone},
+		})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		var got []string
+		for _, h := range resp.Headers {
+			got = append(got, h.Key+"="+h.Value)
+		}
+		if strings.Join(got, ",") != want[i] {
+			t.Errorf("response %d: headers = %v, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestHeaderOrderConnHTTP2(t *testing.T) {
+	var frames bytes.Buffer
+	framer := http2.NewFramer(&frames, nil)
+	var block bytes.Buffer
+	encoder := hpack.NewEncoder(&block)
+	encode := func(fields ...string) []byte {
+		block.Reset()
+		for i := 0; i < len(fields); i += 2 {
+			encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+		}
+		return append([]byte(nil), block.Bytes()...)
+	}
+
+	framer.WriteSettings()
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: encode(":status", "103", "link", "</a.css>"), EndHeaders: true})
+	// A final response split over a padded HEADERS frame and a CONTINUATION
+	final := encode(":status", "200", "z-last", "1", "set-cookie", "a=1", "a-first", "2", "set-cookie", "b=2")
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: final[:3], PadLength: 4})
+	framer.WriteContinuation(1, true, final[3:])
+	framer.WriteData(1, false, bytes.Repeat([]byte("x"), 1000))
+	// A second stream reusing the dynamic table, then the first stream's trailers
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 3, BlockFragment: encode(":status", "200", "set-cookie", "a=1", "b", "x"), EndHeaders: true})
+	framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: encode("grpc-status", "0"), EndHeaders: true, EndStream: true})
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		// Deliver the frames in small pieces so they straddle reads
+		data := frames.Bytes()
+		for len(data) > 0 {
+			n := min(7, len(data))
+			server.Write(data[:n])
+			data = data[n:]
+		}
+		server.Close()
+	}()
+
+	conn := newHeaderOrderConn(client, "h2")
+	first, second := conn.expect(), conn.expect()
+	buf := make([]byte, 5)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	tests := []struct {
+		order  *headerOrder
+		header http.Header
+		want   string
+	}{
+		{first, http.Header{"Set-Cookie": {"a=1", "b=2"}, "A-First": {"2"}, "Z-Last": {"1"}}, "z-last,set-cookie,a-first,set-cookie"},
+		{second, http.Header{"Set-Cookie": {"a=1"}, "B": {"x"}}, "set-cookie,b"},
+		{second, http.Header{"Unknown": {"x"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.order.order(tt.header), ","); got != tt.want {
+			t.Errorf("order(%v) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// defaultHeaders returns the headers added to a request when the user did not set them
+func defaultHeaders(profile string, custom []models.KeyValue, tls tlsProfile) ([]models.KeyValue, error) {
+	switch profile {
+	case "", models.HeaderProfileBrowser:
+		return tls.headers, nil
+	case models.HeaderProfileMinimal:
+		var headers []models.KeyValue
+		for _, h := range tls.headers {
+			if h.Key == "User-Agent" {
+				headers = append(headers, h)
+			}
+		}
+		return append(headers, models.KeyValue{Key: "Accept", Value: "*/*"}), nil
+	case models.HeaderProfileNone:
+		return nil, nil
+	case models.HeaderProfileCustom:
+		var headers []models.KeyValue
+		for _, h := range custom {
+			if h.Enabled && h.Key != "" {
+				headers = append(headers, h)
+			}
+		}
+		return headers, nil
+	default:
+		return nil, fmt.Errorf("unknown header profile %q", profile)
+	}
+}
+
+// sentHeaderRecorder collects the header fields the transport writes to the wire,
+// including ones it adds itself such as Host and Content-Length. HTTP/2
+// pseudo-headers are left out since they only restate the method and URL.
+type sentHeaderRecorder struct {
+	mu      sync.Mutex
+	pending []models.KeyValue
+	sent    []models.KeyValue
+}
+
+// attach adds the recording hooks to trace
+func (r *sentHeaderRecorder) attach(trace *httptrace.ClientTrace) {
+	trace.WroteHeaderField = func(key string, values []string) {
+		if isPseudoHeader(key) {
+			return
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		for _, v := range values {
+			r.pending = append(r.pending, models.KeyValue{Key: key, Value: v, Enabled: true})
+		}
+	}
+	// A retried request writes its headers again; keep only the last complete set
+	trace.WroteHeaders = func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.sent = r.pending
+		r.pending = nil
+	}
+}
+
+// headers returns the header fields of the last request written
+func (r *sentHeaderRecorder) headers() []models.KeyValue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sent
+}
+
+// isPseudoHeader reports whether key is an HTTP/2 pseudo-header such as ":authority"
+func isPseudoHeader(key string) bool {
+	return strings.HasPrefix(key, ":")
+}
+
+// headerList converts response headers into the ordered list sent to the frontend,
+// keeping every value of repeated headers such as Set-Cookie. http.Header forgets the
+// order of the fields, so order lists their names as received on the wire, once per
+// field line, and each name takes the next of its values in turn. Headers come out
+// exactly as received, including values of one name interleaved with other names.
+// Only headers order does not account for, such as when it is nil for a connection
+// that did not record it, follow at the end sorted by name.
+func headerList(header http.Header, order []string) []models.KeyValue {
+	list := make([]models.KeyValue, 0, len(header))
+	used := make(map[string]int, len(header))
+	for _, name := range order {
+		name = http.CanonicalHeaderKey(name)
+		if values := header[name]; used[name] < len(values) {
+			list = append(list, models.KeyValue{Key: name, Value: values[used[name]], Enabled: true})
+			used[name]++
+		}
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name][used[name]:] {
+			list = append(list, models.KeyValue{Key: name, Value: value, Enabled: true})
+		}
+	}
+	return list
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteDefaultHeaderProfiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for name := range r.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w.Write([]byte(strings.Join(names, ",")))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		profile string
+		custom  []models.KeyValue
+		want    string
+	}{
+		{profile: models.HeaderProfileNone, want: "X-Typed"},
+		{profile: models.HeaderProfileMinimal, want: "Accept,User-Agent,X-Typed"},
+		{profile: models.HeaderProfileCustom, custom: []models.KeyValue{
+			{Key: "X-Team", Value: "qa", Enabled: true},
+			{Key: "X-Off", Value: "1"},
+		}, want: "X-Team,X-Typed"},
+		{profile: models.HeaderProfileBrowser, want: "Accept,Accept-Encoding,Accept-Language,Sec-Fetch-Dest,Sec-Fetch-Mode,Sec-Fetch-Site,User-Agent,X-Typed"},
+	}
+
+	client := NewHTTPClient()
+	for _, tt := range tests {
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method:   "GET",
+			URL:      server.URL,
+			Headers:  []models.KeyValue{{Key: "X-Typed", Value: "1", Enabled: true}},
+			Settings: &models.RequestSettings{HeaderProfile: tt.profile, CustomHeaders: tt.custom},
+		})
+		if err != nil {
+			t.Fatalf("%s: Execute: %v", tt.profile, err)
+		}
+		if resp.Body != tt.want {
+			t.Fatalf("%s: server received %q, want %q", tt.profile, resp.Body, tt.want)
+		}
+
+		// SentHeaders reports the wire headers, which also include Host
+		var sent []string
+		for _, h := range resp.SentHeaders {
+			if h.Key != "Host" {
+				sent = append(sent, h.Key)
+			}
+		}
+		sort.Strings(sent)
+		if got := strings.Join(sent, ","); got != tt.want {
+			t.Fatalf("%s: SentHeaders = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+
+	if err := client.SetDefaultHeaders("loud", nil); err == nil {
+		t.Fatal("expected an error for an unknown header profile")
+	}
+}
+
+func TestExecuteKeepsRepeatedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["X-Seen"] = r.Header.Values("X-Multi")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Header().Add("Vary", "Accept")
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	for _, capture := range []bool{false, true} {
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method: "GET",
+			URL:    server.URL,
+			Headers: []models.KeyValue{
+				{Key: "X-Multi", Value: "one", Enabled: true},
+				{Key: "X-Multi", Value: "two", Enabled: true},
+			},
+			Settings: &models.RequestSettings{HeaderProfile: models.HeaderProfileNone, CaptureRaw: &capture},
+		})
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+
+		var got []string
+		for _, h := range resp.Headers {
+			if h.Key != "Date" && h.Key != "Content-Length" {
+				got = append(got, h.Key+"="+h.Value)
+			}
+		}
+		want := "Set-Cookie=a=1,Set-Cookie=b=2,Vary=Accept,X-Seen=one,X-Seen=two"
+		if strings.Join(got, ",") != want {
+			t.Errorf("capture=%v: headers = %v, want %s", capture, got, want)
+		}
+	}
+}
+
+func TestHeaderListFollowsWireOrder(t *testing.T) {
+	header := http.Header{
+		"A":      {"1"},
+		"B":      {"2", "3"},
+		"C":      {"4"},
+		"Server": {"test"},
+	}
+	got := headerList(header, []string{"server", "b", "C", "B", "b", "x"})
+
+	var pairs []string
+	for _, h := range got {
+		pairs = append(pairs, h.Key+"="+h.Value)
+	}
+	if want := "Server=test,B=2,C=4,B=3,A=1"; strings.Join(pairs, ",") != want {
+		t.Errorf("headerList = %v, want %s", pairs, want)
+	}
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/dop251/goja"
+)
+
+const (
+	// pacCacheTTL is how long a loaded PAC script is used before it is fetched again
+	pacCacheTTL = 5 * time.Minute
+
+	// pacRetryTTL is how long a PAC script that failed to load is not retried
+	pacRetryTTL = 30 * time.Second
+
+	// pacFetchTimeout bounds downloading a PAC script, per WPAD candidate
+	pacFetchTimeout = 10 * time.Second
+
+	// pacEvalTimeout bounds one FindProxyForURL call, including its DNS lookups
+	pacEvalTimeout = 5 * time.Second
+
+	// maxPACSize is the largest PAC script that is loaded
+	maxPACSize = 1 << 20
+)
+
+// pacResolver loads proxy auto-config scripts, by location or through WPAD, and
+// evaluates them for requests. Loaded scripts are cached by location.
+type pacResolver struct {
+	mu      sync.Mutex
+	scripts map[string]*pacEntry
+
+	// PAC scripts are always fetched directly, never through a proxy
+	client *http.Client
+}
+
+type pacEntry struct {
+	script  *pacScript
+	err     error
+	expires time.Time
+}
+
+// newPACResolver creates a pacResolver with an empty cache
+func newPACResolver() *pacResolver {
+	return &pacResolver{
+		scripts: make(map[string]*pacEntry),
+		client: &http.Client{
+			Transport: &http.Transport{Proxy: nil},
+			Timeout:   pacFetchTimeout,
+		},
+	}
+}
+
+// proxiesForURL returns the proxy chain config gives for target. With a PAC script or
+// WPAD the script decides; otherwise the chain is the single fixed proxy.
+//
+// When WPAD finds no script, requests go directly like they do in browsers. A script
+// configured by location that cannot be loaded or run is an error.
+func (r *pacResolver) proxiesForURL(ctx context.Context, config *models.ProxyConfig, target *url.URL) ([]*url.URL, error) {
+	if !config.Enabled || config.PAC == "" && !config.AutoDetect || bypassProxy(target, config.Bypass) {
+		proxyURL, err := proxyForURL(config, target)
+		if err != nil {
+			return nil, err
+		}
+		return []*url.URL{proxyURL}, nil
+	}
+
+	script, err := r.script(ctx, config)
+	if err != nil {
+		if config.PAC == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("proxy auto-config %s: %w", config.PAC, err)
+	}
+
+	result, err := script.findProxy(target)
+	if err != nil {
+		return nil, fmt.Errorf("proxy auto-config: %w", err)
+	}
+	proxies := parsePACResult(result)
+	for _, proxyURL := range proxies {
+		if proxyURL != nil && config.Username != "" {
+			proxyURL.User = url.UserPassword(config.Username, config.Password)
+		}
+	}
+	return proxies, nil
+}
+
+// script returns the cached script of config, loading it when missing or expired
+func (r *pacResolver) script(ctx context.Context, config *models.ProxyConfig) (*pacScript, error) {
+	location := config.PAC
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.scripts[location]; ok && time.Now().Before(entry.expires) {
+		return entry.script, entry.err
+	}
+
+	var source string
+	var err error
+	if location == "" {
+		source, err = r.discover(ctx)
+	} else {
+		source, err = r.load(ctx, location)
+	}
+
+	entry := &pacEntry{err: err, expires: time.Now().Add(pacRetryTTL)}
+	if err == nil {
+		entry.script, entry.err = compilePAC(source)
+		if entry.err == nil {
+			entry.expires = time.Now().Add(pacCacheTTL)
+		}
+	}
+	r.scripts[location] = entry
+	return entry.script, entry.err
+}
+
+// load reads a PAC script from an http(s) URL, a file:// URL or a file path
+func (r *pacResolver) load(ctx context.Context, location string) (string, error) {
+	lower := strings.ToLower(location)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, location, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("server returned %s", resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxPACSize))
+		return string(data), err
+	}
+
+	file, err := os.Open(pacFilePath(location))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxPACSize))
+	return string(data), err
+}
+
+// discover looks for a PAC script through DNS-based WPAD
+func (r *pacResolver) discover(ctx context.Context) (string, error) {
+	for _, candidate := range wpadCandidates(localDomain()) {
+		if source, err := r.load(ctx, candidate); err == nil {
+			return source, nil
+		}
+	}
+	return "", errors.New("no WPAD server found")
+}
+
+// wpadCandidates returns the URLs WPAD tries for domain, from the most specific one
+// down to two labels: wpad.eng.example.com, then wpad.example.com. The bare name wpad,
+// which the resolver completes with its search domains, comes first.
+func wpadCandidates(domain string) []string {
+	candidates := []string{"http://wpad/wpad.dat"}
+	labels := strings.Split(strings.Trim(domain, "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, "http://wpad."+strings.Join(labels[i:], ".")+"/wpad.dat")
+	}
+	return candidates
+}
+
+// localDomain returns the DNS domain of this machine, from its host name or the
+// resolver configuration, or "" when it has none
+func localDomain() string {
+	if hostname, err := os.Hostname(); err == nil {
+		if _, domain, ok := strings.Cut(hostname, "."); ok {
+			return domain
+		}
+	}
+
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && (fields[0] == "domain" || fields[0] == "search") {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// pacFilePath turns a file:// URL into a path; other locations are paths already
+func pacFilePath(location string) string {
+	if !strings.HasPrefix(strings.ToLower(location), "file://") {
+		return location
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	path := u.Path
+	// file:///C:/proxy.pac has the path /C:/proxy.pac
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// validatePACLocation checks that location is an http(s) or file URL, or a file path
+func validatePACLocation(location string) error {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		return nil
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "file":
+		return nil
+	}
+	return fmt.Errorf("unsupported PAC script URL %q", location)
+}
+
+// parsePACResult turns the result of FindProxyForURL, such as
+// "PROXY a:8080; SOCKS5 b:1080; DIRECT", into a proxy chain. Like browsers, SOCKS
+// means SOCKS4. Unknown entries are skipped and an empty result is a direct connection.
+func parsePACResult(result string) []*url.URL {
+	var proxies []*url.URL
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.EqualFold(fields[0], "DIRECT") {
+			proxies = append(proxies, nil)
+			continue
+		}
+		if len(fields) != 2 {
+			continue
+		}
+
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "PROXY", "HTTP":
+			scheme = proxySchemeHTTP
+		case "HTTPS":
+			scheme = proxySchemeHTTPS
+		case "SOCKS", "SOCKS4":
+			scheme = proxySchemeSOCKS4
+		case "SOCKS5":
+			scheme = proxySchemeSOCKS5
+		default:
+			continue
+		}
+		proxyURL, err := url.Parse(scheme + "://" + fields[1])
+		if err != nil || proxyURL.Hostname() == "" {
+			continue
+		}
+		proxies = append(proxies, proxyURL)
+	}
+
+	if len(proxies) == 0 {
+		return []*url.URL{nil}
+	}
+	return proxies
+}
+
+// pacScript is a compiled PAC script. A goja runtime is not safe for concurrent
+// use, so calls are serialized.
+type pacScript struct {
+	mu   sync.Mutex
+	vm   *goja.Runtime
+	find goja.Callable
+}
+
+// compilePAC runs source with the standard PAC helper functions defined and returns
+// its FindProxyForURL
+func compilePAC(source string) (*pacScript, error) {
+	vm := goja.New()
+	vm.Set("dnsResolve", pacDNSResolve)
+	vm.Set("myIpAddress", pacMyIPAddress)
+	vm.Set("alert", func(string) {})
+	if _, err := vm.RunString(pacHelpers); err != nil {
+		return nil, err
+	}
+
+	// Top-level code of the script runs once, bounded like a call
+	timer := time.AfterFunc(pacEvalTimeout, func() { vm.Interrupt("PAC script timed out") })
+	_, err := vm.RunString(source)
+	timer.Stop()
+	vm.ClearInterrupt()
+	if err != nil {
+		return nil, err
+	}
+
+	find, ok := goja.AssertFunction(vm.Get("FindProxyForURL"))
+	if !ok {
+		return nil, errors.New("script does not define FindProxyForURL")
+	}
+	return &pacScript{vm: vm, find: find}, nil
+}
+
+// findProxy calls FindProxyForURL for target. Like browsers, https URLs are reduced to
+// their scheme and host so scripts never see their paths.
+func (s *pacScript) findProxy(target *url.URL) (string, error) {
+	scriptURL := *target
+	scriptURL.User = nil
+	if scriptURL.Scheme == "https" {
+		scriptURL.Path, scriptURL.RawPath, scriptURL.RawQuery, scriptURL.Fragment = "/", "", "", ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timer := time.AfterFunc(pacEvalTimeout, func() { s.vm.Interrupt("PAC script timed out") })
+	result, err := s.find(goja.Undefined(), s.vm.ToValue(scriptURL.String()), s.vm.ToValue(target.Hostname()))
+	timer.Stop()
+	s.vm.ClearInterrupt()
+	if err != nil {
+		return "", err
+	}
+	if goja.IsUndefined(result) || goja.IsNull(result) {
+		return "", nil
+	}
+	return result.String(), nil
+}
+
+// pacDNSResolve implements dnsResolve: the first IPv4 address of host, or null
+func pacDNSResolve(host string) any {
+	ctx, cancel := context.WithTimeout(context.Background(), pacEvalTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+	return ips[0].String()
+}
+
+// pacMyIPAddress implements myIpAddress: the local address used for outgoing
+// connections. Dialing UDP sends nothing, it only picks the route.
+func pacMyIPAddress() string {
+	conn, err := net.Dial("udp4", "198.51.100.1:80")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// pacHelpers defines the PAC functions that need no access to the network
+const pacHelpers = `
+function isPlainHostName(host) {
+	return host.indexOf('.') < 0;
+}
+
+function dnsDomainIs(host, domain) {
+	return host.length >= domain.length && host.substring(host.length - domain.length) === domain;
+}
+
+function localHostOrDomainIs(host, hostdom) {
+	return host === hostdom || hostdom.lastIndexOf(host + '.', 0) === 0;
+}
+
+function isResolvable(host) {
+	return dnsResolve(host) !== null;
+}
+
+function dnsDomainLevels(host) {
+	return host.split('.').length - 1;
+}
+
+function convert_addr(ipchars) {
+	var bytes = ipchars.split('.');
+	return ((bytes[0] & 0xff) << 24 | (bytes[1] & 0xff) << 16 | (bytes[2] & 0xff) << 8 | (bytes[3] & 0xff)) >>> 0;
+}
+
+function isInNet(host, pattern, mask) {
+	var ip = /^\d+\.\d+\.\d+\.\d+$/.test(host) ? host : dnsResolve(host);
+	if (ip === null) {
+		return false;
+	}
+	var m = convert_addr(mask);
+	return ((convert_addr(ip) & m) >>> 0) === ((convert_addr(pattern) & m) >>> 0);
+}
+
+function shExpMatch(str, shexp) {
+	var re = shexp.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+	return new RegExp('^' + re + '$').test(str);
+}
+
+var __pacDays = ['SUN', 'MON', 'TUE', 'WED', 'THU', 'FRI', 'SAT'];
+var __pacMonths = ['JAN', 'FEB', 'MAR', 'APR', 'MAY', 'JUN', 'JUL', 'AUG', 'SEP', 'OCT', 'NOV', 'DEC'];
+
+// __pacArgs splits a trailing "GMT" off a range function's arguments
+function __pacArgs(args) {
+	var list = Array.prototype.slice.call(args);
+	var gmt = list.length > 0 && list[list.length - 1] === 'GMT';
+	if (gmt) {
+		list.pop();
+	}
+	return { list: list, gmt: gmt, now: new Date() };
+}
+
+function __pacInRange(value, start, end) {
+	return start <= end ? value >= start && value <= end : value >= start || value <= end;
+}
+
+function weekdayRange() {
+	var a = __pacArgs(arguments);
+	var day = a.gmt ? a.now.getUTCDay() : a.now.getDay();
+	var start = __pacDays.indexOf(a.list[0]);
+	var end = a.list.length > 1 ? __pacDays.indexOf(a.list[1]) : start;
+	if (start < 0 || end < 0) {
+		return false;
+	}
+	return __pacInRange(day, start, end);
+}
+
+function dateRange() {
+	var a = __pacArgs(arguments);
+	var now = {
+		day: a.gmt ? a.now.getUTCDate() : a.now.getDate(),
+		month: a.gmt ? a.now.getUTCMonth() : a.now.getMonth(),
+		year: a.gmt ? a.now.getUTCFullYear() : a.now.getFullYear()
+	};
+	function parse(values) {
+		var date = {};
+		for (var i = 0; i < values.length; i++) {
+			var month = __pacMonths.indexOf(values[i]);
+			if (month >= 0) {
+				date.month = month;
+			} else if (values[i] > 31) {
+				date.year = Number(values[i]);
+			} else {
+				date.day = Number(values[i]);
+			}
+		}
+		return date;
+	}
+	// key orders dates by the fields present in the pattern
+	function key(pattern, date) {
+		return ('year' in pattern ? date.year * 10000 : 0) + ('month' in pattern ? date.month * 100 : 0) + ('day' in pattern ? date.day : 0);
+	}
+	if (a.list.length === 1) {
+		var only = parse(a.list);
+		return key(only, only) === key(only, now);
+	}
+	if (a.list.length === 0 || a.list.length % 2 !== 0) {
+		return false;
+	}
+	var start = parse(a.list.slice(0, a.list.length / 2));
+	var end = parse(a.list.slice(a.list.length / 2));
+	return __pacInRange(key(start, now), key(start, start), key(start, end));
+}
+
+function timeRange() {
+	var a = __pacArgs(arguments);
+	var v = a.list.map(Number);
+	var now = a.gmt
+		? a.now.getUTCHours() * 3600 + a.now.getUTCMinutes() * 60 + a.now.getUTCSeconds()
+		: a.now.getHours() * 3600 + a.now.getMinutes() * 60 + a.now.getSeconds();
+	switch (v.length) {
+	case 1:
+		return Math.floor(now / 3600) === v[0];
+	case 2:
+		return __pacInRange(Math.floor(now / 3600), v[0], v[1]);
+	case 4:
+		return __pacInRange(Math.floor(now / 60), v[0] * 60 + v[1], v[2] * 60 + v[3]);
+	case 6:
+		return __pacInRange(now, v[0] * 3600 + v[1] * 60 + v[2], v[3] * 3600 + v[4] * 60 + v[5]);
+	}
+	return false;
+}
+`
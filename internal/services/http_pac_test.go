@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// writeTestPAC writes a PAC script to a temporary file and returns its path
+func writeTestPAC(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	if err := os.WriteFile(path, []byte(source), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// closedPort returns a local address nothing listens on
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestPACHelperFunctions(t *testing.T) {
+	script, err := compilePAC(`
+		function FindProxyForURL(url, host) {
+			if (isPlainHostName(host)) return "DIRECT";
+			if (dnsDomainIs(host, ".intranet.example")) return "PROXY intranet:3128";
+			if (shExpMatch(url, "http://*.example.org/api/*")) return "PROXY api:8080";
+			if (isInNet(host, "10.0.0.0", "255.0.0.0")) return "SOCKS5 tunnel:1080";
+			if (localHostOrDomainIs(host, "www.example.net")) return "HTTPS secure:443";
+			return "PROXY fallback:8080; DIRECT";
+		}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://printer/status", "DIRECT"},
+		{"http://wiki.intranet.example/", "PROXY intranet:3128"},
+		{"http://svc.example.org/api/users", "PROXY api:8080"},
+		{"http://svc.example.org/docs", "PROXY fallback:8080; DIRECT"},
+		{"http://10.2.3.4/", "SOCKS5 tunnel:1080"},
+		{"http://www/", "DIRECT"},
+		{"https://www.example.net/secret/path", "HTTPS secure:443"},
+	}
+	for _, tt := range tests {
+		target, _ := url.Parse(tt.url)
+		got, err := script.findProxy(target)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if got != tt.want {
+			t.Errorf("FindProxyForURL(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	// https URLs are stripped to scheme and host before the script sees them
+	seen, err := compilePAC(`function FindProxyForURL(url, host) { return url; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse("https://user:pw@site.test/private?q=1")
+	if got, _ := seen.findProxy(target); got != "https://site.test/" {
+		t.Errorf("script saw %q", got)
+	}
+
+	if _, err := compilePAC(`var notAPAC = 1;`); err == nil {
+		t.Error("expected a script without FindProxyForURL to be rejected")
+	}
+}
+
+func TestParsePACResult(t *testing.T) {
+	tests := []struct {
+		result string
+		want   []string
+	}{
+		{"", []string{""}},
+		{"DIRECT", []string{""}},
+		{"PROXY a:8080; SOCKS b:1080; SOCKS5 c:1080; HTTPS d:443; DIRECT",
+			[]string{"http://a:8080", "socks4://b:1080", "socks5://c:1080", "https://d:443", ""}},
+		{"QUIC e:443; PROXY f:3128", []string{"http://f:3128"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, proxyURL := range parsePACResult(tt.result) {
+			if proxyURL == nil {
+				got = append(got, "")
+			} else {
+				got = append(got, proxyURL.String())
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePACResult(%q) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestWPADCandidates(t *testing.T) {
+	got := wpadCandidates("eng.corp.example.com")
+	want := []string{
+		"http://wpad/wpad.dat",
+		"http://wpad.eng.corp.example.com/wpad.dat",
+		"http://wpad.corp.example.com/wpad.dat",
+		"http://wpad.example.com/wpad.dat",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wpadCandidates = %q, want %q", got, want)
+	}
+	if got := wpadCandidates(""); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("wpadCandidates without a domain = %q", got)
+	}
+}
+
+func TestExecuteWithPACFallback(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer target.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer secure.Close()
+
+	// A stand-in forward proxy that answers every plain HTTP request itself
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pac proxy"))
+	}))
+	defer proxy.Close()
+	socksAddr, socksTargets := startTestSOCKSProxy(t, "", "")
+	dead := closedPort(t)
+
+	pac := writeTestPAC(t, fmt.Sprintf(`
+		function FindProxyForURL(url, host) {
+			if (url.indexOf("/only-dead") >= 0) return "PROXY %[1]s; DIRECT";
+			if (url.substring(0, 6) == "https:") return "PROXY %[1]s; SOCKS5 %[2]s";
+			return "PROXY %[1]s; PROXY %[3]s; DIRECT";
+		}`, dead, socksAddr, strings.TrimPrefix(proxy.URL, "http://")))
+
+	client := NewHTTPClient()
+	settings := &models.RequestSettings{
+		SkipTLSVerify: true,
+		Proxy:         &models.ProxyConfig{Enabled: true, PAC: pac},
+	}
+
+	tests := []struct {
+		url  string
+		body string
+		want string
+	}{
+		{url: target.URL + "/api", want: "pac proxy"},
+		{url: target.URL + "/api", body: "payload", want: "pac proxy"},
+		{url: target.URL + "/only-dead", want: "direct"},
+		{url: secure.URL, want: "secure"},
+	}
+	for _, tt := range tests {
+		method := "GET"
+		if tt.body != "" {
+			method = "POST"
+		}
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method:   method,
+			URL:      tt.url,
+			Body:     tt.body,
+			BodyType: "text",
+			Settings: settings,
+		})
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, tt.url, err)
+		}
+		if resp.Body != tt.want {
+			t.Errorf("%s %s: answered by %q, want %q", method, tt.url, resp.Body, tt.want)
+		}
+	}
+
+	select {
+	case got := <-socksTargets:
+		if got != strings.TrimPrefix(secure.URL, "https://") {
+			t.Errorf("SOCKS proxy was asked for %s", got)
+		}
+	default:
+		t.Error("https request did not fall back to the SOCKS proxy")
+	}
+
+	// A PAC location that cannot be loaded is reported
+	_, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "GET",
+		URL:      target.URL,
+		Settings: &models.RequestSettings{Proxy: &models.ProxyConfig{Enabled: true, PAC: pac + ".missing"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "proxy auto-config") {
+		t.Errorf("expected a PAC load error, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// idleConnTimeout is how long a pooled connection may stay unused before it is closed
+const idleConnTimeout = 90 * time.Second
+
+// connPool caches uTLS connections so repeated HTTPS requests skip DNS, TCP and TLS setup.
+//
+// Keys combine proxy, target address and TLS fingerprint (see utlsTransport.poolKey).
+// HTTP/2 servers share one multiplexed ClientConn per key; HTTP/1.1 servers get a
+// dedicated http.Transport per key that keeps its own idle keep-alive connections.
+type connPool struct {
+	mu          sync.Mutex
+	h2Transport *http2.Transport
+	h2          map[string]pooledH2
+	h1          map[string]*http.Transport
+}
+
+// pooledH2 is a pooled HTTP/2 connection and the connection it runs on
+type pooledH2 struct {
+	cc   *http2.ClientConn
+	conn net.Conn
+}
+
+// newConnPool creates an empty connPool
+func newConnPool() *connPool {
+	return &connPool{
+		h2Transport: &http2.Transport{
+			IdleConnTimeout: idleConnTimeout,
+			// Responses are decompressed by Execute; never add Accept-Encoding on our own
+			DisableCompression: true,
+		},
+		h2: make(map[string]pooledH2),
+		h1: make(map[string]*http.Transport),
+	}
+}
+
+// getH2 returns a pooled HTTP/2 connection for key that can take another request,
+// and the connection it runs on
+func (p *connPool) getH2(key string) (*http2.ClientConn, net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pooled, ok := p.h2[key]
+	if !ok {
+		return nil, nil
+	}
+	if !pooled.cc.CanTakeNewRequest() {
+		// Closed, idle-timed-out or received GOAWAY; the next request dials again
+		delete(p.h2, key)
+		return nil, nil
+	}
+	return pooled.cc, pooled.conn
+}
+
+// putH2 stores an HTTP/2 connection for key, running on conn
+func (p *connPool) putH2(key string, cc *http2.ClientConn, conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if old, ok := p.h2[key]; ok && old.cc != cc {
+		go old.cc.Shutdown(context.Background())
+	}
+	p.h2[key] = pooledH2{cc: cc, conn: conn}
+}
+
+// getH1 returns the HTTP/1.1 transport for key, if the server is known to speak HTTP/1.1
+func (p *connPool) getH1(key string) *http.Transport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.h1[key]
+}
+
+// putH1 stores an HTTP/1.1 transport for key
+func (p *connPool) putH1(key string, transport *http.Transport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if old, ok := p.h1[key]; ok && old != transport {
+		old.CloseIdleConnections()
+	}
+	p.h1[key] = transport
+}
+
+// flush forgets all pooled connections. Idle ones are closed right away; ones
+// serving a request are left to finish it.
+func (p *connPool) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pooled := range p.h2 {
+		go pooled.cc.Shutdown(context.Background())
+		delete(p.h2, key)
+	}
+	for key, transport := range p.h1 {
+		transport.CloseIdleConnections()
+		delete(p.h1, key)
+	}
+}
+
+// traceGotConn reports an HTTP/2 connection handed to a request to any httptrace hooks on ctx.
+// http2.ClientConn.RoundTrip does not report it itself.
+func traceGotConn(ctx context.Context, conn net.Conn, reused bool) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn, Reused: reused})
+	}
+}
+
+// afterCloseBody runs after once the response body is closed, e.g. to close
+// a connection that must not be reused
+type afterCloseBody struct {
+	io.ReadCloser
+	after func()
+}
+
+func (b *afterCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.after()
+	return err
+}
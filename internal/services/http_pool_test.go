@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		reuse      bool
+		wantReused bool
+	}{
+		{name: "keep-alive", reuse: true, wantReused: true},
+		{name: "fresh connection", reuse: false, wantReused: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewHTTPClient()
+			client.SetUseSystemProxy(false)
+			client.SetReuseConnections(tt.reuse)
+
+			var reused bool
+			for i := 0; i < 2; i++ {
+				resp, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+				if err != nil {
+					t.Fatal(err)
+				}
+				reused = resp.Timing.Reused
+			}
+
+			if reused != tt.wantReused {
+				t.Fatalf("second request reused = %v, want %v", reused, tt.wantReused)
+			}
+		})
+	}
+}
+
+func TestExecuteReusesTLSConnections(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		wantProto string
+	}{
+		{name: "http2", http2: true, wantProto: "HTTP/2.0"},
+		{name: "http1 only", http2: false, wantProto: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handshakes atomic.Int32
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}))
+			server.EnableHTTP2 = tt.http2
+			server.TLS = &tls.Config{
+				GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+					handshakes.Add(1)
+					return nil, nil
+				},
+			}
+			server.StartTLS()
+			defer server.Close()
+
+			client := NewHTTPClient()
+			client.SetUseSystemProxy(false)
+
+			for i := 0; i < 2; i++ {
+				resp, err := client.Execute(context.Background(), ExecuteRequest{
+					Method:   "GET",
+					URL:      server.URL,
+					Settings: &models.RequestSettings{SkipTLSVerify: true},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Body != tt.wantProto {
+					t.Fatalf("request %d used %s, want %s", i, resp.Body, tt.wantProto)
+				}
+				if resp.Timing.Reused != (i == 1) {
+					t.Fatalf("request %d reused = %v", i, resp.Timing.Reused)
+				}
+			}
+
+			if got := handshakes.Load(); got != 1 {
+				t.Fatalf("%d TLS handshakes, want 1", got)
+			}
+		})
+	}
+}
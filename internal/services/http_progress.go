@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// progressInterval is the minimum time between two progress reports of a transfer
+const progressInterval = 100 * time.Millisecond
+
+// progressReader counts the bytes read through it and reports them at most every
+// progressInterval, and once more when the body has been read in full
+type progressReader struct {
+	r      io.ReadCloser
+	report func(models.TransferProgress)
+
+	progress models.TransferProgress
+	start    time.Time
+	last     time.Time
+}
+
+// newProgressReader wraps r, whose length is total or unknown when total is negative
+func newProgressReader(r io.ReadCloser, direction string, total int64, report func(models.TransferProgress)) *progressReader {
+	return &progressReader{
+		r:        r,
+		report:   report,
+		progress: models.TransferProgress{Direction: direction, Total: max(total, 0)},
+		start:    time.Now(),
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.progress.Transferred += int64(n)
+
+	complete := p.progress.Total > 0 && p.progress.Transferred >= p.progress.Total
+	if err == io.EOF || complete {
+		p.finish()
+	} else if n > 0 && time.Since(p.last) >= progressInterval {
+		p.emit()
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	return p.r.Close()
+}
+
+// finish sends the final report, unless it was already sent
+func (p *progressReader) finish() {
+	if p.progress.Done {
+		return
+	}
+	p.progress.Done = true
+	p.emit()
+}
+
+func (p *progressReader) emit() {
+	p.last = time.Now()
+	if elapsed := p.last.Sub(p.start).Seconds(); elapsed > 0 {
+		p.progress.Rate = float64(p.progress.Transferred) / elapsed
+	}
+	p.report(p.progress)
+}
+
+// trackUploadProgress reports the progress of sending the body of req, including when
+// it is sent again after a redirect or a proxy fallback
+func trackUploadProgress(req *http.Request, report func(models.TransferProgress)) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	total := req.ContentLength
+	req.Body = newProgressReader(req.Body, models.TransferUpload, total, report)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressReader(body, models.TransferUpload, total, report), nil
+		}
+	}
+}
+
+// streamBody is a request body assembled from in-memory chunks and files, which are
+// read from disk as the body is sent rather than loaded up front
+type streamBody struct {
+	parts []streamPart
+	size  int64
+}
+
+// streamPart is either data or the file at path, which had size bytes when added
+type streamPart struct {
+	data []byte
+	path string
+	size int64
+}
+
+// Write appends p to the body, so that a multipart.Writer can build it
+func (b *streamBody) Write(p []byte) (int, error) {
+	if n := len(b.parts); n > 0 && b.parts[n-1].path == "" {
+		b.parts[n-1].data = append(b.parts[n-1].data, p...)
+	} else {
+		b.parts = append(b.parts, streamPart{data: append([]byte(nil), p...)})
+	}
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+// addFile appends the contents of the file at path
+func (b *streamBody) addFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	b.parts = append(b.parts, streamPart{path: path, size: info.Size()})
+	b.size += info.Size()
+	return nil
+}
+
+// attach makes the body that of req, with a known length so it is not sent chunked
+func (b *streamBody) attach(req *http.Request) {
+	if b.size == 0 {
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+		return
+	}
+	req.Body = b.open()
+	req.GetBody = func() (io.ReadCloser, error) { return b.open(), nil }
+	req.ContentLength = b.size
+}
+
+// open returns a reader over the whole body
+func (b *streamBody) open() io.ReadCloser {
+	return &streamReader{parts: b.parts}
+}
+
+// streamReader reads the parts of a streamBody in turn, with one file open at a time
+type streamReader struct {
+	parts []streamPart
+	cur   io.ReadCloser
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			part := r.parts[0]
+			r.parts = r.parts[1:]
+			if part.path == "" {
+				r.cur = io.NopCloser(bytes.NewReader(part.data))
+			} else {
+				file, err := os.Open(part.path)
+				if err != nil {
+					return 0, fmt.Errorf("failed to open %s: %w", part.path, err)
+				}
+				r.cur = &filePart{Reader: io.LimitReader(file, part.size), file: file, path: part.path, left: part.size}
+			}
+		}
+
+		n, err := r.cur.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *streamReader) Close() error {
+	if r.cur != nil {
+		err := r.cur.Close()
+		r.cur = nil
+		return err
+	}
+	return nil
+}
+
+// filePart reads the file of a part up to the size it had when added, which the
+// Content-Length was computed from. A file that shrank since is an error rather
+// than a body shorter than announced.
+type filePart struct {
+	io.Reader
+	file *os.File
+	path string
+	left int64
+}
+
+func (f *filePart) Read(p []byte) (int, error) {
+	n, err := f.Reader.Read(p)
+	f.left -= int64(n)
+	if errors.Is(err, io.EOF) && f.left > 0 {
+		return n, fmt.Errorf("%s shrank by %d bytes while it was sent", f.path, f.left)
+	}
+	return n, err
+}
+
+func (f *filePart) Close() error {
+	return f.file.Close()
+}
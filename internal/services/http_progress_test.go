@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// progressLog collects the progress reports of a request
+type progressLog struct {
+	mu      sync.Mutex
+	reports []models.TransferProgress
+}
+
+func (l *progressLog) record(p models.TransferProgress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reports = append(l.reports, p)
+}
+
+// last returns the final report of a direction, and how many reports it had
+func (l *progressLog) last(direction string) (models.TransferProgress, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var last models.TransferProgress
+	count := 0
+	for _, p := range l.reports {
+		if p.Direction == direction {
+			last = p
+			count++
+		}
+	}
+	return last, count
+}
+
+func TestExecuteStreamsBinaryUploadWithProgress(t *testing.T) {
+	content := strings.Repeat("postme upload ", 1<<16)
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A redirect that keeps the method makes the body be sent twice
+		if r.URL.Path == "/moved" {
+			io.Copy(io.Discard, r.Body)
+			http.Redirect(w, r, "/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if r.ContentLength != int64(len(content)) || r.TransferEncoding != nil {
+			t.Errorf("content length = %d, transfer encoding = %v", r.ContentLength, r.TransferEncoding)
+		}
+		if string(data) != content {
+			t.Errorf("server received %d bytes, want %d", len(data), len(content))
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	var log progressLog
+	client := NewHTTPClient()
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:     "POST",
+		URL:        server.URL + "/moved",
+		Body:       path,
+		BodyType:   "binary",
+		OnProgress: log.record,
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Size != int64(len(content)) {
+		t.Fatalf("response size = %d", resp.Size)
+	}
+
+	size := int64(len(content))
+	for _, direction := range []string{models.TransferUpload, models.TransferDownload} {
+		last, count := log.last(direction)
+		if !last.Done || last.Transferred != size || last.Total != size {
+			t.Errorf("final %s report = %+v, want %d of %d bytes done", direction, last, size, size)
+		}
+		if count == 0 || last.Rate <= 0 {
+			t.Errorf("%s: %d reports, rate %v", direction, count, last.Rate)
+		}
+	}
+}
+
+func TestExecuteStreamsMultipartFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.bin")
+	os.WriteFile(first, []byte("first file"), 0o600)
+	os.WriteFile(second, []byte{0, 1, 2, 3}, 0o600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength <= 0 {
+			t.Errorf("multipart body sent without a length")
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+			return
+		}
+		fields := map[string]string{"note": r.FormValue("note")}
+		for key, headers := range r.MultipartForm.File {
+			file, _ := headers[0].Open()
+			data, _ := io.ReadAll(file)
+			file.Close()
+			fields[key] = fmt.Sprintf("%s=%q", headers[0].Filename, data)
+		}
+		json.NewEncoder(w).Encode(fields)
+	}))
+	defer server.Close()
+
+	items, _ := json.Marshal([]models.KeyValue{
+		{Key: "doc", Value: first, Type: "file", Enabled: true},
+		{Key: "note", Value: "hello", Enabled: true},
+		{Key: "blob", Value: second, Type: "file", Enabled: true},
+	})
+	client := NewHTTPClient()
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "POST",
+		URL:      server.URL,
+		Body:     string(items),
+		BodyType: "form-data",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	var got map[string]string
+	json.Unmarshal([]byte(resp.Body), &got)
+	want := map[string]string{
+		"note": "hello",
+		"doc":  `first.txt="first file"`,
+		"blob": `second.bin="\x00\x01\x02\x03"`,
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %s, want %s", key, got[key], value)
+		}
+	}
+
+	// A missing file is reported before anything is sent
+	items, _ = json.Marshal([]models.KeyValue{{Key: "doc", Value: first + ".missing", Type: "file", Enabled: true}})
+	_, err = client.Execute(context.Background(), ExecuteRequest{Method: "POST", URL: server.URL, Body: string(items), BodyType: "form-data"})
+	if err == nil || !strings.Contains(err.Error(), "failed to open form file") {
+		t.Errorf("expected an open error, got %v", err)
+	}
+}
+
+func TestStreamBodyKeepsFileSizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	os.WriteFile(path, []byte("0123456789"), 0o600)
+
+	body := &streamBody{}
+	body.Write([]byte("head:"))
+	if err := body.addFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that grew is sent at the size the Content-Length was computed from
+	os.WriteFile(path, []byte("0123456789 and more"), 0o600)
+	data, err := io.ReadAll(body.open())
+	if err != nil || string(data) != "head:0123456789" || int64(len(data)) != body.size {
+		t.Errorf("grown file read %q, %v", data, err)
+	}
+
+	// One that shrank fails rather than sending less than announced
+	os.WriteFile(path, []byte("0123"), 0o600)
+	if _, err := io.ReadAll(body.open()); err == nil || !strings.Contains(err.Error(), "shrank by 6 bytes") {
+		t.Errorf("shrunk file read error = %v", err)
+	}
+}
@@ -0,0 +1,582 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// Supported proxy URL schemes. socks5h and socks4a resolve the target host on the
+// proxy; socks5 and socks4 resolve it locally, like curl.
+const (
+	proxySchemeHTTP    = "http"
+	proxySchemeHTTPS   = "https"
+	proxySchemeSOCKS5  = "socks5"
+	proxySchemeSOCKS5H = "socks5h"
+	proxySchemeSOCKS4  = "socks4"
+	proxySchemeSOCKS4A = "socks4a"
+)
+
+// isSOCKSProxy reports whether proxyURL is a SOCKS proxy. SOCKS proxies are always
+// dialed by dialThroughProxy, even for plain HTTP requests.
+func isSOCKSProxy(proxyURL *url.URL) bool {
+	switch strings.ToLower(proxyURL.Scheme) {
+	case proxySchemeSOCKS5, proxySchemeSOCKS5H, proxySchemeSOCKS4, proxySchemeSOCKS4A:
+		return true
+	}
+	return false
+}
+
+// proxyChainFunc returns the proxies to try for a request, in order. A nil entry is a
+// direct connection, and so is an empty chain.
+type proxyChainFunc func(*http.Request) ([]*url.URL, error)
+
+// singleProxy adapts a function that picks one proxy, like http.ProxyURL, to a chain
+func singleProxy(proxy func(*http.Request) (*url.URL, error)) proxyChainFunc {
+	return func(req *http.Request) ([]*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		return []*url.URL{proxyURL}, nil
+	}
+}
+
+// proxyUnreachable reports whether err means the proxy itself could not be reached,
+// so the next proxy of a chain may be tried
+func proxyUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
+
+type proxyConfigKey struct{}
+
+// withProxyConfig routes requests made with ctx through proxy instead of the
+// app-wide proxy settings. A disabled config sends them directly.
+func withProxyConfig(ctx context.Context, proxy *models.ProxyConfig) context.Context {
+	return context.WithValue(ctx, proxyConfigKey{}, proxy)
+}
+
+// proxyConfigFrom returns the proxy override attached to ctx, or nil
+func proxyConfigFrom(ctx context.Context) *models.ProxyConfig {
+	proxy, _ := ctx.Value(proxyConfigKey{}).(*models.ProxyConfig)
+	return proxy
+}
+
+// proxyForURL picks the proxy of a manual configuration for target, or nil if
+// target is reached directly
+func proxyForURL(config *models.ProxyConfig, target *url.URL) (*url.URL, error) {
+	if !config.Enabled || bypassProxy(target, config.Bypass) {
+		return nil, nil
+	}
+
+	raw := config.HTTP
+	if target.Scheme == "https" && config.HTTPS != "" {
+		raw = config.HTTPS
+	}
+	if raw == "" {
+		raw = config.SOCKS
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := parseProxyURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.User == nil && config.Username != "" {
+		proxyURL.User = url.UserPassword(config.Username, config.Password)
+	}
+	return proxyURL, nil
+}
+
+// parseProxyURL parses a proxy URL, defaulting to an HTTP proxy when there is no scheme
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case proxySchemeHTTP, proxySchemeHTTPS, proxySchemeSOCKS5, proxySchemeSOCKS5H, proxySchemeSOCKS4, proxySchemeSOCKS4A:
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return proxyURL, nil
+}
+
+// validateProxyConfig checks that every proxy URL, PAC location and bypass entry of
+// config can be used
+func validateProxyConfig(config models.ProxyConfig) error {
+	if config.PAC != "" {
+		if err := validatePACLocation(config.PAC); err != nil {
+			return err
+		}
+	}
+	for _, raw := range []string{config.HTTP, config.HTTPS, config.SOCKS} {
+		if raw == "" {
+			continue
+		}
+		if _, err := parseProxyURL(raw); err != nil {
+			return err
+		}
+	}
+	for _, entry := range config.Bypass {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(strings.TrimSpace(entry)); err != nil {
+				return fmt.Errorf("invalid bypass range %q", entry)
+			}
+		}
+	}
+	return nil
+}
+
+// bypassProxy reports whether target matches a NO_PROXY style bypass list. Entries may be:
+//   - "*", matching everything
+//   - a host name, matching it and its subdomains ("example.com")
+//   - ".example.com" or "*.example.com", matching subdomains only
+//   - a pattern with "*" wildcards ("10.0.*", "*.corp.*")
+//   - an IP address or CIDR range ("192.168.0.0/16")
+//   - "<local>", matching names without a dot and loopback addresses
+//
+// An entry may end in :port to match only that port.
+func bypassProxy(target *url.URL, bypass []string) bool {
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range bypass {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if entry == "<local>" {
+			if !strings.Contains(host, ".") && ip == nil || host == "localhost" || ip != nil && ip.IsLoopback() {
+				return true
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		// Split off a port, taking care of bare IPv6 addresses
+		pattern, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			pattern, entryPort = h, p
+		}
+		pattern = strings.Trim(pattern, "[]")
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if entryIP := net.ParseIP(pattern); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(pattern, "*.") && !strings.Contains(pattern[2:], "*"):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case strings.HasPrefix(pattern, "."):
+			if strings.HasSuffix(host, pattern) {
+				return true
+			}
+		case strings.Contains(pattern, "*"):
+			if matched, _ := path.Match(pattern, host); matched {
+				return true
+			}
+		default:
+			if host == pattern || strings.HasSuffix(host, "."+pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyAddr returns host:port of the proxy, filling in the scheme's default port
+func proxyAddr(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "80"
+	switch strings.ToLower(proxyURL.Scheme) {
+	case proxySchemeHTTPS:
+		port = "443"
+	case proxySchemeSOCKS5, proxySchemeSOCKS5H, proxySchemeSOCKS4, proxySchemeSOCKS4A:
+		port = "1080"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// dialThroughProxy opens a tunnel to targetHost through proxyURL: HTTP CONNECT for
+// http and https proxies (the latter over TLS), or a SOCKS handshake
+func (t *utlsTransport) dialThroughProxy(ctx context.Context, proxyURL *url.URL, targetHost string) (net.Conn, error) {
+	scheme := strings.ToLower(proxyURL.Scheme)
+	switch scheme {
+	case proxySchemeHTTP, proxySchemeHTTPS, proxySchemeSOCKS5, proxySchemeSOCKS5H, proxySchemeSOCKS4, proxySchemeSOCKS4A:
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+
+	// Connect to proxy. Failing here is reported like http.Transport does, so a
+	// proxy chain moves on to its next entry.
+	conn, err := t.dialer.DialContext(ctx, "tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, &net.OpError{Op: "proxyconnect", Net: "tcp", Err: err}
+	}
+
+	// Bound the handshake by the request deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	switch scheme {
+	case proxySchemeSOCKS5, proxySchemeSOCKS5H:
+		err = t.socks5Connect(ctx, conn, proxyURL, targetHost, scheme == proxySchemeSOCKS5H)
+	case proxySchemeSOCKS4, proxySchemeSOCKS4A:
+		err = t.socks4Connect(ctx, conn, proxyURL, targetHost, scheme == proxySchemeSOCKS4A)
+	default:
+		if scheme == proxySchemeHTTPS {
+			conn, err = t.proxyTLS(ctx, conn, proxyURL)
+			if err != nil {
+				return nil, err
+			}
+		}
+		conn, err = httpConnect(conn, proxyURL, targetHost)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The dialer only traced the hop to the proxy; count the tunnel as part of connect
+	traceConnectDone(ctx, "tcp", targetHost, nil)
+
+	return conn, nil
+}
+
+// proxyTLS performs the TLS handshake with an https proxy. The proxy certificate is
+// always verified, against the system roots plus any imported CAs.
+func (t *utlsTransport) proxyTLS(ctx context.Context, conn net.Conn, proxyURL *url.URL) (net.Conn, error) {
+	config, err := t.tlsConfig(proxyAddr(proxyURL), proxyURL.Hostname(), tlsOptions{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	config.NextProtos = []string{"http/1.1"}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// httpConnect asks an HTTP proxy to open a tunnel to targetHost
+func httpConnect(conn net.Conn, proxyURL *url.URL, targetHost string) (net.Conn, error) {
+	// Send CONNECT request
+	connectReq := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: targetHost},
+		Host:   targetHost,
+		Header: make(http.Header),
+	}
+
+	// Add proxy authentication if present
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := connectReq.Write(conn); err != nil {
+		return conn, err
+	}
+
+	// Read response
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		return conn, err
+	}
+
+	if resp.StatusCode != 200 {
+		return conn, &net.OpError{Op: "dial", Err: &proxyError{resp.Status}}
+	}
+
+	// Keep anything the proxy sent past its response head
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into r
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// socks5Connect performs a SOCKS5 handshake (RFC 1928) with username/password
+// authentication (RFC 1929) when the proxy URL has credentials
+func (t *utlsTransport) socks5Connect(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetHost string, remoteDNS bool) error {
+	host, port, err := splitTargetHost(targetHost)
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{0x00}
+	if proxyURL.User != nil {
+		methods = []byte{0x02, 0x00}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return &proxyError{"not a SOCKS5 proxy"}
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if proxyURL.User == nil {
+			return &proxyError{"SOCKS5 proxy requires authentication"}
+		}
+		username := proxyURL.User.Username()
+		password, _ := proxyURL.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return &proxyError{"SOCKS5 credentials too long"}
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return &proxyError{"SOCKS5 authentication failed"}
+		}
+	default:
+		return &proxyError{"SOCKS5 proxy accepts none of our authentication methods"}
+	}
+
+	// CONNECT request
+	req := []byte{0x05, 0x01, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && !remoteDNS {
+		if ip, err = t.resolveHost(ctx, host, false); err != nil {
+			return err
+		}
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return &proxyError{"host name too long for SOCKS5"}
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, 0x01)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		return &proxyError{"SOCKS5 connect failed: " + socks5ReplyText(head[1])}
+	}
+	var addrLen int
+	switch head[3] {
+	case 0x01:
+		addrLen = net.IPv4len
+	case 0x04:
+		addrLen = net.IPv6len
+	case 0x03:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return err
+		}
+		addrLen = int(size[0])
+	default:
+		return &proxyError{"SOCKS5 reply has unknown address type"}
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
+
+// socks5ReplyText describes a SOCKS5 reply code
+func socks5ReplyText(code byte) string {
+	switch code {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return "error " + strconv.Itoa(int(code))
+}
+
+// socks4Connect performs a SOCKS4 handshake, or SOCKS4a when remoteDNS is set.
+// The proxy URL's username is sent as the user ID.
+func (t *utlsTransport) socks4Connect(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetHost string, remoteDNS bool) error {
+	host, port, err := splitTargetHost(targetHost)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host).To4()
+	if ip == nil && net.ParseIP(host) != nil {
+		return &proxyError{"SOCKS4 does not support IPv6"}
+	}
+	if ip == nil && !remoteDNS {
+		if ip, err = t.resolveHost(ctx, host, true); err != nil {
+			return err
+		}
+	}
+
+	req := []byte{0x04, 0x01}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if ip == nil {
+		// SOCKS4a: an invalid address tells the proxy a host name follows
+		req = append(req, 0, 0, 0, 1)
+	} else {
+		req = append(req, ip...)
+	}
+	if proxyURL.User != nil {
+		req = append(req, proxyURL.User.Username()...)
+	}
+	req = append(req, 0x00)
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0x00)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0x5A {
+		return &proxyError{fmt.Sprintf("SOCKS4 connect rejected (code 0x%02X)", reply[1])}
+	}
+	return nil
+}
+
+// resolveHost looks up host for a proxy that needs an address, preferring IPv4
+func (t *utlsTransport) resolveHost(ctx context.Context, host string, ipv4Only bool) (net.IP, error) {
+	resolver := t.dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ip4 := addr.IP.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+	if !ipv4Only && len(addrs) > 0 {
+		return addrs[0].IP, nil
+	}
+	return nil, &net.DNSError{Err: "no IPv4 address", Name: host}
+}
+
+// splitTargetHost splits a host:port tunnel target
+func splitTargetHost(targetHost string) (string, uint16, error) {
+	host, portText, err := net.SplitHostPort(targetHost)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return "", 0, errors.New("invalid port " + portText)
+	}
+	return host, uint16(port), nil
+}
+
+type proxyError struct {
+	status string
+}
+
+func (e *proxyError) Error() string {
+	return "proxy connect failed: " + e.status
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// maxRedirectDrain is how much of an intermediate redirect body is read so its connection can be reused
+const maxRedirectDrain = 64 << 10
+
+// defaultRedirectPolicy returns the app-wide redirect policy used before settings are loaded
+func defaultRedirectPolicy() models.RedirectPolicy {
+	return models.RedirectPolicy{
+		Follow:       true,
+		MaxRedirects: models.DefaultMaxRedirects,
+		StripAuth:    true,
+	}
+}
+
+// hopTrace records what happened on the wire for one request of a redirect chain
+type hopTrace struct {
+	timer   *requestTimer
+	headers sentHeaderRecorder
+	order   headerOrderRecorder
+	capture *wireCapture
+}
+
+// newHopTrace creates a hopTrace starting now, optionally recording raw traffic
+func newHopTrace(capture bool) *hopTrace {
+	hop := &hopTrace{timer: newRequestTimer()}
+	if capture {
+		hop.capture = &wireCapture{}
+	}
+	return hop
+}
+
+// context attaches the hop's recorders to ctx
+func (h *hopTrace) context(ctx context.Context) context.Context {
+	ctx = httptrace.WithClientTrace(ctx, h.clientTrace())
+	if h.capture != nil {
+		ctx = withWireCapture(ctx, h.capture)
+	}
+	return ctx
+}
+
+// responseHeaders returns the headers of resp as an ordered list, in wire order
+func (h *hopTrace) responseHeaders(resp *http.Response) []models.KeyValue {
+	return headerList(resp.Header, h.order.order(resp))
+}
+
+// raw returns the captured traffic of the hop, or nil if capture was off
+func (h *hopTrace) raw() *models.RawCapture {
+	if h.capture == nil {
+		return nil
+	}
+	return h.capture.result()
+}
+
+// clientTrace returns httptrace hooks that feed the hop's recorders
+func (h *hopTrace) clientTrace() *httptrace.ClientTrace {
+	trace := h.timer.clientTrace()
+	h.headers.attach(trace)
+	h.order.attach(trace)
+	return trace
+}
+
+// doWithRedirects sends req and follows redirects according to policy, recording every
+// intermediate response. The http.Client itself never follows redirects (see rebuildClient),
+// so each hop gets its own timing and passes through the cookie jar and transport normally.
+//
+// jar may be nil when cookies are not persisted. sign, when set, signs every hop as it is
+// sent, after the jar's cookies are added, so no hop reuses another's signature; with
+// StripAuth, hops after one to another origin are not signed. When capture is set, every
+// hop is sent on a dedicated connection whose bytes are recorded. When the hop limit is
+// reached, the last redirect response is returned as the final one.
+func (c *HTTPClient) doWithRedirects(req *http.Request, policy models.RedirectPolicy, jar http.CookieJar, sign func(*http.Request) error, capture bool) (*http.Response, *hopTrace, []models.RedirectHop, error) {
+	ctx := req.Context()
+	client := c.httpClient()
+	var hops []models.RedirectHop
+
+	for {
+		hop := newHopTrace(capture)
+		hopCtx := hop.context(ctx)
+
+		send := req.WithContext(hopCtx)
+		if jar != nil || sign != nil {
+			// Clone so jar cookies and signatures are not carried into the next hop's
+			// copied headers
+			send = req.Clone(hopCtx)
+			if jar != nil {
+				for _, cookie := range jar.Cookies(req.URL) {
+					send.AddCookie(cookie)
+				}
+			}
+			// Signed last so the signature covers the cookies too
+			if sign != nil {
+				if err := sign(send); err != nil {
+					return nil, nil, hops, err
+				}
+			}
+		}
+
+		resp, err := client.Do(send)
+		if err != nil {
+			return nil, nil, hops, err
+		}
+		if jar != nil {
+			if cookies := resp.Cookies(); len(cookies) > 0 {
+				jar.SetCookies(req.URL, cookies)
+			}
+		}
+
+		if !policy.Follow || len(hops) >= policy.MaxRedirects {
+			return resp, hop, hops, nil
+		}
+
+		next, err := nextRedirectRequest(req, resp, policy)
+		if err != nil || next == nil {
+			return resp, hop, hops, err
+		}
+
+		// Drain so the connection can go back to the pool
+		io.CopyN(io.Discard, resp.Body, maxRedirectDrain)
+		resp.Body.Close()
+		hop.timer.finish()
+
+		hops = append(hops, models.RedirectHop{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			StatusCode:  resp.StatusCode,
+			Status:      resp.Status,
+			Location:    resp.Header.Get("Location"),
+			Headers:     hop.responseHeaders(resp),
+			SentHeaders: hop.headers.headers(),
+			Timing:      hop.timer.timing(),
+			Raw:         hop.raw(),
+		})
+		if policy.StripAuth && !sameOrigin(req.URL, next.URL) {
+			// Like Authorization, signatures are only sent to the origin they were for
+			sign = nil
+		}
+		req = next
+	}
+}
+
+// nextRedirectRequest builds the request that follows a redirect response, or returns nil
+// if resp is not a redirect that can be followed. With StripAuth, credentials are not
+// forwarded to another origin: Authorization, Cookie, Proxy-Authorization and the
+// headers recorded by withAuthHeaders are dropped.
+func nextRedirectRequest(req *http.Request, resp *http.Response, policy models.RedirectPolicy) (*http.Request, error) {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, nil
+	}
+
+	if resp.Header.Get("Location") == "" {
+		return nil, nil
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	// 307/308 always keep method and body; 303 always switches to GET. 301/302
+	// historically switch non-GET requests to GET like browsers do, unless the
+	// policy asks to preserve the method.
+	method := req.Method
+	keepBody := true
+	switch resp.StatusCode {
+	case http.StatusSeeOther:
+		if method != http.MethodHead {
+			method = http.MethodGet
+		}
+		keepBody = false
+	case http.StatusMovedPermanently, http.StatusFound:
+		if !policy.PreserveMethod && method != http.MethodGet && method != http.MethodHead {
+			method = http.MethodGet
+			keepBody = false
+		}
+	}
+
+	var body io.ReadCloser
+	if keepBody && req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	} else if keepBody && req.Body != nil && req.Body != http.NoBody {
+		// Body cannot be replayed (e.g. a streamed upload); stop here
+		return nil, nil
+	}
+
+	next, err := http.NewRequestWithContext(req.Context(), method, location.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		next.GetBody = req.GetBody
+		next.ContentLength = req.ContentLength
+	}
+
+	next.Header = req.Header.Clone()
+	if body == nil {
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+	if policy.StripAuth && !sameOrigin(req.URL, location) {
+		for _, key := range []string{"Authorization", "Cookie", "Proxy-Authorization"} {
+			next.Header.Del(key)
+		}
+		for _, key := range authHeadersFrom(req.Context()) {
+			next.Header.Del(key)
+		}
+	}
+
+	return next, nil
+}
+
+// sameOrigin reports whether two URLs share scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && canonicalHostPort(a) == canonicalHostPort(b)
+}
+
+// canonicalHostPort returns host:port of u, filling in the scheme's default port
+func canonicalHostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return strings.ToLower(u.Hostname()) + ":" + port
+}
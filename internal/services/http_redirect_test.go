@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteRecordsRedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/callback", http.StatusFound)
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		policy     models.RedirectPolicy
+		wantStatus int
+		wantHops   int
+		wantBody   string
+	}{
+		{
+			name:       "follow switches POST to GET on 302",
+			policy:     defaultRedirectPolicy(),
+			wantStatus: http.StatusOK,
+			wantHops:   2,
+			wantBody:   "GET",
+		},
+		{
+			name:       "preserve method",
+			policy:     models.RedirectPolicy{Follow: true, MaxRedirects: 10, PreserveMethod: true},
+			wantStatus: http.StatusOK,
+			wantHops:   2,
+			wantBody:   "POST",
+		},
+		{
+			name:       "do not follow",
+			policy:     models.RedirectPolicy{Follow: false},
+			wantStatus: http.StatusFound,
+			wantHops:   0,
+		},
+		{
+			name:       "hop limit",
+			policy:     models.RedirectPolicy{Follow: true, MaxRedirects: 1},
+			wantStatus: http.StatusTemporaryRedirect,
+			wantHops:   1,
+		},
+	}
+
+	client := NewHTTPClient()
+	client.SetUseSystemProxy(false)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy
+			resp, err := client.Execute(context.Background(), ExecuteRequest{
+				Method:   "POST",
+				URL:      server.URL + "/login",
+				Body:     "payload",
+				BodyType: "text",
+				Settings: &models.RequestSettings{Redirect: &policy},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(resp.Redirects) != tt.wantHops {
+				t.Fatalf("len(Redirects) = %d, want %d", len(resp.Redirects), tt.wantHops)
+			}
+			if tt.wantBody != "" && resp.Body != tt.wantBody {
+				t.Fatalf("Body = %q, want %q", resp.Body, tt.wantBody)
+			}
+			if tt.wantHops > 0 && resp.Redirects[0].Location != "/callback" {
+				t.Fatalf("Redirects[0].Location = %q, want /callback", resp.Redirects[0].Location)
+			}
+		})
+	}
+}
+
+func TestNextRedirectRequestStripsAuthAcrossOrigins(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		strip    bool
+		wantAuth bool
+	}{
+		{name: "same origin", location: "https://api.example.com/next", strip: true, wantAuth: true},
+		{name: "other host", location: "https://sso.example.com/next", strip: true, wantAuth: false},
+		{name: "other scheme", location: "http://api.example.com/next", strip: true, wantAuth: false},
+		{name: "stripping disabled", location: "https://sso.example.com/next", strip: false, wantAuth: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://api.example.com/start", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			resp := &http.Response{
+				StatusCode: http.StatusFound,
+				Header:     http.Header{"Location": {tt.location}},
+				Request:    req,
+				Body:       http.NoBody,
+			}
+
+			next, err := nextRedirectRequest(req, resp, models.RedirectPolicy{Follow: true, StripAuth: tt.strip})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := next.Header.Get("Authorization") != ""; got != tt.wantAuth {
+				t.Fatalf("Authorization kept = %v, want %v", got, tt.wantAuth)
+			}
+			if !strings.HasPrefix(next.URL.String(), tt.location) {
+				t.Fatalf("URL = %q, want %q", next.URL, tt.location)
+			}
+		})
+	}
+}
+
+func TestExecuteSignsEveryRedirectHop(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keep", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		http.Redirect(w, r, "/next", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/see", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/next", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/next", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/next", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewHTTPClient()
+	client.SetUseSystemProxy(false)
+	client.SetCookieService(NewCookieService(newTestDB(t)))
+
+	// The signature covers the hop's own method, path and body, and the jar's cookies
+	auth := &models.AuthConfig{Type: models.AuthTypeHMAC, HMAC: &models.HMACConfig{
+		Secret: "s", StringToSign: "{method} {path} {header:Cookie} {bodySha256}", Value: "{signature}",
+	}}
+	signature := func(stringToSign string) string {
+		mac := hmac.New(sha256.New, []byte("s"))
+		mac.Write([]byte(stringToSign))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/keep", signature("POST /next session=1 " + hex.EncodeToString(sha256Sum("payload")))},
+		{"/see", signature("GET /next session=1 " + hex.EncodeToString(sha256Sum("")))},
+		{"/away", ""},
+	}
+	for _, tt := range tests {
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method: "POST", URL: server.URL + tt.path, Body: "payload", BodyType: "text", Auth: auth,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Body != tt.want {
+			t.Errorf("%s: redirected request signed %q, want %q", tt.path, resp.Body, tt.want)
+		}
+	}
+}
+
+func TestExecuteStripsAPIKeyHeaderAcrossOrigins(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Api-Key"))
+	}
+	other := httptest.NewServer(http.HandlerFunc(echo))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/same", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/next", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/next", http.StatusFound)
+	})
+	mux.HandleFunc("/next", echo)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewHTTPClient()
+	client.SetUseSystemProxy(false)
+	auth := &models.AuthConfig{Type: models.AuthTypeAPIKey, Key: "X-Api-Key", Value: "secret"}
+
+	tests := []struct {
+		path  string
+		strip bool
+		want  string
+	}{
+		{"/same", true, "secret"},
+		{"/away", true, ""},
+		{"/away", false, "secret"},
+	}
+	for _, tt := range tests {
+		policy := models.RedirectPolicy{Follow: true, MaxRedirects: 5, StripAuth: tt.strip}
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method: "GET", URL: server.URL + tt.path, Auth: auth,
+			Settings: &models.RequestSettings{Redirect: &policy},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Body != tt.want {
+			t.Errorf("%s (strip %v): redirected request sent key %q, want %q", tt.path, tt.strip, resp.Body, tt.want)
+		}
+	}
+}
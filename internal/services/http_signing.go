@@ -0,0 +1,492 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// signedFormLimit caps the form bodies kept for OAuth 1.0a to sign their parameters
+const signedFormLimit = 1 << 20
+
+// signsRequest reports whether auth is computed over the final request just before it
+// is sent, rather than added along with the user's headers
+func signsRequest(auth *models.AuthConfig) bool {
+	if auth == nil {
+		return false
+	}
+	switch auth.Type {
+	case models.AuthTypeAWSV4, models.AuthTypeOAuth1, models.AuthTypeHMAC, models.AuthTypeJWT:
+		return true
+	}
+	return false
+}
+
+// signedBody is what signing needs of a request body
+type signedBody struct {
+	sha256 string // hex SHA-256 of the body, for the auth types that sign it
+	data   []byte // the body itself, for OAuth 1.0a form bodies
+}
+
+// readSignedBody reads what auth needs of the body of req, through GetBody so that req
+// can still be sent: its hash for AWS Signature V4 and HMAC, and for OAuth 1.0a a form
+// body of up to signedFormLimit bytes, whose parameters are signed. Other bodies, and
+// every body for JWT, are not read.
+func readSignedBody(req *http.Request, auth *models.AuthConfig) (*signedBody, error) {
+	sum := sha256.New()
+	var data bytes.Buffer
+	var w io.Writer
+	switch auth.Type {
+	case models.AuthTypeAWSV4, models.AuthTypeHMAC:
+		w = sum
+	case models.AuthTypeOAuth1:
+		if isFormBody(req) && req.ContentLength >= 0 && req.ContentLength <= signedFormLimit {
+			w = &data
+		}
+	}
+
+	if w != nil && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("the request body cannot be read twice to sign it")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		if _, err := io.Copy(w, body); err != nil {
+			return nil, err
+		}
+	}
+	return &signedBody{sha256: hex.EncodeToString(sum.Sum(nil)), data: data.Bytes()}, nil
+}
+
+// isFormBody reports whether the body of req is URL-encoded form parameters
+func isFormBody(req *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// requestSigner returns a function that signs each request sent for auth, such as every
+// hop of a redirect chain, as it goes out. body is the signedBody of the first request;
+// a hop that dropped the body, as after a 303, is signed without it.
+func requestSigner(auth *models.AuthConfig, body *signedBody) func(*http.Request) error {
+	empty := &signedBody{sha256: hex.EncodeToString(sha256.New().Sum(nil))}
+	return func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody {
+			return signRequest(req, auth, empty, time.Now())
+		}
+		return signRequest(req, auth, body, time.Now())
+	}
+}
+
+// signRequest adds the signature of auth to req as of now. It must run after every
+// header that should be signed is set. A header the user typed in place of the one
+// auth adds is kept, as for the other auth types.
+func signRequest(req *http.Request, auth *models.AuthConfig, body *signedBody, now time.Time) error {
+	switch auth.Type {
+	case models.AuthTypeAWSV4:
+		if req.Header.Get("Authorization") != "" {
+			return nil
+		}
+		return signAWSV4(req, auth, body.sha256, now)
+	case models.AuthTypeOAuth1:
+		if auth.OAuth1 == nil {
+			return errors.New("oauth1: not configured")
+		}
+		if auth.OAuth1.In != models.AuthInQuery && req.Header.Get("Authorization") != "" {
+			return nil
+		}
+		var form url.Values
+		if isFormBody(req) {
+			form, _ = url.ParseQuery(string(body.data))
+		}
+		return signOAuth1(req, auth.OAuth1, form, now.Unix(), newNonce())
+	case models.AuthTypeHMAC:
+		if auth.HMAC == nil {
+			return errors.New("hmac: not configured")
+		}
+		return signHMAC(req, auth.HMAC, body.sha256, now.Unix(), newNonce())
+	case models.AuthTypeJWT:
+		if auth.JWT == nil {
+			return errors.New("jwt: not configured")
+		}
+		token, err := mintJWT(auth.JWT, now)
+		if err != nil {
+			return err
+		}
+		if auth.JWT.In == models.AuthInQuery {
+			param := auth.JWT.Param
+			if param == "" {
+				param = "jwt"
+			}
+			applyAuth(req, &models.AuthConfig{Type: models.AuthTypeAPIKey, Key: param, Value: token, In: models.AuthInQuery})
+		} else {
+			applyAuth(req, &models.AuthConfig{Type: models.AuthTypeBearer, Token: token, Prefix: auth.JWT.Prefix})
+		}
+	}
+	return nil
+}
+
+// signOAuth1 adds an OAuth 1.0a signature (RFC 5849) to req, as an Authorization header
+// or as query parameters. form holds the parameters of a form body, which are signed too.
+func signOAuth1(req *http.Request, config *models.OAuth1Config, form url.Values, timestamp int64, nonce string) error {
+	method := config.SignatureMethod
+	if method == "" {
+		method = models.OAuth1HMACSHA1
+	}
+	params := map[string]string{
+		"oauth_consumer_key":     config.ConsumerKey,
+		"oauth_nonce":            nonce,
+		"oauth_signature_method": method,
+		"oauth_timestamp":        strconv.FormatInt(timestamp, 10),
+		"oauth_version":          "1.0",
+	}
+	if config.Token != "" {
+		params["oauth_token"] = config.Token
+	}
+	if config.Callback != "" {
+		params["oauth_callback"] = config.Callback
+	}
+	if config.Verifier != "" {
+		params["oauth_verifier"] = config.Verifier
+	}
+
+	// Signature base string (section 3.4.1)
+	var pairs []string
+	add := func(name, value string) { pairs = append(pairs, percentEncode(name)+"="+percentEncode(value)) }
+	for name, value := range params {
+		add(name, value)
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			add(name, value)
+		}
+	}
+	for name, values := range form {
+		for _, value := range values {
+			add(name, value)
+		}
+	}
+	sort.Strings(pairs)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	baseURL := strings.ToLower(req.URL.Scheme) + "://" + oauth1Host(req.URL) + path
+	base := strings.ToUpper(req.Method) + "&" + percentEncode(baseURL) + "&" + percentEncode(strings.Join(pairs, "&"))
+
+	key := percentEncode(config.ConsumerSecret) + "&" + percentEncode(config.TokenSecret)
+	var signature string
+	switch method {
+	case models.OAuth1HMACSHA1:
+		mac := hmac.New(sha1.New, []byte(key))
+		mac.Write([]byte(base))
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	case models.OAuth1HMACSHA256:
+		signature = base64.StdEncoding.EncodeToString(hmacSHA256([]byte(key), base))
+	case models.OAuth1RSASHA1:
+		signer, err := parsePrivateKey(config.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("oauth1: %w", err)
+		}
+		rsaKey, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return errors.New("oauth1: RSA-SHA1 needs an RSA private key")
+		}
+		digest := sha1.Sum([]byte(base))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA1, digest[:])
+		if err != nil {
+			return fmt.Errorf("oauth1: %w", err)
+		}
+		signature = base64.StdEncoding.EncodeToString(sig)
+	case models.OAuth1Plaintext:
+		signature = key
+	default:
+		return fmt.Errorf("oauth1: unsupported signature method %q", method)
+	}
+	params["oauth_signature"] = signature
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if config.In == models.AuthInQuery {
+		query := make([]string, 0, len(names))
+		for _, name := range names {
+			query = append(query, percentEncode(name)+"="+percentEncode(params[name]))
+		}
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = strings.Join(query, "&")
+		} else {
+			req.URL.RawQuery += "&" + strings.Join(query, "&")
+		}
+		return nil
+	}
+
+	fields := make([]string, 0, len(names)+1)
+	if config.Realm != "" {
+		fields = append(fields, "realm="+quoteAuthParam(config.Realm))
+	}
+	for _, name := range names {
+		fields = append(fields, name+`="`+percentEncode(params[name])+`"`)
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(fields, ", "))
+	return nil
+}
+
+// oauth1Host returns the lowercased host of u without the default port of its scheme
+func oauth1Host(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" {
+		return strings.TrimSuffix(host, ":80")
+	} else if scheme == "https" {
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// hmacPlaceholder matches the placeholders of HMACConfig templates
+var hmacPlaceholder = regexp.MustCompile(`\{(method|host|path|query|timestamp|nonce|bodySha256|keyId|signature|header:[^{}]+)\}`)
+
+// signHMAC signs the canonical request string of config with its secret and sets
+// the header it describes, unless the user typed that header
+func signHMAC(req *http.Request, config *models.HMACConfig, bodySHA256 string, timestamp int64, nonce string) error {
+	newHash, err := hmacHash(config.Algorithm)
+	if err != nil {
+		return err
+	}
+	header := config.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	if req.Header.Get(header) != "" {
+		return nil
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	values := map[string]string{
+		"method":     req.Method,
+		"host":       host,
+		"path":       path,
+		"query":      awsV4CanonicalQuery(req),
+		"timestamp":  strconv.FormatInt(timestamp, 10),
+		"nonce":      nonce,
+		"bodySha256": bodySHA256,
+		"keyId":      config.KeyID,
+	}
+	expand := func(template string) string {
+		return hmacPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+			name := placeholder[1 : len(placeholder)-1]
+			if headerName, ok := strings.CutPrefix(name, "header:"); ok {
+				return req.Header.Get(headerName)
+			}
+			return values[name]
+		})
+	}
+
+	stringToSign := config.StringToSign
+	if stringToSign == "" {
+		stringToSign = models.DefaultHMACStringToSign
+	}
+	mac := hmac.New(newHash, []byte(config.Secret))
+	mac.Write([]byte(expand(stringToSign)))
+	sum := mac.Sum(nil)
+	switch config.Encoding {
+	case "", "hex":
+		values["signature"] = hex.EncodeToString(sum)
+	case "base64":
+		values["signature"] = base64.StdEncoding.EncodeToString(sum)
+	default:
+		return fmt.Errorf("hmac: unsupported encoding %q", config.Encoding)
+	}
+
+	value := config.Value
+	if value == "" {
+		value = models.DefaultHMACValue
+	}
+	req.Header.Set(header, expand(value))
+	return nil
+}
+
+// hmacHash returns the hash of an HMACConfig algorithm
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("hmac: unsupported algorithm %q", algorithm)
+}
+
+// mintJWT returns a JSON Web Token (RFC 7519) of the claims of config, signed as of now.
+// The claims keep the order they were written in.
+func mintJWT(config *models.JWTConfig, now time.Time) (string, error) {
+	header := struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid,omitempty"`
+	}{config.Algorithm, "JWT", config.KeyID}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	claims := strings.TrimSpace(config.Claims)
+	if claims == "" {
+		claims = "{}"
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(claims), &fields); err != nil {
+		return "", fmt.Errorf("jwt: the claims are not a JSON object: %w", err)
+	}
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, []byte(claims)); err != nil {
+		return "", err
+	}
+	var added []string
+	if _, ok := fields["iat"]; !ok {
+		added = append(added, `"iat":`+strconv.FormatInt(now.Unix(), 10))
+	}
+	if _, ok := fields["exp"]; !ok && config.ExpiresIn > 0 {
+		added = append(added, `"exp":`+strconv.FormatInt(now.Unix()+int64(config.ExpiresIn), 10))
+	}
+	if len(added) > 0 {
+		payload.Truncate(payload.Len() - 1) // the closing brace
+		if len(fields) > 0 {
+			payload.WriteByte(',')
+		}
+		payload.WriteString(strings.Join(added, ",") + "}")
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payload.Bytes())
+	var signature []byte
+	switch config.Algorithm {
+	case models.JWTHS256:
+		signature = hmacSHA256([]byte(config.Secret), signingInput)
+	case models.JWTRS256, models.JWTES256:
+		signer, err := parsePrivateKey(config.PrivateKey)
+		if err != nil {
+			return "", fmt.Errorf("jwt: %w", err)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		switch key := signer.(type) {
+		case *rsa.PrivateKey:
+			if config.Algorithm != models.JWTRS256 {
+				return "", errors.New("jwt: ES256 needs an EC private key")
+			}
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		case *ecdsa.PrivateKey:
+			if config.Algorithm != models.JWTES256 || key.Curve.Params().BitSize != 256 {
+				return "", errors.New("jwt: RS256 needs an RSA private key and ES256 a P-256 key")
+			}
+			// JWS uses the fixed-size concatenation of r and s, not ASN.1 (RFC 7518 section 3.4)
+			var r, s *big.Int
+			if r, s, err = ecdsa.Sign(rand.Reader, key, digest[:]); err == nil {
+				signature = make([]byte, 64)
+				r.FillBytes(signature[:32])
+				s.FillBytes(signature[32:])
+			}
+		default:
+			return "", errors.New("jwt: unsupported private key type")
+		}
+		if err != nil {
+			return "", fmt.Errorf("jwt: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("jwt: unsupported algorithm %q", config.Algorithm)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// pemBlock matches a PEM block whose line breaks were lost, as when pasted into a
+// single-line variable
+var pemBlock = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]+)-----([\s\S]*?)-----END [A-Z0-9 ]+-----`)
+
+// parsePrivateKey parses a PKCS #8, PKCS #1 or SEC 1 private key in PEM. Line breaks
+// may be written as \n or be missing.
+func parsePrivateKey(s string) (crypto.Signer, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), `\n`, "\n")
+	match := pemBlock.FindStringSubmatch(s)
+	if match == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(match[2]), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PEM private key: %w", err)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("unsupported private key type")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("cannot parse the %s", strings.ToLower(match[1]))
+}
+
+// percentEncode percent-encodes every byte of s but the unreserved characters of RFC 3986
+func percentEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
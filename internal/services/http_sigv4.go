@@ -0,0 +1,148 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+const (
+	awsV4Algorithm = "AWS4-HMAC-SHA256"
+
+	// awsV4TimeFormat is the format of X-Amz-Date
+	awsV4TimeFormat = "20060102T150405Z"
+)
+
+// awsV4UnsignedHeaders are left out of the signature because proxies and the transport
+// may change them on the way
+var awsV4UnsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// signAWSV4 adds an AWS Signature Version 4 Authorization to req, signing its method,
+// path, query, headers and payloadHash as of now. It must run after every header that
+// should be signed is set. An X-Amz-Content-Sha256 header the user set, such as
+// UNSIGNED-PAYLOAD, is signed in place of payloadHash.
+func signAWSV4(req *http.Request, auth *models.AuthConfig, payloadHash string, now time.Time) error {
+	if auth.Region == "" || auth.Service == "" {
+		return errors.New("aws signature: region and service are required")
+	}
+
+	amzDate := now.UTC().Format(awsV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if auth.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", auth.SessionToken)
+	}
+	if hash := req.Header.Get("X-Amz-Content-Sha256"); hash != "" {
+		payloadHash = hash
+	} else if auth.Service == "s3" {
+		// S3 requires the payload hash as a header too
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := awsV4CanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsV4CanonicalURI(req, auth.Service),
+		awsV4CanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{amzDate[:8], auth.Region, auth.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsV4Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + auth.SecretKey)
+	for _, part := range []string{amzDate[:8], auth.Region, auth.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", awsV4Algorithm+" Credential="+auth.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// awsV4CanonicalURI returns the encoded path of req. S3 signs the path as it is; other
+// services sign it normalized and encoded a second time.
+func awsV4CanonicalURI(req *http.Request, service string) string {
+	p := req.URL.Path
+	if p == "" {
+		p = "/"
+	}
+	if service != "s3" {
+		cleaned := path.Clean(p)
+		if strings.HasSuffix(p, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		p = cleaned
+	}
+
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = percentEncode(segment)
+		if service != "s3" {
+			segments[i] = percentEncode(segments[i])
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsV4CanonicalQuery returns the query parameters of req encoded and sorted by name,
+// then value
+func awsV4CanonicalQuery(req *http.Request) string {
+	var params []string
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, percentEncode(name)+"="+percentEncode(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsV4CanonicalHeaders returns the canonical headers block of req and the list of
+// header names it signs
+func awsV4CanonicalHeaders(req *http.Request) (canonical, signed string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string][]string{"host": {host}}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if awsV4UnsignedHeaders[name] {
+			continue
+		}
+		values[name] = append(values[name], vs...)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		trimmed := make([]string, len(values[name]))
+		for i, v := range values[name] {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
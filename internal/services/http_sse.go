@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+const (
+	// defaultSSERetry is the reconnection delay until the server sets one with retry:
+	defaultSSERetry = 3 * time.Second
+
+	// maxStreamEvents is how many events of a stream are kept in the response; all of
+	// them are still passed to the event callback as they arrive
+	maxStreamEvents = 10000
+)
+
+// isEventStream reports whether resp is a Server-Sent Events stream
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// eventStream holds the state of an event stream that carries over reconnections
+type eventStream struct {
+	start   time.Time
+	onEvent func(models.ServerSentEvent)
+
+	events []models.ServerSentEvent
+	lastID string
+	retry  time.Duration
+}
+
+// newEventStream creates an eventStream for a request sent at start
+func newEventStream(start time.Time, onEvent func(models.ServerSentEvent)) *eventStream {
+	return &eventStream{start: start, onEvent: onEvent, retry: defaultSSERetry}
+}
+
+// parse reads the stream of one connection until it ends, dispatching each complete
+// event. An event cut off by the end of the stream is dropped.
+func (s *eventStream) parse(r io.Reader) error {
+	lines := eventLineReader{r: bufio.NewReader(r)}
+	var eventType string
+	var data strings.Builder
+
+	for first := true; ; first = false {
+		line, err := lines.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if line == "" {
+			if data.Len() > 0 {
+				s.dispatch(eventType, strings.TrimSuffix(data.String(), "\n"))
+			}
+			eventType = ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment, often sent as a keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastID = value
+			}
+		case "retry":
+			if ms, ok := parseDigits(value); ok {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// dispatch records an event and passes it on
+func (s *eventStream) dispatch(eventType, data string) {
+	if eventType == "" {
+		eventType = "message"
+	}
+	event := models.ServerSentEvent{
+		ID:    s.lastID,
+		Event: eventType,
+		Data:  data,
+		Time:  time.Since(s.start).Milliseconds(),
+	}
+	if len(s.events) == maxStreamEvents {
+		s.events = s.events[1:]
+	}
+	s.events = append(s.events, event)
+	if s.onEvent != nil {
+		s.onEvent(event)
+	}
+}
+
+// parseDigits parses a non-negative decimal made only of ASCII digits
+func parseDigits(value string) (int64, bool) {
+	if value == "" || len(value) > 18 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	return n, true
+}
+
+// eventLineReader splits an event stream into lines ended by CRLF, LF or CR. A CR is
+// taken as a line end at once, so a line is never held back waiting for the next byte.
+type eventLineReader struct {
+	r       *bufio.Reader
+	afterCR bool
+	line    []byte
+}
+
+func (l *eventLineReader) readLine() (string, error) {
+	l.line = l.line[:0]
+	for {
+		b, err := l.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if l.afterCR {
+			l.afterCR = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\n':
+			return string(l.line), nil
+		case '\r':
+			l.afterCR = true
+			return string(l.line), nil
+		}
+		l.line = append(l.line, b)
+	}
+}
+
+// readEventStream reads the text/event-stream response resp, recording its raw text to
+// recorder and passing each event to the stream's callback as it arrives. When resend
+// is set, a stream that ends is reopened after the retry delay by resending req with
+// Last-Event-ID, until the server answers with anything but an event stream. The end
+// of ctx, as from cancelling the request, ends the stream without an error.
+func readEventStream(ctx context.Context, req *http.Request, resp *http.Response, stream *eventStream,
+	recorder io.Writer, onProgress func(models.TransferProgress), resend func(*http.Request) (*http.Response, error)) error {
+	for {
+		var err error
+		if resp != nil {
+			var reader io.Reader
+			var done func()
+			reader, done, err = responseReader(resp, onProgress)
+			if err == nil {
+				err = stream.parse(io.TeeReader(reader, recorder))
+				done()
+			}
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if resend == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(stream.retry):
+		}
+
+		next, err := reconnectRequest(ctx, req, stream.lastID)
+		if err != nil {
+			return err
+		}
+		resp, err = resend(next)
+		if err != nil {
+			// The server may be restarting; keep trying until cancelled
+			resp = nil
+			continue
+		}
+		if resp.StatusCode != http.StatusOK || !isEventStream(resp) {
+			resp.Body.Close()
+			return nil
+		}
+	}
+}
+
+// reconnectRequest returns a copy of req for reopening an event stream
+func reconnectRequest(ctx context.Context, req *http.Request, lastID string) (*http.Request, error) {
+	next := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	if lastID != "" {
+		next.Header.Set("Last-Event-ID", lastID)
+	}
+	return next, nil
+}
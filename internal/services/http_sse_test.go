@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestEventStreamParse(t *testing.T) {
+	input := "\ufeff: keep-alive\r\n" +
+		"data: first\r\n\r\n" +
+		"event: update\rid: 7\rdata: line one\rdata:line two\r\r" +
+		"retry: 250\nid\ndata\n\n" +
+		"data: ignored because\nid: bad\x00id\nevent: no blank line"
+
+	stream := newEventStream(time.Now(), nil)
+	if err := stream.parse(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []models.ServerSentEvent
+	for _, event := range stream.events {
+		event.Time = 0
+		got = append(got, event)
+	}
+	want := []models.ServerSentEvent{
+		{Event: "message", Data: "first"},
+		{ID: "7", Event: "update", Data: "line one\nline two"},
+		{Event: "message", Data: ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+	if stream.retry != 250*time.Millisecond {
+		t.Errorf("retry = %v", stream.retry)
+	}
+	if stream.lastID != "" {
+		t.Errorf("an id field with no value should reset the last event ID, got %q", stream.lastID)
+	}
+}
+
+func TestExecuteEventStreamReconnects(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch connections.Add(1) {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: one\n\n")
+		case 2:
+			if got := r.Header.Get("Last-Event-ID"); got != "1" {
+				t.Errorf("Last-Event-ID = %q, want 1", got)
+			}
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			fmt.Fprint(w, "id: 2\nevent: done\ndata: two\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var received []string
+	client := NewHTTPClient()
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "GET",
+		URL:      server.URL,
+		Settings: &models.RequestSettings{SSEReconnect: true},
+		OnEvent: func(event models.ServerSentEvent) {
+			received = append(received, event.ID+":"+event.Event+":"+event.Data)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	want := []string{"1:message:one", "2:done:two"}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+	if len(resp.Events) != 2 || resp.Events[1].Data != "two" {
+		t.Errorf("response events = %+v", resp.Events)
+	}
+	if !strings.Contains(resp.Body, "data: one") || !strings.Contains(resp.Body, "data: two") {
+		t.Errorf("body should hold the raw stream, got %q", resp.Body)
+	}
+	if n := connections.Load(); n != 3 {
+		t.Errorf("server saw %d connections, want 3", n)
+	}
+}
+
+func TestExecuteEventStreamStopsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := NewHTTPClient()
+	resp, err := client.Execute(ctx, ExecuteRequest{
+		Method:   "GET",
+		URL:      server.URL,
+		Settings: &models.RequestSettings{SSEReconnect: true},
+		OnEvent:  func(models.ServerSentEvent) { cancel() },
+	})
+	if err != nil {
+		t.Fatalf("cancelling a stream should end it without an error, got %v", err)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Data != "hello" {
+		t.Errorf("events = %+v", resp.Events)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// requestTimer records per-phase timestamps of a single request through httptrace hooks
+type requestTimer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	end          time.Time
+	reused       bool
+}
+
+// newRequestTimer creates a requestTimer starting now
+func newRequestTimer() *requestTimer {
+	return &requestTimer{start: time.Now()}
+}
+
+// clientTrace returns httptrace hooks that feed this timer.
+//
+// Start hooks keep the earliest timestamp and done hooks keep the latest one, so
+// multi-address dials and proxy tunnels are measured as a single phase.
+func (t *requestTimer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.markFirst(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.markLast(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.markFirst(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.markLast(&t.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			t.markFirst(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.markLast(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.markLast(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.markFirst(&t.firstByte)
+		},
+	}
+}
+
+// finish marks the end of the request, after the body has been read
+func (t *requestTimer) finish() {
+	t.markLast(&t.end)
+}
+
+func (t *requestTimer) markFirst(ts *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts.IsZero() {
+		*ts = time.Now()
+	}
+}
+
+func (t *requestTimer) markLast(ts *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	*ts = time.Now()
+}
+
+// timing converts the recorded timestamps into a phase breakdown
+func (t *requestTimer) timing() *models.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Server think time starts once the request is written; fall back to the
+	// last connection milestone when the transport did not report it
+	waitStart := t.wroteRequest
+	if waitStart.IsZero() {
+		waitStart = latest(t.start, t.connectDone, t.tlsDone)
+	}
+
+	return &models.Timing{
+		DNS:      elapsedMs(t.dnsStart, t.dnsDone),
+		Connect:  elapsedMs(t.connectStart, t.connectDone),
+		TLS:      elapsedMs(t.tlsStart, t.tlsDone),
+		TTFB:     elapsedMs(waitStart, t.firstByte),
+		Download: elapsedMs(t.firstByte, t.end),
+		Total:    elapsedMs(t.start, t.end),
+		Reused:   t.reused,
+	}
+}
+
+// elapsedMs returns the milliseconds between two timestamps, or 0 if either is missing
+func elapsedMs(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+func latest(times ...time.Time) time.Time {
+	var result time.Time
+	for _, ts := range times {
+		if ts.After(result) {
+			result = ts
+		}
+	}
+	return result
+}
+
+// traceConnectDone reports a completed connection to any httptrace hooks on ctx.
+// Used after a proxy tunnel is established, since the dialer only reports the hop to the proxy.
+func traceConnectDone(ctx context.Context, network, addr string, err error) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone(network, addr, err)
+	}
+}
+
+// traceTLSHandshakeStart reports the start of a manual TLS handshake to any httptrace hooks on ctx
+func traceTLSHandshakeStart(ctx context.Context) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+}
+
+// traceTLSHandshakeDone reports the end of a manual TLS handshake to any httptrace hooks on ctx
+func traceTLSHandshakeDone(ctx context.Context, state tls.ConnectionState, err error) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state, err)
+	}
+}
+
+// BuildTimingJSON builds JSON string from a timing breakdown
+func BuildTimingJSON(timing *models.Timing) string {
+	if timing == nil {
+		return ""
+	}
+	data, _ := json.Marshal(timing)
+	return string(data)
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExecuteReportsPhaseTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	client.SetUseSystemProxy(false)
+
+	resp, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Timing == nil {
+		t.Fatal("expected timing breakdown")
+	}
+
+	timing := resp.Timing
+	if timing.Connect <= 0 {
+		t.Fatalf("Connect = %v, want > 0", timing.Connect)
+	}
+	if timing.TTFB < 20 {
+		t.Fatalf("TTFB = %v, want >= 20", timing.TTFB)
+	}
+	if timing.Total < timing.Connect+timing.TTFB {
+		t.Fatalf("Total = %v, want >= connect + ttfb (%v)", timing.Total, timing.Connect+timing.TTFB)
+	}
+}
+
+func TestElapsedMs(t *testing.T) {
+	base := time.Now()
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want float64
+	}{
+		{name: "normal", from: base, to: base.Add(1500 * time.Microsecond), want: 1.5},
+		{name: "missing start", from: time.Time{}, to: base, want: 0},
+		{name: "missing end", from: base, to: time.Time{}, want: 0},
+		{name: "reversed", from: base.Add(time.Millisecond), to: base, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := elapsedMs(tt.from, tt.to); got != tt.want {
+			t.Fatalf("%s: elapsedMs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	utls "github.com/refraction-networking/utls"
+)
+
+// tlsOptions are per-request TLS settings carried to the transport on the request context
+type tlsOptions struct {
+	skipVerify bool
+	profile    tlsProfile
+}
+
+type tlsOptionsKey struct{}
+
+// withTLSOptions attaches per-request TLS settings to ctx
+func withTLSOptions(ctx context.Context, opts tlsOptions) context.Context {
+	return context.WithValue(ctx, tlsOptionsKey{}, opts)
+}
+
+// tlsOptionsFrom returns the TLS settings attached to ctx, or the defaults
+func tlsOptionsFrom(ctx context.Context) tlsOptions {
+	opts, _ := ctx.Value(tlsOptionsKey{}).(tlsOptions)
+	if opts.profile.name == "" {
+		opts.profile, _ = lookupTLSProfile(models.DefaultTLSProfile)
+	}
+	return opts
+}
+
+// tlsConfig builds the TLS configuration for a connection to addr (host:port),
+// applying the client certificates and CAs configured for that host
+func (t *utlsTransport) tlsConfig(addr, serverName string, opts tlsOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: opts.skipVerify,
+	}
+	if t.certs == nil {
+		return config, nil
+	}
+
+	material, err := t.certs.materialFor(addr)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = material.certificates
+	config.RootCAs = material.rootCAs
+	return config, nil
+}
+
+// dialPlainTLS performs a standard crypto/tls handshake for the plain transport,
+// whose only TLS connections are to HTTPS proxies
+func (t *utlsTransport) dialPlainTLS(skipVerify bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config, err := t.tlsConfig(addr, host, tlsOptions{skipVerify: skipVerify})
+		if err != nil {
+			return nil, err
+		}
+		config.NextProtos = []string{"http/1.1"}
+
+		conn, err := t.dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// negotiatedProtocol returns the ALPN protocol of a crypto/tls or uTLS connection
+func negotiatedProtocol(conn net.Conn) string {
+	switch c := conn.(type) {
+	case *tls.Conn:
+		return c.ConnectionState().NegotiatedProtocol
+	case *utls.UConn:
+		return c.ConnectionState().NegotiatedProtocol
+	}
+	return ""
+}
+
+// utlsConnectionState converts a uTLS connection state for httptrace hooks
+func utlsConnectionState(state utls.ConnectionState) tls.ConnectionState {
+	return tls.ConnectionState{
+		Version:            state.Version,
+		HandshakeComplete:  state.HandshakeComplete,
+		DidResume:          state.DidResume,
+		CipherSuite:        state.CipherSuite,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+		PeerCertificates:   state.PeerCertificates,
+	}
+}
+
+// toUTLSConfig converts a crypto/tls configuration to its uTLS equivalent
+func toUTLSConfig(config *tls.Config) *utls.Config {
+	certificates := make([]utls.Certificate, 0, len(config.Certificates))
+	for _, cert := range config.Certificates {
+		certificates = append(certificates, utls.Certificate{
+			Certificate: cert.Certificate,
+			PrivateKey:  cert.PrivateKey,
+			Leaf:        cert.Leaf,
+		})
+	}
+
+	return &utls.Config{
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		RootCAs:            config.RootCAs,
+		Certificates:       certificates,
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/database"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+func TestExecuteWithClientCertificateAndCustomCA(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCertificate(t, nil, nil, "Test CA", true)
+	serverCert, serverKey := newTestCertificate(t, caCert, caKey, "127.0.0.1", false)
+	clientCert, clientKey := newTestCertificate(t, caCert, caKey, "client", false)
+
+	caFile := writeTestPEM(t, dir, "ca.pem", "CERTIFICATE", caCert.Raw)
+	clientFile := writeTestPEM(t, dir, "client.pem", "CERTIFICATE", clientCert.Raw)
+	clientKeyFile := writeTestPEM(t, dir, "client-key.pem", "PRIVATE KEY", marshalTestKey(t, clientKey))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	certs := NewCertificateService(newTestDB(t), filepath.Join(dir, "certs"))
+	client := NewHTTPClient()
+	client.SetCertificateService(certs)
+
+	if _, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL}); err == nil {
+		t.Fatal("expected an unknown authority error before importing the CA")
+	}
+
+	if _, err := certs.ImportCA("", "127.0.0.1", caFile); err != nil {
+		t.Fatalf("ImportCA: %v", err)
+	}
+	if _, err := certs.ImportPEM("", "*.example.com", clientFile, clientKeyFile); err != nil {
+		t.Fatalf("ImportPEM: %v", err)
+	}
+	client.FlushConnections()
+	if _, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL}); err == nil {
+		t.Fatal("expected the handshake to fail when no client certificate matches the host")
+	}
+
+	imported, err := certs.ImportPEM("", "127.0.0.1", clientFile, clientKeyFile)
+	if err != nil {
+		t.Fatalf("ImportPEM: %v", err)
+	}
+	if imported.Name != "client" || imported.Type != models.CertificateTypeClient {
+		t.Fatalf("imported = %+v", imported)
+	}
+	client.FlushConnections()
+	resp, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if resp.Body != "client" {
+		t.Fatalf("server saw client certificate %q, want %q", resp.Body, "client")
+	}
+}
+
+func TestExecuteSkipTLSVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	if _, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL}); err == nil {
+		t.Fatal("expected self-signed certificate to be rejected")
+	}
+
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method:   "GET",
+		URL:      server.URL,
+		Settings: &models.RequestSettings{SkipTLSVerify: true},
+	})
+	if err != nil {
+		t.Fatalf("Execute with SkipTLSVerify: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d", resp.StatusCode)
+	}
+
+	// The unverified connection must not be reused for a verified request
+	if _, err := client.Execute(context.Background(), ExecuteRequest{Method: "GET", URL: server.URL}); err == nil {
+		t.Fatal("expected verification to apply again without SkipTLSVerify")
+	}
+}
+
+func TestMatchCertificateHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		addr    string
+		want    bool
+	}{
+		{pattern: "", addr: "api.example.com:443", want: true},
+		{pattern: "api.example.com", addr: "API.example.com:443", want: true},
+		{pattern: "api.example.com", addr: "example.com:443"},
+		{pattern: "*.example.com", addr: "api.example.com:443", want: true},
+		{pattern: "*.example.com", addr: "example.com:443"},
+		{pattern: "localhost:8443", addr: "localhost:8443", want: true},
+		{pattern: "localhost:8443", addr: "localhost:443"},
+	}
+
+	for _, tt := range tests {
+		if got := matchCertificateHost(tt.pattern, tt.addr); got != tt.want {
+			t.Fatalf("matchCertificateHost(%q, %q) = %v, want %v", tt.pattern, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, commonName string, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if ip := net.ParseIP(commonName); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func marshalTestKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func writeTestPEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
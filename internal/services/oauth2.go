@@ -0,0 +1,590 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/database/repository"
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// oauth2ExpirySkew is how long before it expires a token is refreshed, so it does
+	// not expire on its way to the server
+	oauth2ExpirySkew = 30 * time.Second
+
+	// oauth2AuthorizeTimeout is how long the loopback listener waits for the browser
+	oauth2AuthorizeTimeout = 5 * time.Minute
+
+	// oauth2ResponseLimit caps the size of token endpoint responses
+	oauth2ResponseLimit = 1 << 20
+
+	oauth2DeviceGrant = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// oauth2Doer sends a request to an authorization server, with the proxy and TLS
+// settings of the request needing the token
+type oauth2Doer func(*http.Request) (*http.Response, error)
+
+// OAuth2Service obtains OAuth 2.0 access tokens and caches them per environment
+type OAuth2Service struct {
+	repo *repository.OAuth2TokenRepository
+	now  func() time.Time
+
+	// pollUnit is the unit of the device code interval and lifetime; a second outside tests
+	pollUnit time.Duration
+
+	// Serializes fetching tokens so concurrent requests refresh a token once
+	mu sync.Mutex
+}
+
+// NewOAuth2Service creates a new OAuth2Service
+func NewOAuth2Service(db *sqlx.DB) *OAuth2Service {
+	return &OAuth2Service{
+		repo:     repository.NewOAuth2TokenRepository(db),
+		now:      time.Now,
+		pollUnit: time.Second,
+	}
+}
+
+// oauth2Error is an error response of an authorization server (RFC 6749 section 5.2)
+type oauth2Error struct {
+	Code        string
+	Description string
+}
+
+func (e *oauth2Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %s: %s", e.Code, e.Description)
+	}
+	return "oauth2: " + e.Code
+}
+
+// oauth2Response holds the fields of token and device authorization responses
+type oauth2Response struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Scope        string
+	ExpiresIn    int64
+
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURL         string // Google's name for verification_uri
+	VerificationURIComplete string
+	Interval                int64
+}
+
+// Token returns the access token for config in envID: the cached one while it is
+// valid, else a refreshed one, else a new one from a grant that needs no user. An
+// authorization code or device code grant without a usable token needs Authorize.
+func (s *OAuth2Service) Token(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, envID int64) (*models.OAuth2Token, error) {
+	if err := validateOAuth2Config(config); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := oauth2CacheKey(config)
+	cached, err := s.CachedToken(config, envID)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && s.valid(cached) {
+		return cached, nil
+	}
+
+	var refreshErr error
+	if cached != nil && cached.RefreshToken != "" {
+		token, err := s.grant(ctx, do, config, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cached.RefreshToken},
+		})
+		if err == nil {
+			if token.RefreshToken == "" {
+				// The server keeps the refresh token unless it sends a new one
+				token.RefreshToken = cached.RefreshToken
+			}
+			return token, s.save(token, envID, key)
+		}
+		refreshErr = err
+	}
+
+	if interactiveOAuth2Grant(config.GrantType) {
+		if refreshErr != nil {
+			return nil, fmt.Errorf("oauth2: the token expired and could not be refreshed, get a new token: %w", refreshErr)
+		}
+		if cached != nil {
+			return nil, errors.New("oauth2: the token expired, get a new token")
+		}
+		return nil, errors.New("oauth2: no token yet, get a new token")
+	}
+
+	token, err := s.grant(ctx, do, config, oauth2GrantForm(config))
+	if err != nil {
+		return nil, err
+	}
+	return token, s.save(token, envID, key)
+}
+
+// Authorize obtains a new access token for config in envID and caches it, whatever
+// is cached. The authorization code and device code grants call prompt with what the
+// user must open or enter.
+func (s *OAuth2Service) Authorize(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, envID int64, prompt func(models.OAuth2Prompt)) (*models.OAuth2Token, error) {
+	if err := validateOAuth2Config(config); err != nil {
+		return nil, err
+	}
+
+	var token *models.OAuth2Token
+	var err error
+	switch config.GrantType {
+	case models.OAuth2GrantAuthorizationCode:
+		token, err = s.authorizationCode(ctx, do, config, prompt)
+	case models.OAuth2GrantDeviceCode:
+		token, err = s.deviceCode(ctx, do, config, prompt)
+	default:
+		token, err = s.grant(ctx, do, config, oauth2GrantForm(config))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return token, s.save(token, envID, oauth2CacheKey(config))
+}
+
+// CachedToken returns the token cached for config in envID, or nil if there is none
+func (s *OAuth2Service) CachedToken(config *models.OAuth2Config, envID int64) (*models.OAuth2Token, error) {
+	token, err := s.repo.Get(envID, oauth2CacheKey(config))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+// ClearToken forgets the token cached for config in envID
+func (s *OAuth2Service) ClearToken(config *models.OAuth2Config, envID int64) error {
+	return s.repo.Delete(envID, oauth2CacheKey(config))
+}
+
+// valid reports whether token can still be sent
+func (s *OAuth2Service) valid(token *models.OAuth2Token) bool {
+	return token.ExpiresAt == nil || s.now().Add(oauth2ExpirySkew).Before(*token.ExpiresAt)
+}
+
+func (s *OAuth2Service) save(token *models.OAuth2Token, envID int64, key string) error {
+	token.EnvID, token.CacheKey = envID, key
+	return s.repo.Save(token)
+}
+
+// grant posts form to the token endpoint and returns the token it answers with
+func (s *OAuth2Service) grant(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, form url.Values) (*models.OAuth2Token, error) {
+	resp, err := postOAuth2Form(ctx, do, config, config.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("oauth2: the token response has no access_token")
+	}
+
+	token := &models.OAuth2Token{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		TokenType:    resp.TokenType,
+		Scope:        resp.Scope,
+	}
+	if resp.ExpiresIn > 0 {
+		expires := s.now().Add(time.Duration(resp.ExpiresIn) * time.Second).UTC().Truncate(time.Second)
+		token.ExpiresAt = &expires
+	}
+	return token, nil
+}
+
+// authorizationCode runs the authorization code grant with PKCE (RFC 7636), receiving
+// the code on a loopback redirect URI (RFC 8252 section 7.3)
+func (s *OAuth2Service) authorizationCode(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, prompt func(models.OAuth2Prompt)) (*models.OAuth2Token, error) {
+	listener, redirect, err := listenOAuth2Redirect(config.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	verifier := randomOAuth2String()
+	challenge := sha256.Sum256([]byte(verifier))
+	state := randomOAuth2String()
+
+	authURL, err := url.Parse(config.AuthURL)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: invalid authorization URL: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", redirect.String())
+	if config.Scope != "" {
+		query.Set("scope", config.Scope)
+	}
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	type callback struct {
+		code string
+		err  error
+	}
+	result := make(chan callback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != redirect.Path {
+			http.NotFound(w, r)
+			return
+		}
+		params := r.URL.Query()
+		if params.Get("state") != state {
+			writeOAuth2Page(w, http.StatusBadRequest, "The authorization response does not match the request. Try again from PostMe.")
+			return
+		}
+		var got callback
+		if code := params.Get("error"); code != "" {
+			got.err = &oauth2Error{Code: code, Description: params.Get("error_description")}
+			writeOAuth2Page(w, http.StatusOK, "Authorization failed: "+got.err.Error())
+		} else if got.code = params.Get("code"); got.code == "" {
+			got.err = errors.New("oauth2: the authorization response has no code")
+			writeOAuth2Page(w, http.StatusBadRequest, "The authorization response has no code.")
+		} else {
+			writeOAuth2Page(w, http.StatusOK, "Authorization complete. You can close this window and return to PostMe.")
+		}
+		select {
+		case result <- got:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if prompt != nil {
+		prompt(models.OAuth2Prompt{AuthorizationURL: authURL.String()})
+	}
+
+	timeout := time.NewTimer(oauth2AuthorizeTimeout)
+	defer timeout.Stop()
+	var got callback
+	select {
+	case got = <-result:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout.C:
+		return nil, errors.New("oauth2: timed out waiting for the browser to authorize")
+	}
+	if got.err != nil {
+		return nil, got.err
+	}
+
+	return s.grant(ctx, do, config, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {got.code},
+		"redirect_uri":  {redirect.String()},
+		"code_verifier": {verifier},
+	})
+}
+
+// deviceCode runs the device authorization grant (RFC 8628), polling the token
+// endpoint until the user has entered the code
+func (s *OAuth2Service) deviceCode(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, prompt func(models.OAuth2Prompt)) (*models.OAuth2Token, error) {
+	form := url.Values{}
+	if config.Scope != "" {
+		form.Set("scope", config.Scope)
+	}
+	device, err := postOAuth2Form(ctx, do, config, config.DeviceAuthURL, form)
+	if err != nil {
+		return nil, err
+	}
+	if device.DeviceCode == "" {
+		return nil, errors.New("oauth2: the device authorization response has no device_code")
+	}
+	verification := device.VerificationURI
+	if verification == "" {
+		verification = device.VerificationURL
+	}
+	if prompt != nil {
+		prompt(models.OAuth2Prompt{
+			AuthorizationURL: device.VerificationURIComplete,
+			UserCode:         device.UserCode,
+			VerificationURI:  verification,
+			ExpiresIn:        int(device.ExpiresIn),
+		})
+	}
+
+	interval := device.Interval
+	if interval <= 0 {
+		interval = 5
+	}
+	var deadline <-chan time.Time
+	if device.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(device.ExpiresIn) * s.pollUnit)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, errors.New("oauth2: the device code expired before it was entered")
+		case <-time.After(time.Duration(interval) * s.pollUnit):
+		}
+
+		token, err := s.grant(ctx, do, config, url.Values{
+			"grant_type":  {oauth2DeviceGrant},
+			"device_code": {device.DeviceCode},
+		})
+		var oerr *oauth2Error
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.As(err, &oerr) && oerr.Code == "authorization_pending":
+		case errors.As(err, &oerr) && oerr.Code == "slow_down":
+			interval += 5
+		default:
+			return nil, err
+		}
+	}
+}
+
+// postOAuth2Form posts form to an endpoint of the authorization server, authenticating
+// the client, and decodes the JSON or form-encoded response
+func postOAuth2Form(ctx context.Context, do oauth2Doer, config *models.OAuth2Config, endpoint string, form url.Values) (*oauth2Response, error) {
+	form = cloneValues(form)
+	basic := config.ClientSecret != "" && config.ClientAuth != models.OAuth2ClientAuthBody
+	if !basic {
+		form.Set("client_id", config.ClientID)
+		if config.ClientSecret != "" {
+			form.Set("client_secret", config.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before Basic encoding them
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oauth2ResponseLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := decodeOAuth2Body(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("oauth2: %s returned %s", endpoint, resp.Status)
+		}
+		return nil, err
+	}
+	if code := values.Get("error"); code != "" {
+		return nil, &oauth2Error{Code: code, Description: values.Get("error_description")}
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("oauth2: %s returned %s", endpoint, resp.Status)
+	}
+
+	result := &oauth2Response{
+		AccessToken:             values.Get("access_token"),
+		TokenType:               values.Get("token_type"),
+		RefreshToken:            values.Get("refresh_token"),
+		Scope:                   values.Get("scope"),
+		DeviceCode:              values.Get("device_code"),
+		UserCode:                values.Get("user_code"),
+		VerificationURI:         values.Get("verification_uri"),
+		VerificationURL:         values.Get("verification_url"),
+		VerificationURIComplete: values.Get("verification_uri_complete"),
+	}
+	result.ExpiresIn, _ = strconv.ParseInt(values.Get("expires_in"), 10, 64)
+	result.Interval, _ = strconv.ParseInt(values.Get("interval"), 10, 64)
+	return result, nil
+}
+
+// decodeOAuth2Body returns the top-level fields of a JSON or form-encoded response as
+// strings. Numbers are kept as written; some servers send them as strings.
+func decodeOAuth2Body(contentType string, body []byte) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "text/plain" {
+		return url.ParseQuery(string(body))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("oauth2: cannot decode the response: %w", err)
+	}
+	values := url.Values{}
+	for name, raw := range fields {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			values.Set(name, s)
+		} else if len(raw) > 0 && raw[0] != '{' && raw[0] != '[' && string(raw) != "null" {
+			values.Set(name, string(raw))
+		}
+	}
+	return values, nil
+}
+
+// oauth2GrantForm returns the token request of a grant that needs no user
+func oauth2GrantForm(config *models.OAuth2Config) url.Values {
+	form := url.Values{}
+	switch config.GrantType {
+	case models.OAuth2GrantPassword:
+		form.Set("grant_type", "password")
+		form.Set("username", config.Username)
+		form.Set("password", config.Password)
+	case models.OAuth2GrantRefreshToken:
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", config.RefreshToken)
+	default:
+		form.Set("grant_type", "client_credentials")
+	}
+	if config.Scope != "" {
+		form.Set("scope", config.Scope)
+	}
+	return form
+}
+
+// interactiveOAuth2Grant reports whether a grant needs the user to sign in
+func interactiveOAuth2Grant(grantType string) bool {
+	return grantType == models.OAuth2GrantAuthorizationCode || grantType == models.OAuth2GrantDeviceCode
+}
+
+func validateOAuth2Config(config *models.OAuth2Config) error {
+	if config == nil {
+		return errors.New("oauth2: not configured")
+	}
+	switch config.GrantType {
+	case models.OAuth2GrantClientCredentials, models.OAuth2GrantPassword, models.OAuth2GrantRefreshToken:
+	case models.OAuth2GrantAuthorizationCode:
+		if config.AuthURL == "" {
+			return errors.New("oauth2: the authorization URL is required")
+		}
+	case models.OAuth2GrantDeviceCode:
+		if config.DeviceAuthURL == "" {
+			return errors.New("oauth2: the device authorization URL is required")
+		}
+	default:
+		return fmt.Errorf("oauth2: unsupported grant type %q", config.GrantType)
+	}
+	if config.TokenURL == "" {
+		return errors.New("oauth2: the token URL is required")
+	}
+	return nil
+}
+
+// oauth2CacheKey identifies the tokens config obtains. Secrets are left out so a
+// changed secret keeps using the cached token.
+func oauth2CacheKey(config *models.OAuth2Config) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		config.GrantType, config.TokenURL, config.AuthURL, config.DeviceAuthURL,
+		config.ClientID, config.Scope, config.Username,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// listenOAuth2Redirect listens on the loopback address of redirectURI, or on a free
+// port of 127.0.0.1 when it is empty, and returns the redirect URI to register
+func listenOAuth2Redirect(redirectURI string) (net.Listener, *url.URL, error) {
+	if redirectURI == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		return listener, &url.URL{Scheme: "http", Host: listener.Addr().String(), Path: "/callback"}, nil
+	}
+
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oauth2: invalid redirect URI: %w", err)
+	}
+	host := redirect.Hostname()
+	loopback := host == "localhost"
+	if ip := net.ParseIP(host); ip != nil {
+		loopback = ip.IsLoopback()
+	}
+	if redirect.Scheme != "http" || !loopback {
+		return nil, nil, fmt.Errorf("oauth2: the redirect URI %s is not an http loopback address", redirectURI)
+	}
+	port := redirect.Port()
+	if port == "" {
+		port = "80"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, nil, err
+	}
+	if redirect.Path == "" {
+		redirect.Path = "/"
+	}
+	return listener, redirect, nil
+}
+
+// writeOAuth2Page answers the browser sent to the loopback redirect URI
+func writeOAuth2Page(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<!doctype html><title>PostMe</title><p>%s</p>", html.EscapeString(message))
+}
+
+// randomOAuth2String returns a random state or PKCE code verifier
+func randomOAuth2String() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for k, v := range values {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// oauth2Auth returns the auth sending token the way config asks: as a header, or as a
+// query parameter
+func oauth2Auth(config *models.OAuth2Config, token *models.OAuth2Token) *models.AuthConfig {
+	if config.In == models.AuthInQuery {
+		param := config.Param
+		if param == "" {
+			param = "access_token"
+		}
+		return &models.AuthConfig{Type: models.AuthTypeAPIKey, Key: param, Value: token.AccessToken, In: models.AuthInQuery}
+	}
+	return &models.AuthConfig{Type: models.AuthTypeBearer, Token: token.AccessToken, Prefix: config.Prefix}
+}
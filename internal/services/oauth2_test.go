@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// testAuthServer is a stand-in OAuth 2.0 authorization server with an API echoing the
+// token it receives
+type testAuthServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	issued    int
+	grants    []string          // grant_type of each token request
+	refresh   map[string]bool   // valid refresh tokens
+	challenge map[string]string // code -> PKCE challenge
+	pending   int               // device polls answered authorization_pending
+}
+
+func newTestAuthServer(t *testing.T) *testAuthServer {
+	s := &testAuthServer{refresh: map[string]bool{}, challenge: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("client_id") != "app" || q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "bad authorization request", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.challenge["code-1"] = q.Get("code_challenge")
+		s.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-1&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]any{
+			"device_code": "dev-1", "user_code": "ABCD-EFGH", "verification_uri": s.URL + "/activate",
+			"expires_in": 60, "interval": 1,
+		})
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization")+"|"+r.URL.RawQuery)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant := r.PostForm.Get("grant_type")
+	s.grants = append(s.grants, grant)
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	public := grant == "authorization_code" || grant == oauth2DeviceGrant
+	if id != "app" || secret != "s3cret" && !(public && secret == "") {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	switch grant {
+	case "client_credentials":
+	case "password":
+		if r.PostForm.Get("username") != "alice" || r.PostForm.Get("password") != "pw" {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		if !s.refresh[r.PostForm.Get("refresh_token")] {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "authorization_code":
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		want, found := s.challenge[r.PostForm.Get("code")]
+		if !found || want != base64.RawURLEncoding.EncodeToString(sum[:]) || r.PostForm.Get("redirect_uri") == "" {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+	case oauth2DeviceGrant:
+		if s.pending < 2 {
+			s.pending++
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			return
+		}
+	default:
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.issued++
+	refresh := fmt.Sprintf("rt-%d", s.issued)
+	s.refresh[refresh] = true
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"access_token": fmt.Sprintf("at-%d", s.issued), "token_type": "Bearer",
+		"refresh_token": refresh, "expires_in": "3600", // some servers send a string
+	})
+}
+
+func (s *testAuthServer) grantTypes() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.grants, " ")
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestOAuth2Client returns a client caching tokens with a clock that only moves when
+// the returned function advances it
+func newTestOAuth2Client(t *testing.T) (*HTTPClient, *OAuth2Service, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewOAuth2Service(newTestDB(t))
+	service.now = func() time.Time { return now }
+	service.pollUnit = time.Millisecond
+	client := NewHTTPClient()
+	client.SetOAuth2Service(service)
+	return client, service, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestOAuth2ClientCredentialsCachedPerEnvironment(t *testing.T) {
+	server := newTestAuthServer(t)
+	client, _, advance := newTestOAuth2Client(t)
+	auth := &models.AuthConfig{Type: models.AuthTypeOAuth2, OAuth2: &models.OAuth2Config{
+		GrantType: models.OAuth2GrantClientCredentials, TokenURL: server.URL + "/token",
+		ClientID: "app", ClientSecret: "s3cret", Scope: "read",
+	}}
+	call := func(envID int64) string {
+		t.Helper()
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method: http.MethodGet, URL: server.URL + "/api", Auth: auth, EnvironmentID: &envID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Body
+	}
+
+	if got := call(1); got != "Bearer at-1|" {
+		t.Fatalf("first request sent %q", got)
+	}
+	if got := call(1); got != "Bearer at-1|" {
+		t.Errorf("second request sent %q, want the cached token", got)
+	}
+	if got := call(2); got != "Bearer at-2|" {
+		t.Errorf("another environment sent %q, want its own token", got)
+	}
+
+	advance(time.Hour - 10*time.Second) // within the expiry skew
+	if got := call(1); got != "Bearer at-3|" {
+		t.Errorf("request near expiry sent %q, want a refreshed token", got)
+	}
+	if got := server.grantTypes(); got != "client_credentials client_credentials refresh_token" {
+		t.Errorf("token requests = %q", got)
+	}
+}
+
+func TestOAuth2PasswordGrantInQuery(t *testing.T) {
+	server := newTestAuthServer(t)
+	client, service, _ := newTestOAuth2Client(t)
+	config := &models.OAuth2Config{
+		GrantType: models.OAuth2GrantPassword, TokenURL: server.URL + "/token",
+		ClientID: "app", ClientSecret: "s3cret", ClientAuth: models.OAuth2ClientAuthBody,
+		Username: "alice", Password: "pw", In: models.AuthInQuery,
+	}
+	resp, err := client.Execute(context.Background(), ExecuteRequest{
+		Method: http.MethodGet, URL: server.URL + "/api?x=1",
+		Auth: &models.AuthConfig{Type: models.AuthTypeOAuth2, OAuth2: config},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != "|x=1&access_token=at-1" {
+		t.Errorf("server saw %q", resp.Body)
+	}
+
+	cached, err := service.CachedToken(config, 0)
+	if err != nil || cached == nil || cached.RefreshToken != "rt-1" {
+		t.Fatalf("cached token = %+v, %v", cached, err)
+	}
+	if err := service.ClearToken(config, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := service.CachedToken(config, 0); cached != nil {
+		t.Errorf("token still cached after ClearToken")
+	}
+
+	config.Password = "wrong"
+	_, err = service.Token(context.Background(), http.DefaultClient.Do, config, 0)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("wrong password error = %v", err)
+	}
+}
+
+func TestOAuth2AuthorizationCodeWithPKCE(t *testing.T) {
+	server := newTestAuthServer(t)
+	client, _, _ := newTestOAuth2Client(t)
+	auth := &models.AuthConfig{Type: models.AuthTypeOAuth2, OAuth2: &models.OAuth2Config{
+		GrantType: models.OAuth2GrantAuthorizationCode, AuthURL: server.URL + "/authorize",
+		TokenURL: server.URL + "/token", ClientID: "app", ClientSecret: "s3cret",
+	}}
+	req := ExecuteRequest{Method: http.MethodGet, URL: server.URL + "/api", Auth: auth}
+
+	if _, err := client.Execute(context.Background(), req); err == nil || !strings.Contains(err.Error(), "get a new token") {
+		t.Fatalf("request without a token: err = %v", err)
+	}
+
+	// The browser follows the redirect to the loopback listener
+	browser := make(chan string, 1)
+	token, err := client.AuthorizeOAuth2(context.Background(), req, func(prompt models.OAuth2Prompt) {
+		go func() {
+			resp, err := http.Get(prompt.AuthorizationURL)
+			if err != nil {
+				browser <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			browser <- string(body)
+		}()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := <-browser; !strings.Contains(page, "Authorization complete") {
+		t.Errorf("browser saw %q", page)
+	}
+	if token.AccessToken != "at-1" {
+		t.Errorf("token = %q", token.AccessToken)
+	}
+
+	resp, err := client.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != "Bearer at-1|" {
+		t.Errorf("server saw %q", resp.Body)
+	}
+}
+
+func TestOAuth2DeviceCode(t *testing.T) {
+	server := newTestAuthServer(t)
+	client, _, _ := newTestOAuth2Client(t)
+	auth := &models.AuthConfig{Type: models.AuthTypeOAuth2, OAuth2: &models.OAuth2Config{
+		GrantType: models.OAuth2GrantDeviceCode, DeviceAuthURL: server.URL + "/device",
+		TokenURL: server.URL + "/token", ClientID: "app", ClientSecret: "s3cret",
+	}}
+
+	var prompts []models.OAuth2Prompt
+	token, err := client.AuthorizeOAuth2(context.Background(), ExecuteRequest{Auth: auth}, func(prompt models.OAuth2Prompt) {
+		prompts = append(prompts, prompt)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at-1" {
+		t.Errorf("token = %q", token.AccessToken)
+	}
+	if len(prompts) != 1 || prompts[0].UserCode != "ABCD-EFGH" || prompts[0].VerificationURI != server.URL+"/activate" {
+		t.Errorf("prompts = %+v", prompts)
+	}
+	want := strings.TrimSpace(strings.Repeat(oauth2DeviceGrant+" ", 3))
+	if got := server.grantTypes(); got != want {
+		t.Errorf("token requests = %q, want three polls", got)
+	}
+}
+
+func TestOAuth2TokenWhileSettingsChange(t *testing.T) {
+	server := newTestAuthServer(t)
+	client, _, _ := newTestOAuth2Client(t)
+	auth := &models.AuthConfig{Type: models.AuthTypeOAuth2, OAuth2: &models.OAuth2Config{
+		GrantType: models.OAuth2GrantClientCredentials, TokenURL: server.URL + "/token", ClientID: "app", ClientSecret: "s3cret",
+	}}
+
+	// Settings changes replace the http.Client the token requests are sent with
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 20 {
+			client.SetUseSystemProxy(i%2 == 0)
+		}
+	}()
+	for i := range 20 {
+		envID := int64(i)
+		if _, err := client.AuthorizeOAuth2(context.Background(), ExecuteRequest{Auth: auth, EnvironmentID: &envID}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,392 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// linuxProxyCacheTTL is how long detected settings are reused; reading GNOME settings
+// runs gsettings, which is too slow to do for every request
+const linuxProxyCacheTTL = 10 * time.Second
+
+var linuxProxyCache struct {
+	sync.Mutex
+	config  *models.ProxyConfig
+	expires time.Time
+}
+
+// getSystemProxy reads the proxy configured in the desktop settings (GNOME or KDE)
+// or in /etc/environment. It returns nil when none is configured, in which case the
+// process environment variables decide.
+func getSystemProxy() *models.ProxyConfig {
+	linuxProxyCache.Lock()
+	defer linuxProxyCache.Unlock()
+
+	if time.Now().After(linuxProxyCache.expires) {
+		linuxProxyCache.config = defaultLinuxProxySources().systemProxy()
+		linuxProxyCache.expires = time.Now().Add(linuxProxyCacheTTL)
+	}
+	return linuxProxyCache.config
+}
+
+// linuxProxySources are the places a Linux desktop keeps its proxy settings
+type linuxProxySources struct {
+	desktop         string                 // value of XDG_CURRENT_DESKTOP
+	gsettings       func() (string, error) // output of gsettings list-recursively org.gnome.system.proxy
+	kioslaverc      []string               // candidate kioslaverc paths, the first one that exists is used
+	environmentFile string
+	getenv          func(string) string
+}
+
+// defaultLinuxProxySources returns the sources of the current user session
+func defaultLinuxProxySources() linuxProxySources {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return linuxProxySources{
+		desktop: os.Getenv("XDG_CURRENT_DESKTOP"),
+		gsettings: func() (string, error) {
+			out, err := exec.Command("gsettings", "list-recursively", "org.gnome.system.proxy").Output()
+			return string(out), err
+		},
+		kioslaverc: []string{
+			filepath.Join(configHome, "kioslaverc"),
+			filepath.Join(home, ".kde4", "share", "config", "kioslaverc"),
+			filepath.Join(home, ".kde", "share", "config", "kioslaverc"),
+		},
+		environmentFile: "/etc/environment",
+		getenv:          os.Getenv,
+	}
+}
+
+// systemProxy returns the proxy configured for the session, or nil if there is none.
+//
+// KDE sessions read kioslaverc and every other desktop reads the GNOME settings, which
+// Cinnamon, MATE and Budgie share. /etc/environment is only consulted when the process
+// did not inherit proxy variables of its own, so a proxy set in the shell still wins.
+func (s linuxProxySources) systemProxy() *models.ProxyConfig {
+	var config *models.ProxyConfig
+	if isKDESession(s.desktop) {
+		config = s.kdeProxy()
+	} else if s.gsettings != nil {
+		if out, err := s.gsettings(); err == nil {
+			config = parseGNOMEProxy(out)
+		}
+	}
+	if config != nil {
+		return config
+	}
+
+	for _, key := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy"} {
+		if s.getenv(key) != "" {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(s.environmentFile)
+	if err != nil {
+		return nil
+	}
+	return parseEnvironmentProxy(string(data))
+}
+
+// kdeProxy reads the first kioslaverc that exists
+func (s linuxProxySources) kdeProxy() *models.ProxyConfig {
+	for _, path := range s.kioslaverc {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return parseKDEProxy(string(data), s.getenv)
+		}
+	}
+	return nil
+}
+
+// isKDESession reports whether XDG_CURRENT_DESKTOP names KDE; it may hold a
+// colon-separated list such as "KDE:plasma"
+func isKDESession(desktop string) bool {
+	for _, name := range strings.Split(desktop, ":") {
+		if strings.EqualFold(name, "KDE") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGNOMEProxy parses the output of gsettings list-recursively org.gnome.system.proxy.
+// The "none" mode returns nil; "auto" uses the PAC script URL, or WPAD when it is empty.
+func parseGNOMEProxy(out string) *models.ProxyConfig {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		// Each line is "<schema> <key> <value>"
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		schema := strings.TrimPrefix(fields[0], "org.gnome.system.proxy")
+		values[strings.TrimPrefix(schema, ".")+" "+fields[1]] = fields[2]
+	}
+
+	switch gvariantString(values[" mode"]) {
+	case "manual":
+	case "auto":
+		pac := gvariantString(values[" autoconfig-url"])
+		return &models.ProxyConfig{
+			Enabled:    true,
+			PAC:        pac,
+			AutoDetect: pac == "",
+			Bypass:     gvariantStrings(values[" ignore-hosts"]),
+		}
+	default:
+		return nil
+	}
+
+	server := func(schema, scheme string) string {
+		host := gvariantString(values[schema+" host"])
+		if host == "" {
+			return ""
+		}
+		if port, _ := strconv.Atoi(values[schema+" port"]); port > 0 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return scheme + "://" + host
+	}
+
+	config := &models.ProxyConfig{
+		Enabled: true,
+		HTTP:    server("http", proxySchemeHTTP),
+		HTTPS:   server("https", proxySchemeHTTP),
+		SOCKS:   server("socks", proxySchemeSOCKS5),
+		Bypass:  gvariantStrings(values[" ignore-hosts"]),
+	}
+	if config.HTTP == "" && config.HTTPS == "" && config.SOCKS == "" {
+		return nil
+	}
+	if values["http use-authentication"] == "true" {
+		config.Username = gvariantString(values["http authentication-user"])
+		config.Password = gvariantString(values["http authentication-password"])
+	}
+	return config
+}
+
+// gvariantString decodes a GVariant string as printed by gsettings, e.g. 'manual'
+func gvariantString(value string) string {
+	value, _ = readGVariantString(strings.TrimSpace(value))
+	return value
+}
+
+// gvariantStrings decodes a GVariant string array as printed by gsettings, e.g.
+// ['localhost', '127.0.0.0/8']. An empty array is printed as "@as []".
+func gvariantStrings(value string) []string {
+	value = strings.TrimSpace(strings.TrimPrefix(value, "@as"))
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+
+	var list []string
+	for {
+		value = strings.TrimLeft(value, " ,")
+		if value == "" {
+			return list
+		}
+		item, rest := readGVariantString(value)
+		if rest == value {
+			return list
+		}
+		list = append(list, item)
+		value = rest
+	}
+}
+
+// readGVariantString reads one quoted string from the start of s and returns it
+// with the remaining input. s is returned unchanged if it does not start with a quote.
+func readGVariantString(s string) (string, string) {
+	if s == "" || s[0] != '\'' && s[0] != '"' {
+		return "", s
+	}
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == quote:
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ""
+}
+
+// KDE proxy types stored as ProxyType in kioslaverc
+const (
+	kdeProxyManual      = 1
+	kdeProxyPAC         = 2
+	kdeProxyWPAD        = 3
+	kdeProxyEnvironment = 4
+)
+
+// parseKDEProxy parses the [Proxy Settings] group of kioslaverc: manual proxies, a PAC
+// script, WPAD, or proxies taken from named environment variables. getenv resolves the
+// variable names stored in environment mode.
+func parseKDEProxy(data string, getenv func(string) string) *models.ProxyConfig {
+	values := make(map[string]string)
+	group := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line[1 : len(line)-1]
+			continue
+		}
+		if group != "Proxy Settings" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		// Drop KConfig flags such as httpProxy[$e]
+		if i := strings.Index(key, "["); i >= 0 {
+			key = key[:i]
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	proxyType, _ := strconv.Atoi(values["ProxyType"])
+	switch proxyType {
+	case kdeProxyManual:
+	case kdeProxyPAC:
+		if values["Proxy Config Script"] == "" {
+			return nil
+		}
+		return &models.ProxyConfig{Enabled: true, PAC: values["Proxy Config Script"]}
+	case kdeProxyWPAD:
+		return &models.ProxyConfig{Enabled: true, AutoDetect: true}
+	case kdeProxyEnvironment:
+		// Values name the variables to read, e.g. httpProxy=HTTP_PROXY
+		for _, key := range []string{"httpProxy", "httpsProxy", "socksProxy", "NoProxyFor"} {
+			resolved := ""
+			for _, name := range strings.Split(values[key], ",") {
+				if name = strings.TrimSpace(name); name != "" && getenv(name) != "" {
+					resolved = getenv(name)
+					break
+				}
+			}
+			values[key] = resolved
+		}
+	default:
+		return nil
+	}
+
+	// With ReversedException the list names the only hosts that use the proxy,
+	// which a bypass list cannot express
+	if values["ReversedException"] == "true" {
+		return nil
+	}
+
+	config := &models.ProxyConfig{
+		Enabled: true,
+		HTTP:    kdeProxyURL(values["httpProxy"], proxySchemeHTTP),
+		HTTPS:   kdeProxyURL(values["httpsProxy"], proxySchemeHTTP),
+		SOCKS:   kdeProxyURL(values["socksProxy"], proxySchemeSOCKS5),
+		Bypass:  splitProxyList(values["NoProxyFor"]),
+	}
+	if config.HTTP == "" && config.HTTPS == "" && config.SOCKS == "" {
+		return nil
+	}
+	return config
+}
+
+// kdeProxyURL normalizes a kioslaverc proxy entry. KDE separates the port with a space
+// ("http://proxy 3128") and writes SOCKS proxies as socks://.
+func kdeProxyURL(value, scheme string) string {
+	value = strings.TrimSpace(value)
+	if fields := strings.Fields(value); len(fields) == 2 {
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			value = fields[0] + ":" + fields[1]
+		}
+	}
+	if value == "" {
+		return ""
+	}
+
+	if rest, ok := strings.CutPrefix(value, "socks://"); ok {
+		return proxySchemeSOCKS5 + "://" + rest
+	}
+	if !strings.Contains(value, "://") {
+		return scheme + "://" + value
+	}
+	return value
+}
+
+// parseEnvironmentProxy reads the proxy variables of an /etc/environment file. Like
+// http.ProxyFromEnvironment, upper-case names take precedence. As with manual settings,
+// https requests fall back to http_proxy, and all_proxy is used when neither is set.
+func parseEnvironmentProxy(data string) *models.ProxyConfig {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+
+	lookup := func(name string) string {
+		if value := values[strings.ToUpper(name)]; value != "" {
+			return value
+		}
+		return values[name]
+	}
+
+	config := &models.ProxyConfig{
+		Enabled: true,
+		HTTP:    lookup("http_proxy"),
+		HTTPS:   lookup("https_proxy"),
+		SOCKS:   lookup("all_proxy"),
+		Bypass:  splitProxyList(lookup("no_proxy")),
+	}
+	if config.HTTP == "" && config.HTTPS == "" && config.SOCKS == "" {
+		return nil
+	}
+	return config
+}
+
+// splitProxyList splits a comma-separated host list, dropping empty entries
+func splitProxyList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -1,10 +1,12 @@
-//go:build !windows
+//go:build !windows && !linux
 
 package services
 
-// getSystemProxyURL returns platform system proxy settings when available.
+import "github.com/SoulTraitor/postme/internal/models"
+
+// getSystemProxy returns platform system proxy settings when available.
 //
-// macOS/Linux currently rely on http.ProxyFromEnvironment in http_client.go.
-func getSystemProxyURL() string {
-	return ""
+// macOS currently relies on http.ProxyFromEnvironment in http_client.go.
+func getSystemProxy() *models.ProxyConfig {
+	return nil
 }
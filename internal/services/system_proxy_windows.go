@@ -5,32 +5,42 @@ package services
 import (
 	"strings"
 
+	"github.com/SoulTraitor/postme/internal/models"
 	"golang.org/x/sys/windows/registry"
 )
 
-// getSystemProxyURL reads the Windows system proxy settings from registry.
-func getSystemProxyURL() string {
+// getSystemProxy reads the Windows system proxy settings from registry: a PAC script
+// from AutoConfigURL, or the proxy server and its bypass list.
+func getSystemProxy() *models.ProxyConfig {
 	key, err := registry.OpenKey(registry.CURRENT_USER,
 		`Software\Microsoft\Windows\CurrentVersion\Internet Settings`,
 		registry.QUERY_VALUE)
 	if err != nil {
-		return ""
+		return nil
 	}
 	defer key.Close()
 
+	if autoConfigURL, _, err := key.GetStringValue("AutoConfigURL"); err == nil && autoConfigURL != "" {
+		return &models.ProxyConfig{Enabled: true, PAC: autoConfigURL}
+	}
+
 	proxyEnable, _, err := key.GetIntegerValue("ProxyEnable")
 	if err != nil || proxyEnable == 0 {
-		return ""
+		return nil
 	}
 
 	proxyServer, _, err := key.GetStringValue("ProxyServer")
 	if err != nil || proxyServer == "" {
-		return ""
+		return nil
 	}
 
 	if !strings.HasPrefix(proxyServer, "http://") && !strings.HasPrefix(proxyServer, "https://") {
 		proxyServer = "http://" + proxyServer
 	}
 
-	return proxyServer
+	config := &models.ProxyConfig{Enabled: true, HTTP: proxyServer}
+	if override, _, err := key.GetStringValue("ProxyOverride"); err == nil {
+		config.Bypass = strings.Split(override, ";")
+	}
+	return config
 }
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	utls "github.com/refraction-networking/utls"
+)
+
+// tlsProfile describes how a client identifies itself: the TLS ClientHello it
+// sends and the default headers the same client would send with it
+type tlsProfile struct {
+	name     string
+	hello    utls.ClientHelloID
+	standard bool // use crypto/tls instead of uTLS
+
+	// Default headers in the order they are applied; user headers take precedence
+	headers []models.KeyValue
+}
+
+const (
+	chromeUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefoxUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0"
+	safariUserAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15"
+	iosUserAgent     = "Mozilla/5.0 (iPhone; CPU iPhone OS 14_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1"
+	goUserAgent      = "Go-http-client/1.1"
+)
+
+// chromiumHeaders are the defaults sent by Chromium-based browsers for fetch() calls
+var chromiumHeaders = []models.KeyValue{
+	{Key: "User-Agent", Value: chromeUserAgent},
+	{Key: "Accept", Value: "*/*"},
+	{Key: "Accept-Language", Value: "en-US,en;q=0.9"},
+	{Key: "Accept-Encoding", Value: "gzip, deflate, br"},
+	{Key: "Sec-Fetch-Dest", Value: "empty"},
+	{Key: "Sec-Fetch-Mode", Value: "cors"},
+	{Key: "Sec-Fetch-Site", Value: "cross-site"},
+}
+
+var tlsProfiles = map[string]tlsProfile{
+	models.TLSProfileChrome: {
+		name:    models.TLSProfileChrome,
+		hello:   utls.HelloChrome_120,
+		headers: chromiumHeaders,
+	},
+	models.TLSProfileFirefox: {
+		name:  models.TLSProfileFirefox,
+		hello: utls.HelloFirefox_120,
+		headers: []models.KeyValue{
+			{Key: "User-Agent", Value: firefoxUserAgent},
+			{Key: "Accept", Value: "*/*"},
+			{Key: "Accept-Language", Value: "en-US,en;q=0.5"},
+			{Key: "Accept-Encoding", Value: "gzip, deflate, br"},
+			{Key: "Sec-Fetch-Dest", Value: "empty"},
+			{Key: "Sec-Fetch-Mode", Value: "cors"},
+			{Key: "Sec-Fetch-Site", Value: "cross-site"},
+		},
+	},
+	models.TLSProfileSafari: {
+		name:  models.TLSProfileSafari,
+		hello: utls.HelloSafari_16_0,
+		headers: []models.KeyValue{
+			{Key: "User-Agent", Value: safariUserAgent},
+			{Key: "Accept", Value: "*/*"},
+			{Key: "Accept-Language", Value: "en-US,en;q=0.9"},
+			{Key: "Accept-Encoding", Value: "gzip, deflate, br"},
+		},
+	},
+	models.TLSProfileIOS: {
+		name:  models.TLSProfileIOS,
+		hello: utls.HelloIOS_14,
+		headers: []models.KeyValue{
+			{Key: "User-Agent", Value: iosUserAgent},
+			{Key: "Accept", Value: "*/*"},
+			{Key: "Accept-Language", Value: "en-US,en;q=0.9"},
+			{Key: "Accept-Encoding", Value: "gzip, deflate, br"},
+		},
+	},
+	models.TLSProfileRandomized: {
+		name:    models.TLSProfileRandomized,
+		hello:   utls.HelloRandomized,
+		headers: chromiumHeaders,
+	},
+	models.TLSProfileGo: {
+		name:     models.TLSProfileGo,
+		standard: true,
+		headers: []models.KeyValue{
+			{Key: "User-Agent", Value: goUserAgent},
+			{Key: "Accept-Encoding", Value: "gzip"},
+		},
+	},
+}
+
+// lookupTLSProfile returns the named profile; an empty name selects the default
+func lookupTLSProfile(name string) (tlsProfile, error) {
+	if name == "" {
+		name = models.DefaultTLSProfile
+	}
+	profile, ok := tlsProfiles[name]
+	if !ok {
+		return tlsProfile{}, fmt.Errorf("unknown TLS profile %q", name)
+	}
+	return profile, nil
+}
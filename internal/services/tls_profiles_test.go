@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestExecuteUsesTLSProfile(t *testing.T) {
+	var mu sync.Mutex
+	var grease bool
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			// Browsers add GREASE values (0x?a?a) that the Go stack never sends
+			grease = false
+			for _, suite := range hello.CipherSuites {
+				if suite&0x0f0f == 0x0a0a {
+					grease = true
+				}
+			}
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		profile   string
+		userAgent string
+		grease    bool
+	}{
+		{profile: models.TLSProfileChrome, userAgent: chromeUserAgent, grease: true},
+		{profile: models.TLSProfileFirefox, userAgent: firefoxUserAgent},
+		{profile: models.TLSProfileGo, userAgent: goUserAgent},
+	}
+
+	client := NewHTTPClient()
+	for _, tt := range tests {
+		resp, err := client.Execute(context.Background(), ExecuteRequest{
+			Method:   "GET",
+			URL:      server.URL,
+			Settings: &models.RequestSettings{SkipTLSVerify: true, TLSProfile: tt.profile},
+		})
+		if err != nil {
+			t.Fatalf("%s: Execute: %v", tt.profile, err)
+		}
+		if resp.Body != tt.userAgent {
+			t.Fatalf("%s: User-Agent = %q, want %q", tt.profile, resp.Body, tt.userAgent)
+		}
+		mu.Lock()
+		if grease != tt.grease {
+			t.Fatalf("%s: GREASE in ClientHello = %v, want %v", tt.profile, grease, tt.grease)
+		}
+		mu.Unlock()
+	}
+
+	if err := client.SetTLSProfile("netscape"); err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// VariableResolver replaces {{name}} references with the values of variables. Names may
+// hold any character but braces, such as dots and dashes, and surrounding spaces are
+// ignored. Values may reference other variables, which are resolved in turn, and names
+// starting with $ are the dynamic variables of dynamicVariables.
+type VariableResolver struct {
+	values   map[string]string
+	resolved map[string]string
+	visiting map[string]bool
+
+	// now is the time of dynamic variables, the same for every reference
+	now       time.Time
+	generated []models.Variable
+
+	unresolved map[string]bool
+	cycles     map[string]bool
+}
+
+// NewVariableResolver creates a resolver of the variables of layers, where a later layer
+// overrides an earlier one, such as globals, then an environment, then a request
+func NewVariableResolver(layers ...[]models.Variable) *VariableResolver {
+	r := &VariableResolver{
+		values:     make(map[string]string),
+		resolved:   make(map[string]string),
+		visiting:   make(map[string]bool),
+		now:        time.Now(),
+		unresolved: make(map[string]bool),
+		cycles:     make(map[string]bool),
+	}
+	for _, layer := range layers {
+		for _, v := range layer {
+			if key := strings.TrimSpace(v.Key); key != "" {
+				r.values[key] = v.Value
+			}
+		}
+	}
+	return r
+}
+
+// Resolve returns text with every reference that has a value replaced. The others are
+// left as typed and reported by Unresolved and Cycles. References inside a reference,
+// such as {{$base64 {{user}}:{{password}}}}, are resolved first.
+func (r *VariableResolver) Resolve(text string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			break
+		}
+		end := referenceEnd(text, start)
+		if end < 0 {
+			break
+		}
+		b.WriteString(text[:start])
+		inner := r.Resolve(text[start+2 : end-2])
+		if value, ok := r.lookupResolved(inner); ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString("{{" + inner + "}}")
+		}
+		text = text[end:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// referenceEnd returns the index just past the }} closing the reference opened at start
+// in text, or -1 when it is not closed
+func referenceEnd(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text)-1; i++ {
+		switch text[i : i+2] {
+		case "{{":
+			depth++
+			i++
+		case "}}":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// lookupResolved returns the value of the reference inner, unless a reference nested in
+// it could not be resolved
+func (r *VariableResolver) lookupResolved(inner string) (string, bool) {
+	if strings.Contains(inner, "{{") {
+		return "", false
+	}
+	return r.lookup(strings.TrimSpace(inner))
+}
+
+// lookup returns the resolved value of name
+func (r *VariableResolver) lookup(name string) (string, bool) {
+	if strings.HasPrefix(name, "$") {
+		return r.dynamic(name)
+	}
+	if value, ok := r.resolved[name]; ok {
+		return value, true
+	}
+	raw, ok := r.values[name]
+	if !ok {
+		r.unresolved[name] = true
+		return "", false
+	}
+	if r.visiting[name] {
+		r.cycles[name] = true
+		return "", false
+	}
+
+	cycles := len(r.cycles)
+	r.visiting[name] = true
+	value := r.Resolve(raw)
+	delete(r.visiting, name)
+	if len(r.cycles) == cycles {
+		// A value cut short by a cycle depends on where the lookup started
+		r.resolved[name] = value
+	}
+	return value, true
+}
+
+// Unresolved returns the sorted names referenced so far that have no value
+func (r *VariableResolver) Unresolved() []string {
+	return sortedNames(r.unresolved)
+}
+
+// Cycles returns the sorted names referenced so far whose value refers back to itself
+func (r *VariableResolver) Cycles() []string {
+	return sortedNames(r.cycles)
+}
+
+// Generated returns the values of the dynamic variables referenced so far, in order
+func (r *VariableResolver) Generated() []models.Variable {
+	return r.generated
+}
+
+// ResolveKeyValues returns the enabled pairs of kvs with a name, resolved
+func (r *VariableResolver) ResolveKeyValues(kvs []models.KeyValue) []models.KeyValue {
+	resolved := make([]models.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		if !kv.Enabled || kv.Key == "" {
+			continue
+		}
+		kv.Key = r.Resolve(kv.Key)
+		kv.Value = r.Resolve(kv.Value)
+		resolved = append(resolved, kv)
+	}
+	return resolved
+}
+
+// ResolveAuth returns a copy of auth with every credential resolved, including those of
+// its OAuth 2.0, OAuth 1.0a, HMAC and JWT settings
+func (r *VariableResolver) ResolveAuth(auth *models.AuthConfig) *models.AuthConfig {
+	if auth == nil {
+		return nil
+	}
+	resolved := *auth
+	r.resolveFields(reflect.ValueOf(&resolved).Elem())
+	return &resolved
+}
+
+// resolveFields resolves the string fields of the struct v, copying the structs it
+// points to before resolving theirs
+func (r *VariableResolver) resolveFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(r.Resolve(field.String()))
+		case field.Kind() == reflect.Pointer && !field.IsNil() && field.Elem().Kind() == reflect.Struct:
+			copied := reflect.New(field.Elem().Type())
+			copied.Elem().Set(field.Elem())
+			r.resolveFields(copied.Elem())
+			field.Set(copied)
+		}
+	}
+}
+
+// WithQueryParams returns rawURL with the enabled params set in its query, replacing a
+// parameter of the same name and keeping the order of the others
+func WithQueryParams(rawURL string, params []models.KeyValue) (string, error) {
+	var enabled []models.KeyValue
+	for _, param := range params {
+		if param.Enabled && param.Key != "" {
+			enabled = append(enabled, param)
+		}
+	}
+	if len(enabled) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	var pairs []string
+	if u.RawQuery != "" {
+		pairs = strings.Split(u.RawQuery, "&")
+	}
+	for _, param := range enabled {
+		pair := url.QueryEscape(param.Key) + "=" + url.QueryEscape(param.Value)
+		kept := pairs[:0:0]
+		found := false
+		for _, existing := range pairs {
+			name, _, _ := strings.Cut(existing, "=")
+			if name, err := url.QueryUnescape(name); err != nil || name != param.Key {
+				kept = append(kept, existing)
+			} else if !found {
+				kept = append(kept, pair)
+				found = true
+			}
+		}
+		if !found {
+			kept = append(kept, pair)
+		}
+		pairs = kept
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+	return u.String(), nil
+}
+
+// sortedNames returns the keys of set in order
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildVariablesJSON builds JSON string from variables, or an empty one when there are none
+func BuildVariablesJSON(variables []models.Variable) string {
+	if len(variables) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(variables)
+	return string(data)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+// dynamicVariables evaluate the built-in {{$name args}} variables from their arguments,
+// the text after the name. Each reference gets a new value, but a variable whose value
+// references one keeps a single value for the whole request.
+var dynamicVariables = map[string]func(r *VariableResolver, args string) (string, error){
+	// {{$uuid}}: a random version 4 UUID
+	"$uuid": func(r *VariableResolver, args string) (string, error) {
+		return newUUID(), nil
+	},
+
+	// {{$timestamp [offset...]}}: Unix time in seconds
+	"$timestamp": func(r *VariableResolver, args string) (string, error) {
+		t, rest, err := r.offsetNow(strings.Fields(args))
+		if err != nil || len(rest) > 0 {
+			return "", errors.Join(err, unexpectedArgs(rest))
+		}
+		return strconv.FormatInt(t.Unix(), 10), nil
+	},
+
+	// {{$isoTimestamp [offset...]}}: ISO 8601 time in UTC with milliseconds
+	"$isoTimestamp": func(r *VariableResolver, args string) (string, error) {
+		t, rest, err := r.offsetNow(strings.Fields(args))
+		if err != nil || len(rest) > 0 {
+			return "", errors.Join(err, unexpectedArgs(rest))
+		}
+		return t.UTC().Format("2006-01-02T15:04:05.000Z"), nil
+	},
+
+	// {{$date [offset...] [utc] [format]}}: local or UTC time in format, a pattern of
+	// dateTokens or one of iso, unix, unixms and http; YYYY-MM-DD by default
+	"$date": func(r *VariableResolver, args string) (string, error) {
+		t, rest, err := r.offsetNow(strings.Fields(args))
+		if err != nil {
+			return "", err
+		}
+		if len(rest) > 0 && rest[0] == "utc" {
+			t, rest = t.UTC(), rest[1:]
+		}
+		return formatDate(t, strings.Join(rest, " ")), nil
+	},
+
+	// {{$randomInt [min max]}}: a random integer from min to max inclusive, 0 to 1000 by
+	// default
+	"$randomInt": func(r *VariableResolver, args string) (string, error) {
+		bounds := strings.Fields(args)
+		lo, hi := int64(0), int64(1000)
+		if len(bounds) > 0 {
+			if len(bounds) != 2 {
+				return "", errors.New("want a minimum and a maximum")
+			}
+			var err1, err2 error
+			lo, err1 = strconv.ParseInt(bounds[0], 10, 64)
+			hi, err2 = strconv.ParseInt(bounds[1], 10, 64)
+			if err := errors.Join(err1, err2); err != nil {
+				return "", err
+			}
+			if lo > hi {
+				return "", fmt.Errorf("minimum %d is above maximum %d", lo, hi)
+			}
+		}
+		// The width is computed in uint64 as hi-lo+1 overflows int64 for wide ranges
+		offset := mathrand.Uint64()
+		if width := uint64(hi) - uint64(lo); width != math.MaxUint64 {
+			offset = mathrand.Uint64N(width + 1)
+		}
+		return strconv.FormatInt(lo+int64(offset), 10), nil
+	},
+
+	// {{$randomEmail}}: a random address at example.com
+	"$randomEmail": func(r *VariableResolver, args string) (string, error) {
+		const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+		name := make([]byte, 10)
+		for i := range name {
+			name[i] = letters[mathrand.IntN(len(letters))]
+		}
+		return string(name) + "@example.com", nil
+	},
+
+	// {{$base64 text}}: text encoded as standard base64
+	"$base64": func(r *VariableResolver, args string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(args)), nil
+	},
+}
+
+// dynamic returns the value of the dynamic variable reference ref, recording it
+func (r *VariableResolver) dynamic(ref string) (string, bool) {
+	name, args := ref, ""
+	if i := strings.IndexFunc(ref, unicode.IsSpace); i >= 0 {
+		name, args = ref[:i], strings.TrimSpace(ref[i:])
+	}
+	eval, ok := dynamicVariables[name]
+	if !ok {
+		r.unresolved[ref] = true
+		return "", false
+	}
+	value, err := eval(r, args)
+	if err != nil {
+		// Report the reference as typed; its arguments are what is wrong
+		r.unresolved[ref] = true
+		return "", false
+	}
+	r.generated = append(r.generated, models.Variable{Key: ref, Value: value})
+	return value, true
+}
+
+// dateOffset matches an offset of dynamic time variables such as +1d or -30m
+var dateOffset = regexp.MustCompile(`^([+-])(\d+)(ms|y|M|w|d|h|m|s)$`)
+
+// offsetNow returns the time of r moved by the offsets leading args, and the arguments
+// after them
+func (r *VariableResolver) offsetNow(args []string) (time.Time, []string, error) {
+	t := r.now
+	for len(args) > 0 {
+		m := dateOffset.FindStringSubmatch(args[0])
+		if m == nil {
+			break
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return t, nil, err
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "y":
+			t = t.AddDate(n, 0, 0)
+		case "M":
+			t = t.AddDate(0, n, 0)
+		case "w":
+			t = t.AddDate(0, 0, 7*n)
+		case "d":
+			t = t.AddDate(0, 0, n)
+		case "h":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "m":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "s":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "ms":
+			t = t.Add(time.Duration(n) * time.Millisecond)
+		}
+		args = args[1:]
+	}
+	return t, args, nil
+}
+
+// unexpectedArgs returns an error naming args, or nil when there are none
+func unexpectedArgs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unexpected %q", strings.Join(args, " "))
+}
+
+// dateTokens are the tokens of $date formats, longest first where one is the prefix of
+// another. Text in [brackets] is kept as it is.
+var dateTokens = []struct {
+	token  string
+	format func(time.Time) string
+}{
+	{"YYYY", func(t time.Time) string { return t.Format("2006") }},
+	{"YY", func(t time.Time) string { return t.Format("06") }},
+	{"MMMM", func(t time.Time) string { return t.Format("January") }},
+	{"MMM", func(t time.Time) string { return t.Format("Jan") }},
+	{"MM", func(t time.Time) string { return t.Format("01") }},
+	{"M", func(t time.Time) string { return t.Format("1") }},
+	{"DD", func(t time.Time) string { return t.Format("02") }},
+	{"D", func(t time.Time) string { return t.Format("2") }},
+	{"dddd", func(t time.Time) string { return t.Format("Monday") }},
+	{"ddd", func(t time.Time) string { return t.Format("Mon") }},
+	{"HH", func(t time.Time) string { return t.Format("15") }},
+	{"H", func(t time.Time) string { return strconv.Itoa(t.Hour()) }},
+	{"hh", func(t time.Time) string { return t.Format("03") }},
+	{"h", func(t time.Time) string { return t.Format("3") }},
+	{"mm", func(t time.Time) string { return t.Format("04") }},
+	{"m", func(t time.Time) string { return t.Format("4") }},
+	{"ss", func(t time.Time) string { return t.Format("05") }},
+	{"s", func(t time.Time) string { return t.Format("5") }},
+	{"SSS", func(t time.Time) string { return t.Format(".000")[1:] }},
+	{"A", func(t time.Time) string { return t.Format("PM") }},
+	{"a", func(t time.Time) string { return t.Format("pm") }},
+	{"ZZ", func(t time.Time) string { return t.Format("-0700") }},
+	{"Z", func(t time.Time) string { return t.Format("-07:00") }},
+}
+
+// formatDate returns t in format, a pattern of dateTokens or one of the named formats
+func formatDate(t time.Time, format string) string {
+	switch format {
+	case "":
+		format = "YYYY-MM-DD"
+	case "iso":
+		return t.Format("2006-01-02T15:04:05.000Z07:00")
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "http":
+		return t.UTC().Format(http.TimeFormat)
+	}
+
+	var b strings.Builder
+next:
+	for format != "" {
+		if format[0] == '[' {
+			if end := strings.IndexByte(format, ']'); end > 0 {
+				b.WriteString(format[1:end])
+				format = format[end+1:]
+				continue
+			}
+		}
+		for _, token := range dateTokens {
+			if strings.HasPrefix(format, token.token) {
+				b.WriteString(token.format(t))
+				format = format[len(token.token):]
+				continue next
+			}
+		}
+		b.WriteByte(format[0])
+		format = format[1:]
+	}
+	return b.String()
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
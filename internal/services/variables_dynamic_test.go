@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestDynamicVariables(t *testing.T) {
+	r := NewVariableResolver([]models.Variable{{Key: "user", Value: "alice"}, {Key: "password", Value: "pw"}})
+	r.now = time.Date(2024, 2, 29, 13, 4, 5, 678e6, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"{{$timestamp}}", "1709208245"},
+		{"{{ $timestamp -1h }}", "1709204645"},
+		{"{{$isoTimestamp}}", "2024-02-29T12:04:05.678Z"},
+		{"{{$isoTimestamp +1y}}", "2025-03-01T12:04:05.678Z"},
+		{"{{$date}}", "2024-02-29"},
+		{"{{$date +1M -2d DD/MM/YYYY}}", "27/03/2024"},
+		{"{{$date ddd, D MMM YY h:mm:ss.SSS a ZZ}}", "Thu, 29 Feb 24 1:04:05.678 pm +0100"},
+		{"{{$date utc HH:mm Z [at] dddd}}", "12:04 +00:00 at Thursday"},
+		{"{{$date +1w unixms}}", "1709813045678"},
+		{"{{$date http}}", "Thu, 29 Feb 2024 12:04:05 GMT"},
+		{"{{$base64 {{user}}:{{password}}}}", "YWxpY2U6cHc="},
+		{"{{$randomInt 7 7}}", "7"},
+	}
+	for _, tt := range tests {
+		if got := r.Resolve(tt.text); got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+	if got := r.Unresolved(); len(got) != 0 {
+		t.Errorf("Unresolved = %q", got)
+	}
+	if got := len(r.Generated()); got != len(tests) {
+		t.Errorf("generated %d values, want %d", got, len(tests))
+	}
+}
+
+func TestDynamicRandomVariables(t *testing.T) {
+	r := NewVariableResolver([]models.Variable{{Key: "orderId", Value: "order-{{$uuid}}"}})
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := r.Resolve("{{$uuid}}"), r.Resolve("{{$uuid}}")
+	if !uuid.MatchString(first) || first == second {
+		t.Errorf("uuids %q and %q", first, second)
+	}
+	if a, b := r.Resolve("{{orderId}}"), r.Resolve("{{orderId}}"); a != b {
+		t.Errorf("a variable gave %q then %q, want one value per request", a, b)
+	}
+
+	for range 50 {
+		n, err := strconv.Atoi(r.Resolve("{{$randomInt -2 2}}"))
+		if err != nil || n < -2 || n > 2 {
+			t.Fatalf("randomInt = %d, %v", n, err)
+		}
+	}
+	for _, bounds := range [][2]int64{{0, math.MaxInt64}, {math.MinInt64, 0}, {math.MinInt64, math.MaxInt64}} {
+		text := fmt.Sprintf("{{$randomInt %d %d}}", bounds[0], bounds[1])
+		n, err := strconv.ParseInt(r.Resolve(text), 10, 64)
+		if err != nil || n < bounds[0] || n > bounds[1] {
+			t.Errorf("Resolve(%q) = %d, %v", text, n, err)
+		}
+	}
+	if email := r.Resolve("{{$randomEmail}}"); !regexp.MustCompile(`^[a-z0-9]{10}@example\.com$`).MatchString(email) {
+		t.Errorf("randomEmail = %q", email)
+	}
+
+	generated := r.Generated()
+	if len(generated) != 57 || generated[0].Key != "$uuid" || generated[0].Value != first {
+		t.Errorf("generated = %d values starting %+v", len(generated), generated[0])
+	}
+}
+
+func TestDynamicVariablesInvalid(t *testing.T) {
+	r := NewVariableResolver()
+	for _, text := range []string{"{{$nope}}", "{{$randomInt 5 1}}", "{{$randomInt x}}", "{{$timestamp +1d later}}", "{{$base64 {{missing}}}}"} {
+		if got := r.Resolve(text); got != text {
+			t.Errorf("Resolve(%q) = %q, want it left as typed", text, got)
+		}
+	}
+	want := []string{"$nope", "$randomInt 5 1", "$randomInt x", "$timestamp +1d later", "missing"}
+	if got := r.Unresolved(); !slices.Equal(got, want) {
+		t.Errorf("Unresolved = %q, want %q", got, want)
+	}
+	if got := r.Generated(); len(got) != 0 {
+		t.Errorf("generated %+v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SoulTraitor/postme/internal/models"
+)
+
+func TestVariableResolverPrecedenceAndNesting(t *testing.T) {
+	r := NewVariableResolver(
+		[]models.Variable{{Key: "host", Value: "global.example"}, {Key: "scheme", Value: "http"}, {Key: "api.version", Value: "v1"}},
+		[]models.Variable{{Key: "host", Value: "env.example"}, {Key: "base-url", Value: "{{scheme}}://{{ host }}/{{api.version}}"}},
+		[]models.Variable{{Key: "scheme", Value: "https"}},
+	)
+
+	if got := r.Resolve("{{base-url}}/users?id={{id}}"); got != "https://env.example/v1/users?id={{id}}" {
+		t.Errorf("Resolve = %q", got)
+	}
+	if got := r.Unresolved(); !reflect.DeepEqual(got, []string{"id"}) {
+		t.Errorf("Unresolved = %q", got)
+	}
+	if got := r.Cycles(); len(got) != 0 {
+		t.Errorf("Cycles = %q", got)
+	}
+}
+
+func TestVariableResolverCycles(t *testing.T) {
+	r := NewVariableResolver([]models.Variable{
+		{Key: "a", Value: "x{{b}}"},
+		{Key: "b", Value: "y{{a}}"},
+		{Key: "self", Value: "{{self}}"},
+		{Key: "ok", Value: "fine"},
+	})
+
+	if got := r.Resolve("{{a}} {{ok}}"); got != "xy{{a}} fine" {
+		t.Errorf("Resolve = %q", got)
+	}
+	if got := r.Resolve("{{b}}"); got != "yx{{b}}" {
+		t.Errorf("Resolve from the other end = %q", got)
+	}
+	r.Resolve("{{self}}")
+	if got := r.Cycles(); !reflect.DeepEqual(got, []string{"a", "b", "self"}) {
+		t.Errorf("Cycles = %q", got)
+	}
+	if got := r.Unresolved(); len(got) != 0 {
+		t.Errorf("Unresolved = %q", got)
+	}
+}
+
+func TestVariableResolverAuthAndKeyValues(t *testing.T) {
+	r := NewVariableResolver([]models.Variable{{Key: "secret", Value: "s3cret"}, {Key: "name", Value: "X-Key"}})
+
+	auth := &models.AuthConfig{Type: models.AuthTypeJWT, JWT: &models.JWTConfig{
+		Algorithm: models.JWTHS256, Secret: "{{secret}}", Claims: `{"sub":"{{name}}"}`,
+	}}
+	resolved := r.ResolveAuth(auth)
+	if resolved.JWT.Secret != "s3cret" || resolved.JWT.Claims != `{"sub":"X-Key"}` {
+		t.Errorf("resolved jwt = %+v", resolved.JWT)
+	}
+	if auth.JWT.Secret != "{{secret}}" {
+		t.Errorf("ResolveAuth changed the original to %+v", auth.JWT)
+	}
+
+	headers := r.ResolveKeyValues([]models.KeyValue{
+		{Key: "{{name}}", Value: "{{secret}}", Enabled: true},
+		{Key: "Disabled", Value: "x"},
+		{Key: "", Value: "x", Enabled: true},
+	})
+	if want := []models.KeyValue{{Key: "X-Key", Value: "s3cret", Enabled: true}}; !reflect.DeepEqual(headers, want) {
+		t.Errorf("ResolveKeyValues = %+v", headers)
+	}
+}
+
+func TestWithQueryParams(t *testing.T) {
+	tests := []struct {
+		url    string
+		params []models.KeyValue
+		want   string
+	}{
+		{"https://example.com/a", nil, "https://example.com/a"},
+		{"https://example.com/a?z=1&b=2&z=3#top", []models.KeyValue{
+			{Key: "z", Value: "new value", Enabled: true},
+			{Key: "c", Value: "a&b", Enabled: true},
+			{Key: "b", Value: "off"},
+		}, "https://example.com/a?z=new+value&b=2&c=a%26b#top"},
+	}
+	for _, tt := range tests {
+		got, err := WithQueryParams(tt.url, tt.params)
+		if err != nil || got != tt.want {
+			t.Errorf("WithQueryParams(%q) = %q, %v; want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestEnvironmentServiceResolver(t *testing.T) {
+	service := NewEnvironmentService(newTestDB(t))
+	if err := service.UpdateGlobalVariables([]models.Variable{{Key: "host", Value: "global"}, {Key: "port", Value: "80"}}); err != nil {
+		t.Fatal(err)
+	}
+	env := &models.Environment{Name: "dev", Variables: []models.Variable{{Key: "host", Value: "dev"}}}
+	if err := service.Create(env); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := int64(999)
+	for _, tt := range []struct {
+		envID *int64
+		want  string
+	}{
+		{nil, "global:80"},
+		{&env.ID, "dev:80"},
+		{&missing, "global:80"},
+	} {
+		r, err := service.Resolver(tt.envID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Resolve("{{host}}:{{port}}"); got != tt.want {
+			t.Errorf("Resolve = %q, want %q", got, tt.want)
+		}
+	}
+
+	r, err := service.Resolver(&env.ID, []models.Variable{{Key: "host", Value: "request"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Resolve("{{host}}"); got != "request" {
+		t.Errorf("request-level value lost to %q", got)
+	}
+}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// webSocketHandshakeTimeout bounds the opening handshake when the request has no timeout
+	webSocketHandshakeTimeout = 30 * time.Second
+
+	// webSocketCloseTimeout is how long Close waits for the server to answer the close frame
+	webSocketCloseTimeout = 5 * time.Second
+
+	// webSocketWriteTimeout bounds writing one frame
+	webSocketWriteTimeout = 10 * time.Second
+)
+
+// WebSocketRequest represents a WebSocket connection to open
+type WebSocketRequest struct {
+	URL     string            `json:"url"`
+	Headers []models.KeyValue `json:"headers"`
+	Timeout float64           `json:"timeout"` // seconds for the opening handshake
+
+	// Settings overrides app-wide settings for this connection; Subprotocols are
+	// offered in the handshake
+	Settings *models.RequestSettings `json:"settings"`
+
+	// EnvironmentID is the active environment, used to pick the cookie jar and proxy override
+	EnvironmentID *int64 `json:"environmentId"`
+}
+
+// WebSocketService runs WebSocket sessions, at most one per tab, over the proxy, TLS
+// and cookie settings of an HTTPClient
+type WebSocketService struct {
+	client *HTTPClient
+
+	mu       sync.Mutex
+	sessions map[string]*webSocketSession
+}
+
+// NewWebSocketService creates a WebSocketService connecting like client
+func NewWebSocketService(client *HTTPClient) *WebSocketService {
+	return &WebSocketService{
+		client:   client,
+		sessions: make(map[string]*webSocketSession),
+	}
+}
+
+// Connect opens a WebSocket session for tabID, closing any session the tab had. Every
+// message of the session, both ways, is recorded and passed to onMessage, which is
+// called for one message at a time and must not call back into the service.
+func (s *WebSocketService) Connect(ctx context.Context, tabID string, req WebSocketRequest, onMessage func(models.WebSocketMessage)) (*models.WebSocketHandshake, error) {
+	s.Close(tabID, websocket.CloseNormalClosure, "")
+
+	conn, resp, err := s.client.dialWebSocket(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another Connect of the tab may have stored its session while this one dialed;
+	// the newest replaces it so no connection is left open without a way to close it
+	session := newWebSocketSession(conn, onMessage)
+	s.mu.Lock()
+	replaced := s.sessions[tabID]
+	s.sessions[tabID] = session
+	s.mu.Unlock()
+	if replaced != nil {
+		replaced.close(websocket.CloseNormalClosure, "")
+	}
+
+	session.record(models.WebSocketMessage{Type: models.WebSocketOpen, Data: req.URL})
+	go session.readLoop()
+
+	return &models.WebSocketHandshake{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		Headers:     headerList(resp.Header, nil),
+		Subprotocol: conn.Subprotocol(),
+	}, nil
+}
+
+// Send sends a message on the session of tabID. messageType is "text", "json", which
+// must be valid JSON and is sent as text, or "binary", whose data is base64.
+func (s *WebSocketService) Send(tabID, messageType, data string) error {
+	session, err := s.session(tabID)
+	if err != nil {
+		return err
+	}
+
+	switch messageType {
+	case models.WebSocketText:
+		return session.write(websocket.TextMessage, []byte(data), data)
+	case "json":
+		if !json.Valid([]byte(data)) {
+			return errors.New("message is not valid JSON")
+		}
+		return session.write(websocket.TextMessage, []byte(data), data)
+	case models.WebSocketBinary:
+		payload, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return fmt.Errorf("binary message must be base64: %w", err)
+		}
+		return session.write(websocket.BinaryMessage, payload, data)
+	default:
+		return fmt.Errorf("unknown message type %q", messageType)
+	}
+}
+
+// Ping sends a ping frame with data on the session of tabID
+func (s *WebSocketService) Ping(tabID, data string) error {
+	session, err := s.session(tabID)
+	if err != nil {
+		return err
+	}
+	return session.write(websocket.PingMessage, []byte(data), data)
+}
+
+// Close sends a close frame with code and reason on the session of tabID and waits
+// briefly for the server to close the connection. Closing a tab without an open
+// session does nothing.
+func (s *WebSocketService) Close(tabID string, code int, reason string) error {
+	s.mu.Lock()
+	session := s.sessions[tabID]
+	s.mu.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.close(code, reason)
+}
+
+// Transcript returns the messages of the last session of tabID, open or closed
+func (s *WebSocketService) Transcript(tabID string) []models.WebSocketMessage {
+	s.mu.Lock()
+	session := s.sessions[tabID]
+	s.mu.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.messages()
+}
+
+// SaveTranscript writes the transcript of the last session of tabID to path as JSON
+func (s *WebSocketService) SaveTranscript(tabID, path string) error {
+	data, err := json.MarshalIndent(s.Transcript(tabID), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Release closes the session of tabID and forgets its transcript
+func (s *WebSocketService) Release(tabID string) {
+	s.Close(tabID, websocket.CloseGoingAway, "")
+	s.mu.Lock()
+	delete(s.sessions, tabID)
+	s.mu.Unlock()
+}
+
+// CloseAll closes every open session
+func (s *WebSocketService) CloseAll() {
+	s.mu.Lock()
+	sessions := make([]*webSocketSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close(websocket.CloseGoingAway, "")
+	}
+}
+
+// session returns the open session of tabID
+func (s *WebSocketService) session(tabID string) (*webSocketSession, error) {
+	s.mu.Lock()
+	session := s.sessions[tabID]
+	s.mu.Unlock()
+	if session == nil || session.isClosed() {
+		return nil, errors.New("WebSocket is not connected")
+	}
+	return session, nil
+}
+
+// webSocketSession is one open WebSocket connection and its transcript
+type webSocketSession struct {
+	conn      *websocket.Conn
+	start     time.Time
+	onMessage func(models.WebSocketMessage)
+	done      chan struct{} // closed when the read loop ends
+
+	writeMu sync.Mutex // gorilla allows one concurrent writer
+
+	mu         sync.Mutex
+	transcript []models.WebSocketMessage
+	closed     bool
+}
+
+// newWebSocketSession wraps conn, recording control frames as they arrive
+func newWebSocketSession(conn *websocket.Conn, onMessage func(models.WebSocketMessage)) *webSocketSession {
+	s := &webSocketSession{
+		conn:      conn,
+		start:     time.Now(),
+		onMessage: onMessage,
+		done:      make(chan struct{}),
+	}
+
+	conn.SetPingHandler(func(data string) error {
+		s.record(models.WebSocketMessage{Direction: models.WebSocketReceived, Type: models.WebSocketPing, Data: data})
+		err := s.write(websocket.PongMessage, []byte(data), data)
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	conn.SetPongHandler(func(data string) error {
+		s.record(models.WebSocketMessage{Direction: models.WebSocketReceived, Type: models.WebSocketPong, Data: data})
+		return nil
+	})
+	conn.SetCloseHandler(func(code int, reason string) error {
+		s.record(models.WebSocketMessage{Direction: models.WebSocketReceived, Type: models.WebSocketClose, Code: code, Data: reason})
+		// Answer with the same code, unless we started the closing handshake
+		s.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), "")
+		return nil
+	})
+	return s
+}
+
+// readLoop records received messages until the connection ends
+func (s *webSocketSession) readLoop() {
+	defer close(s.done)
+	defer s.conn.Close()
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			wasClosed := s.closed
+			s.closed = true
+			s.mu.Unlock()
+
+			// A close frame was already recorded by the close handler, and an error
+			// after we sent ours is just the connection going away
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) && !wasClosed {
+				s.record(models.WebSocketMessage{Type: models.WebSocketError, Data: err.Error()})
+			}
+			return
+		}
+
+		message := models.WebSocketMessage{Direction: models.WebSocketReceived, Type: models.WebSocketText, Data: string(data)}
+		if messageType == websocket.BinaryMessage {
+			message.Type = models.WebSocketBinary
+			message.Data = base64.StdEncoding.EncodeToString(data)
+		}
+		s.record(message)
+	}
+}
+
+// write sends one frame and records it as sent with the given transcript text
+func (s *webSocketSession) write(messageType int, payload []byte, text string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	deadline := time.Now().Add(webSocketWriteTimeout)
+	var err error
+	if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
+		s.conn.SetWriteDeadline(deadline)
+		err = s.conn.WriteMessage(messageType, payload)
+	} else {
+		err = s.conn.WriteControl(messageType, payload, deadline)
+	}
+	if err != nil {
+		return err
+	}
+
+	message := models.WebSocketMessage{Direction: models.WebSocketSent, Data: text}
+	switch messageType {
+	case websocket.TextMessage:
+		message.Type = models.WebSocketText
+	case websocket.BinaryMessage:
+		message.Type = models.WebSocketBinary
+	case websocket.PingMessage:
+		message.Type = models.WebSocketPing
+	case websocket.PongMessage:
+		message.Type = models.WebSocketPong
+	case websocket.CloseMessage:
+		// Recorded by close, which knows the code
+		return nil
+	}
+	s.record(message)
+	return nil
+}
+
+// close starts the closing handshake and waits for the read loop to end
+func (s *webSocketSession) close(code int, reason string) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), reason)
+	if err == nil {
+		s.record(models.WebSocketMessage{Direction: models.WebSocketSent, Type: models.WebSocketClose, Code: code, Data: reason})
+	}
+
+	select {
+	case <-s.done:
+	case <-time.After(webSocketCloseTimeout):
+		s.conn.Close()
+		<-s.done
+	}
+	return nil
+}
+
+// isClosed reports whether the session can no longer send
+func (s *webSocketSession) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// record adds message to the transcript and passes it on
+func (s *webSocketSession) record(message models.WebSocketMessage) {
+	message.Time = time.Since(s.start).Milliseconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcript = append(s.transcript, message)
+	if s.onMessage != nil {
+		// Under the lock, so messages are passed on one at a time and in transcript order
+		s.onMessage(message)
+	}
+}
+
+// messages returns a copy of the transcript
+func (s *webSocketSession) messages() []models.WebSocketMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.WebSocketMessage(nil), s.transcript...)
+}
+
+// dialWebSocket performs the opening handshake of req through the proxy chain, TLS
+// settings, client certificates and cookie jar that HTTP requests use. The TLS
+// handshake is Go's own, offering only HTTP/1.1, since the WebSocket handshake
+// cannot be carried over a connection a browser fingerprint negotiated as HTTP/2.
+func (c *HTTPClient) dialWebSocket(ctx context.Context, req WebSocketRequest) (*websocket.Conn, *http.Response, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The proxy chain is decided as for the equivalent HTTP URL
+	probeURL := *target
+	switch strings.ToLower(target.Scheme) {
+	case "ws":
+		probeURL.Scheme = "http"
+	case "wss":
+		probeURL.Scheme = "https"
+	default:
+		return nil, nil, errors.New("WebSocket URL must start with ws:// or wss://")
+	}
+
+	if proxy := c.proxyOverride(ExecuteRequest{Settings: req.Settings, EnvironmentID: req.EnvironmentID}); proxy != nil {
+		ctx = withProxyConfig(ctx, proxy)
+	}
+	probe, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	transport := c.client.Transport.(*utlsTransport)
+	c.mu.Unlock()
+	proxies, err := transport.proxiesFor(probe)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skipVerify := req.Settings != nil && req.Settings.SkipTLSVerify
+	dialer := &websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return transport.dialChain(ctx, proxies, addr)
+		},
+		NetDialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			config, err := transport.tlsConfig(addr, target.Hostname(), tlsOptions{skipVerify: skipVerify})
+			if err != nil {
+				return nil, err
+			}
+			config.NextProtos = []string{"http/1.1"}
+
+			conn, err := transport.dialChain(ctx, proxies, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, config)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		},
+		HandshakeTimeout:  webSocketHandshakeTimeout,
+		Jar:               c.cookieJar(req.EnvironmentID),
+		EnableCompression: true,
+	}
+	if req.Timeout > 0 {
+		dialer.HandshakeTimeout = time.Duration(req.Timeout * float64(time.Second))
+	}
+	if req.Settings != nil {
+		dialer.Subprotocols = req.Settings.Subprotocols
+	}
+
+	header := make(http.Header)
+	for _, h := range req.Headers {
+		if h.Enabled && h.Key != "" {
+			header.Add(h.Key, h.Value)
+		}
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, target.String(), header)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			return nil, nil, fmt.Errorf("WebSocket handshake failed: server answered %s", resp.Status)
+		}
+		return nil, nil, err
+	}
+	return conn, resp, nil
+}
+
+// dialChain connects to addr through the first proxy of proxies that can be reached,
+// directly for a nil entry
+func (t *utlsTransport) dialChain(ctx context.Context, proxies []*url.URL, addr string) (net.Conn, error) {
+	var err error
+	for _, proxyURL := range proxies {
+		var conn net.Conn
+		if proxyURL == nil {
+			conn, err = t.dialer.DialContext(ctx, "tcp", addr)
+		} else {
+			conn, err = t.dialThroughProxy(ctx, proxyURL, addr)
+		}
+		if err == nil || !proxyUnreachable(err) || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+	return nil, err
+}
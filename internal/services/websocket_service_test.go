@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SoulTraitor/postme/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// newTestWebSocketServer returns a handler echoing every message back, which closes
+// the connection with code 4000 when it receives "bye"
+func newTestWebSocketServer(t *testing.T) http.Handler {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"chat.v2"}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Echo": {r.Header.Get("X-Token")}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(data) == "bye" {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(4000, "done"), time.Now().Add(time.Second))
+				continue
+			}
+			if string(data) == "ping me" {
+				conn.WriteControl(websocket.PingMessage, []byte("hi"), time.Now().Add(time.Second))
+				continue
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	})
+}
+
+// waitForMessage waits until the transcript of tabID has a message matching match
+func waitForMessage(t *testing.T, service *WebSocketService, tabID string, match func(models.WebSocketMessage) bool) models.WebSocketMessage {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, message := range service.Transcript(tabID) {
+			if match(message) {
+				return message
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("message not received; transcript %+v", service.Transcript(tabID))
+	return models.WebSocketMessage{}
+}
+
+func received(messageType, data string) func(models.WebSocketMessage) bool {
+	return func(m models.WebSocketMessage) bool {
+		return m.Direction == models.WebSocketReceived && m.Type == messageType && m.Data == data
+	}
+}
+
+func TestWebSocketSession(t *testing.T) {
+	server := httptest.NewServer(newTestWebSocketServer(t))
+	defer server.Close()
+
+	service := NewWebSocketService(NewHTTPClient())
+	defer service.CloseAll()
+
+	handshake, err := service.Connect(context.Background(), "tab", WebSocketRequest{
+		URL:      "ws" + strings.TrimPrefix(server.URL, "http"),
+		Headers:  []models.KeyValue{{Key: "X-Token", Value: "secret", Enabled: true}},
+		Settings: &models.RequestSettings{Subprotocols: []string{"chat.v1", "chat.v2"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if handshake.StatusCode != http.StatusSwitchingProtocols || handshake.Subprotocol != "chat.v2" {
+		t.Errorf("handshake = %+v", handshake)
+	}
+	echoed := false
+	for _, h := range handshake.Headers {
+		echoed = echoed || (h.Key == "X-Echo" && h.Value == "secret")
+	}
+	if !echoed {
+		t.Errorf("request headers were not sent; response headers %+v", handshake.Headers)
+	}
+
+	if err := service.Send("tab", "text", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	waitForMessage(t, service, "tab", received(models.WebSocketText, "hello"))
+
+	if err := service.Send("tab", "json", `{"a":1}`); err != nil {
+		t.Fatal(err)
+	}
+	waitForMessage(t, service, "tab", received(models.WebSocketText, `{"a":1}`))
+	if err := service.Send("tab", "json", `{"a":`); err == nil {
+		t.Error("invalid JSON should not be sent")
+	}
+
+	binary := base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 255})
+	if err := service.Send("tab", "binary", binary); err != nil {
+		t.Fatal(err)
+	}
+	waitForMessage(t, service, "tab", received(models.WebSocketBinary, binary))
+
+	if err := service.Ping("tab", "are you there"); err != nil {
+		t.Fatal(err)
+	}
+	waitForMessage(t, service, "tab", received(models.WebSocketPong, "are you there"))
+
+	// A ping from the server is answered with a pong
+	service.Send("tab", "text", "ping me")
+	waitForMessage(t, service, "tab", received(models.WebSocketPing, "hi"))
+	waitForMessage(t, service, "tab", func(m models.WebSocketMessage) bool {
+		return m.Direction == models.WebSocketSent && m.Type == models.WebSocketPong && m.Data == "hi"
+	})
+
+	service.Send("tab", "text", "bye")
+	closed := waitForMessage(t, service, "tab", func(m models.WebSocketMessage) bool {
+		return m.Direction == models.WebSocketReceived && m.Type == models.WebSocketClose
+	})
+	if closed.Code != 4000 || closed.Data != "done" {
+		t.Errorf("close = %+v, want code 4000 reason done", closed)
+	}
+	waitFor := time.Now().Add(5 * time.Second)
+	for service.Send("tab", "text", "after close") == nil && time.Now().Before(waitFor) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := service.Send("tab", "text", "after close"); err == nil {
+		t.Error("sending on a closed session should fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "transcript.json")
+	if err := service.SaveTranscript("tab", path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"type": "open"`) || !strings.Contains(string(data), `"code": 4000`) {
+		t.Errorf("transcript = %s", data)
+	}
+}
+
+func TestWebSocketClientClose(t *testing.T) {
+	server := httptest.NewServer(newTestWebSocketServer(t))
+	defer server.Close()
+
+	service := NewWebSocketService(NewHTTPClient())
+	if _, err := service.Connect(context.Background(), "tab", WebSocketRequest{URL: "ws" + strings.TrimPrefix(server.URL, "http")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Close("tab", websocket.CloseNormalClosure, "finished"); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent, answered bool
+	for _, m := range service.Transcript("tab") {
+		if m.Type == models.WebSocketClose {
+			sent = sent || (m.Direction == models.WebSocketSent && m.Code == websocket.CloseNormalClosure && m.Data == "finished")
+			answered = answered || m.Direction == models.WebSocketReceived
+		}
+		if m.Type == models.WebSocketError {
+			t.Errorf("a clean close should not record an error: %+v", m)
+		}
+	}
+	if !sent || !answered {
+		t.Errorf("transcript = %+v", service.Transcript("tab"))
+	}
+}
+
+func TestWebSocketConnectTwice(t *testing.T) {
+	var open atomic.Int32
+	echo := newTestWebSocketServer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		open.Add(1)
+		defer open.Add(-1)
+		echo.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	service := NewWebSocketService(NewHTTPClient())
+	defer service.CloseAll()
+
+	// Both connects dial before either stores its session
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Connect(context.Background(), "tab", WebSocketRequest{URL: "ws" + strings.TrimPrefix(server.URL, "http")}, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for open.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := open.Load(); got != 1 {
+		t.Fatalf("%d connections open after connecting the tab twice, want 1", got)
+	}
+	if err := service.Send("tab", "text", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	waitForMessage(t, service, "tab", received(models.WebSocketText, "hello"))
+}
+
+func TestWebSocketConnectErrors(t *testing.T) {
+	service := NewWebSocketService(NewHTTPClient())
+
+	_, err := service.Connect(context.Background(), "tab", WebSocketRequest{URL: "http://example.com"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "ws://") {
+		t.Errorf("expected a scheme error, got %v", err)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no upgrade here", http.StatusForbidden)
+	}))
+	defer plain.Close()
+	_, err = service.Connect(context.Background(), "tab", WebSocketRequest{URL: "ws" + strings.TrimPrefix(plain.URL, "http")}, nil)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a handshake error, got %v", err)
+	}
+
+	if err := service.Send("tab", "text", "hello"); err == nil {
+		t.Error("sending without a session should fail")
+	}
+}
+
+func TestWebSocketThroughProxyAndTLS(t *testing.T) {
+	server := httptest.NewTLSServer(newTestWebSocketServer(t))
+	defer server.Close()
+
+	proxyAddr, targets := startTestSOCKSProxy(t, "", "")
+	service := NewWebSocketService(NewHTTPClient())
+	defer service.CloseAll()
+
+	url := "wss" + strings.TrimPrefix(localhostURL(t, server.URL), "https")
+	settings := &models.RequestSettings{
+		Proxy: &models.ProxyConfig{Enabled: true, SOCKS: "socks5h://" + proxyAddr},
+	}
+
+	// The test server's certificate is not trusted unless verification is skipped
+	if _, err := service.Connect(context.Background(), "tab", WebSocketRequest{URL: url, Settings: settings}, nil); err == nil {
+		t.Fatal("expected a certificate error")
+	}
+
+	settings.SkipTLSVerify = true
+	var messages []models.WebSocketMessage
+	_, err := service.Connect(context.Background(), "tab", WebSocketRequest{URL: url, Settings: settings}, func(m models.WebSocketMessage) {
+		messages = append(messages, m)
+	})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if target := <-targets; !strings.HasPrefix(target, "localhost:") {
+		t.Errorf("proxy was asked for %q", target)
+	}
+
+	service.Send("tab", "text", "secure")
+	waitForMessage(t, service, "tab", received(models.WebSocketText, "secure"))
+	service.Close("tab", websocket.CloseNormalClosure, "")
+	if len(messages) == 0 || messages[0].Type != models.WebSocketOpen {
+		t.Errorf("callback messages = %+v", messages)
+	}
+}
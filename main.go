@@ -37,6 +37,12 @@ func main() {
 	environmentHandler := handlers.NewEnvironmentHandler()
 	historyHandler := handlers.NewHistoryHandler()
 	appStateHandler := handlers.NewAppStateHandler()
+	cookieHandler := handlers.NewCookieHandler()
+	certificateHandler := handlers.NewCertificateHandler()
+	webSocketHandler := handlers.NewWebSocketHandler(requestHandler)
+	graphQLHandler := handlers.NewGraphQLHandler(requestHandler)
+	grpcHandler := handlers.NewGRPCHandler(requestHandler)
+	oauth2Handler := handlers.NewOAuth2Handler(requestHandler)
 
 	// Initialize database early to restore window state
 	if err := database.Init(); err != nil {
@@ -96,7 +102,17 @@ func main() {
 			environmentHandler.Init()
 			historyHandler.Init()
 			appStateHandler.Init()
+			cookieHandler.Init()
+			certificateHandler.Init()
+			webSocketHandler.Init()
+			graphQLHandler.Init()
+			grpcHandler.Init()
+			oauth2Handler.Init()
 			dialogHandler.SetContext(ctx)
+			requestHandler.SetContext(ctx)
+			webSocketHandler.SetContext(ctx)
+			grpcHandler.SetContext(ctx)
+			oauth2Handler.SetContext(ctx)
 
 			restoreSavedWindowBounds(ctx, savedState, windowWidth, windowHeight)
 			if maximizeAfterRestore {
@@ -142,6 +158,8 @@ func main() {
 			return false
 		},
 		OnShutdown: func(ctx context.Context) {
+			webSocketHandler.Shutdown()
+			requestHandler.Shutdown()
 			database.Close()
 		},
 		Bind: []any{
@@ -150,6 +168,12 @@ func main() {
 			environmentHandler,
 			historyHandler,
 			appStateHandler,
+			cookieHandler,
+			certificateHandler,
+			webSocketHandler,
+			graphQLHandler,
+			grpcHandler,
+			oauth2Handler,
 			dialogHandler,
 		},
 	})